@@ -0,0 +1,125 @@
+package main
+
+// 命令行子命令
+//   ./night-fury                            启动服务
+//   ./night-fury migrate up [steps]         执行迁移
+//   ./night-fury migrate down [steps]       回滚迁移，默认回滚一个
+//   ./night-fury migrate status             查看迁移状态
+//   ./night-fury encrypt aes [value]        使用 CONFIG_AES_KEY 加密配置值
+//   ./night-fury encrypt rsa <pem> [value]  使用 pem 公钥文件加密配置值
+// encrypt 没有传入 value 时从标准输入读取
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/db"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+func runCommand(args []string) error {
+	switch args[0] {
+	case "migrate":
+		initDB()
+		return runMigrate(args[1:])
+	case "encrypt":
+		return runEncrypt(args[1:])
+	default:
+		return errors.WithMessage(ErrUnknownCommand, args[0])
+	}
+}
+
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage : migrate up|down|status [steps]")
+	}
+
+	steps := 0
+	if len(args) > 1 {
+		var err error
+		if steps, err = strconv.Atoi(args[1]); err != nil {
+			return errors.Wrap(err, "parse steps")
+		}
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx, steps)
+		for _, m := range applied {
+			fmt.Printf("applied  %d %s\n", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		reverted, err := db.MigrateDown(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %d %s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		states, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range states {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.WithMessage(ErrUnknownCommand, "migrate "+args[0])
+	}
+}
+
+func runEncrypt(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage : encrypt aes [value] | encrypt rsa <public_key_file> [value]")
+	}
+
+	alg := strings.ToUpper(args[0])
+	args = args[1:]
+
+	var publicKey []byte
+	if alg == config.AlgRSA {
+		if len(args) == 0 {
+			return errors.New("usage : encrypt rsa <public_key_file> [value]")
+		}
+		var err error
+		if publicKey, err = os.ReadFile(args[0]); err != nil {
+			return errors.Wrap(err, "read public key")
+		}
+		args = args[1:]
+	}
+
+	var value string
+	if len(args) > 0 {
+		value = args[0]
+	} else {
+		// 从标准输入读取，避免明文出现在 shell 历史中
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return errors.Wrap(err, "read value from stdin")
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+
+	enc, err := config.Encrypt(alg, value, publicKey)
+	if err != nil {
+		return err
+	}
+	fmt.Println(enc)
+	return nil
+}
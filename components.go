@@ -0,0 +1,278 @@
+package main
+
+// 服务的组件，启动顺序：
+//   tracing 、 config → db 、 redis → lock → kafka → outbox 、 job → metrics 、 debug 、 ws → http → kafka consumer 、 nacos → health
+// 关闭时相反： readyz 返回 503 → 从 nacos 注销 → 停止消费 → 停止 http → 断开 ws → ... → 关闭 db → 导出剩余的 span
+
+import (
+	"context"
+	"night-fury/dashboard"
+	"night-fury/pkgs/app"
+	"night-fury/pkgs/auth"
+	"night-fury/pkgs/casbin"
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/db"
+	"night-fury/pkgs/debug"
+	"night-fury/pkgs/health"
+	"night-fury/pkgs/job"
+	"night-fury/pkgs/kafka"
+	"night-fury/pkgs/lock"
+	"night-fury/pkgs/log"
+	"night-fury/pkgs/metrics"
+	"night-fury/pkgs/nacos"
+	"night-fury/pkgs/outbox"
+	"night-fury/pkgs/redis"
+	"night-fury/pkgs/tracing"
+	"night-fury/ws_server/bridge"
+	"night-fury/ws_server/client"
+)
+
+// stopFunc 不需要 ctx 的关闭函数
+func stopFunc(f func() error) app.Hook {
+	return func(ctx context.Context) error {
+		return f()
+	}
+}
+
+func registerComponents(a *app.App, nacosConf *nacos.Config) error {
+	var (
+		relay       *outbox.Relay
+		debugServer *debug.Server
+		server      *dashboard.Server
+	)
+
+	components := []app.Component{
+		{
+			Name: "tracing",
+			Start: func(ctx context.Context) error {
+				conf, err := tracing.LoadConfig()
+				if err != nil {
+					return err
+				}
+				return tracing.Init(ctx, conf)
+			},
+			Stop: stopFunc(tracing.Close),
+		},
+		{
+			// 配置文件变化时通知 config.OnChange 注册的回调，失败时不影响启动
+			Name: "config",
+			Start: func(ctx context.Context) error {
+				if err := config.Watch(); err != nil {
+					log.Errorf(log.TagInit, "watch config error : %s", err)
+				}
+				return nil
+			},
+			Stop: stopFunc(config.StopWatch),
+		},
+		{
+			Name: "db",
+			Start: func(ctx context.Context) error {
+				if err := openDB(ctx); err != nil {
+					return err
+				}
+				return health.Register("db", db.HealthCheck)
+			},
+			Stop: stopFunc(db.Close),
+		},
+		{
+			Name: "auth",
+			Start: func(ctx context.Context) error {
+				conf, err := auth.LoadConfig()
+				if err != nil {
+					return err
+				}
+				if err := auth.Init(conf); err != nil {
+					return err
+				}
+				return casbin.Init(config.GetString("casbin.policy_file"))
+			},
+		},
+		{
+			Name: "redis",
+			Start: func(ctx context.Context) error {
+				conf, err := redis.LoadConfig()
+				if err != nil || !conf.Enabled() {
+					return err
+				}
+				if err := redis.Init(ctx, conf); err != nil {
+					return err
+				}
+				return health.Register("redis", redis.HealthCheck)
+			},
+			Stop: stopFunc(redis.Close),
+		},
+		{
+			// 分布式锁优先使用 redis ，其次使用 postgres advisory lock ，都没有时只在进程内生效
+			Name:      "lock",
+			DependsOn: []string{"db", "redis"},
+			Start: func(ctx context.Context) error {
+				if redis.GetClient() != nil {
+					lock.SetDefault(redis.NewLocker())
+				} else if db.Dialect() == db.DriverPostgres {
+					lock.SetDefault(db.NewAdvisoryLocker())
+				} else {
+					lock.SetDefault(lock.NewMemoryLocker())
+				}
+				return nil
+			},
+		},
+		{
+			// Close 先停止消费，再关闭 Producer
+			Name: "kafka",
+			Start: func(ctx context.Context) error {
+				conf, err := kafka.LoadConfig()
+				if err != nil || !conf.Enabled() {
+					return err
+				}
+				if err := kafka.Init(conf); err != nil {
+					return err
+				}
+				if err := health.Register("kafka", kafka.HealthCheck); err != nil {
+					return err
+				}
+				return bridge.Init(client.Hub)
+			},
+			Stop: stopFunc(kafka.Close),
+		},
+		{
+			Name:      "outbox",
+			DependsOn: []string{"db", "kafka"},
+			Start: func(ctx context.Context) error {
+				if kafka.GetProducer() == nil {
+					return nil
+				}
+				conf, err := outbox.LoadRelayConfig()
+				if err != nil {
+					return err
+				}
+				relay = outbox.NewRelay(outbox.NewKafkaPublisher(kafka.GetProducer()), conf)
+				relay.Start(context.Background())
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				if relay == nil {
+					return nil
+				}
+				return relay.Stop()
+			},
+		},
+		{
+			// 任务处理函数和定时任务在各个包的 init 中注册
+			Name:      "job",
+			DependsOn: []string{"db", "lock"},
+			Start: func(ctx context.Context) error {
+				conf, err := job.LoadWorkerConfig()
+				if err != nil {
+					return err
+				}
+				job.Init(conf, lock.GetDefault())
+				job.Start(context.Background())
+				return nil
+			},
+			Stop: stopFunc(job.Stop),
+		},
+		{
+			Name: "metrics",
+			Start: func(ctx context.Context) error {
+				conf, err := metrics.LoadConfig()
+				if err != nil {
+					return err
+				}
+				metrics.Init(conf)
+				return nil
+			},
+		},
+		{
+			// pprof 、配置和 ws 连接等调试信息
+			Name: "debug",
+			Start: func(ctx context.Context) error {
+				conf, err := debug.LoadConfig()
+				if err != nil {
+					return err
+				}
+				debug.RegisterStats("ws", func() interface{} { return client.Hub.Stats() })
+				debugServer = debug.NewServer(conf)
+				return debugServer.Start()
+			},
+			Stop: func(ctx context.Context) error {
+				if debugServer == nil {
+					return nil
+				}
+				return debugServer.Close()
+			},
+		},
+		{
+			// http 停止后断开所有 ws 连接，客户端重连到其他实例
+			Name: "ws",
+			Stop: func(ctx context.Context) error {
+				client.Hub.CloseAll()
+				return nil
+			},
+		},
+		{
+			Name:      "http",
+			DependsOn: []string{"db", "auth", "lock", "job", "metrics", "ws"},
+			Start: func(ctx context.Context) error {
+				server = dashboard.NewServer()
+				return server.Start()
+			},
+			Stop: func(ctx context.Context) error {
+				if server == nil {
+					return nil
+				}
+				return server.Stop(ctx)
+			},
+		},
+		{
+			// handler 需要在启动消费之前注册
+			Name:      "kafka_consumer",
+			DependsOn: []string{"kafka", "http"},
+			Start: func(ctx context.Context) error {
+				if c := kafka.GetConsumer(); c != nil {
+					return c.Start(context.Background())
+				}
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				if c := kafka.GetConsumer(); c != nil {
+					return c.Stop()
+				}
+				return nil
+			},
+		},
+		{
+			// 关闭时先从 nacos 注销，避免新的连接进来
+			Name:      "nacos",
+			DependsOn: []string{"http"},
+			Start: func(ctx context.Context) error {
+				if !nacosConf.Enabled() || nacosConf.Service == "" {
+					return nil
+				}
+				return server.Register(nacos.GetRegistry(), nacosConf.Service)
+			},
+			Stop: func(ctx context.Context) error {
+				return server.Deregister()
+			},
+		},
+		{
+			// 最后启动，关闭时 readyz 最先返回 503
+			Name:      "health",
+			DependsOn: []string{"http", "nacos", "kafka_consumer"},
+			Start: func(ctx context.Context) error {
+				conf, err := health.LoadConfig()
+				if err != nil {
+					return err
+				}
+				return health.Init(conf)
+			},
+			Stop: stopFunc(health.Drain),
+		},
+	}
+
+	for _, c := range components {
+		if err := a.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
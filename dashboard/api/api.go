@@ -2,6 +2,7 @@ package api
 
 import (
 	"night-fury/pkgs/db"
+	"night-fury/pkgs/log"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -30,6 +31,12 @@ func Fail(c *gin.Context, code int, meta interface{}) {
 	res["data"] = nil
 	res["code"] = -1
 	res["meta"] = meta
+	// 带上 request id ，便于根据错误响应查找日志
+	if c.Request != nil {
+		if requestID := log.RequestID(c.Request.Context()); requestID != "" {
+			res["requestID"] = requestID
+		}
+	}
 	c.AbortWithStatusJSON(code, res)
 }
 
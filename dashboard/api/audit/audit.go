@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"night-fury/dashboard/api"
+	"night-fury/pkgs/db"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ListParams struct {
+	ActorID    string    `form:"actorID"`
+	Action     string    `form:"action"`
+	Resource   string    `form:"resource"`
+	ResourceID string    `form:"resourceID"`
+	RequestID  string    `form:"requestID"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	PageNo     int       `form:"pageNo"`
+	PageSize   int       `form:"pageSize"`
+	Sort       string    `form:"sort"` // asc / desc ，按创建时间排序
+}
+
+func (p *ListParams) filters() []db.Filter {
+	var filters []db.Filter
+	for field, v := range map[string]string{
+		"actor_id":    p.ActorID,
+		"action":      p.Action,
+		"resource":    p.Resource,
+		"resource_id": p.ResourceID,
+		"request_id":  p.RequestID,
+	} {
+		if v != "" {
+			filters = append(filters, db.Eq(field, v))
+		}
+	}
+	if !p.From.IsZero() {
+		filters = append(filters, db.Gte("created_at", p.From))
+	}
+	if !p.To.IsZero() {
+		filters = append(filters, db.Lt("created_at", p.To))
+	}
+	return filters
+}
+
+// @Title 审计日志
+// @Description 分页查询审计日志
+// @Param actorID query string false "操作人 id"
+// @Param action query string false "操作 create/update/delete"
+// @Param resource query string false "资源(表名)"
+// @Param resourceID query string false "资源 id"
+// @Param requestID query string false "request id"
+// @Param from query string false "开始时间 RFC3339"
+// @Param to query string false "结束时间 RFC3339"
+// @Param pageNo query int false "页数"
+// @Param pageSize query int false "每页数量"
+// @Success 200 {array} db.AuditEvent res
+// @Router	/license/api/v1/audit [get]
+func List(c *gin.Context) {
+	params := &ListParams{}
+	if err := c.BindQuery(params); err != nil {
+		api.Fail(c, 400, api.NewMeta(api.CODE_ERR_PARAMMETER, "parmeter error"))
+		return
+	}
+
+	q := db.ListQuery{
+		PageNo:   params.PageNo,
+		PageSize: params.PageSize,
+		Filters:  params.filters(),
+	}
+	if params.Sort != "" {
+		q.Sorts = []db.SortCond{{Field: "created_at", Sort: params.Sort}}
+	}
+
+	res, err := db.AuditEvents.List(c.Request.Context(), q)
+	if err != nil {
+		api.Fail(c, 400, api.NewMeta(api.CODE_ERR_PARAMMETER, err.Error()))
+		return
+	}
+
+	api.Success(c, res.Items, api.PageMeta(res.PageSize, res.PageNo, res.Total, res.Number))
+}
@@ -4,4 +4,5 @@ var (
 	CODE_ERR_INTERNAL   = "CODE_ERR_INTERNAL"
 	CODE_ERR_PARAMMETER = "CODE_ERR_PARAMMETER"
 	CODE_ERR_NOTPERMIT  = "CODE_ERR_NOTPERMIT"
+	CODE_ERR_NOTFOUND   = "CODE_ERR_NOTFOUND"
 )
@@ -0,0 +1,34 @@
+package health
+
+import (
+	"net/http"
+	"night-fury/pkgs/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 探针根据状态码判断，不使用 api.Success 的格式
+
+// @Title 健康检查
+// @Description 所有依赖的检查结果，任意必需的检查失败时返回 503
+// @Success 200 {object} health.Report res
+// @Router	/healthz [get]
+func Healthz(c *gin.Context) {
+	respond(c, health.Check(c.Request.Context()))
+}
+
+// @Title 就绪检查
+// @Description 同 /healthz ，开始关闭后返回 503
+// @Success 200 {object} health.Report res
+// @Router	/readyz [get]
+func Readyz(c *gin.Context) {
+	respond(c, health.Ready(c.Request.Context()))
+}
+
+func respond(c *gin.Context, report *health.Report) {
+	code := http.StatusOK
+	if !report.Up() {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
@@ -0,0 +1,127 @@
+package job
+
+import (
+	"errors"
+	"night-fury/dashboard/api"
+	"night-fury/pkgs/db"
+	"night-fury/pkgs/job"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ListParams struct {
+	Status   string `form:"status"` // pending / running / done / failed
+	Type     string `form:"type"`
+	Queue    string `form:"queue"`
+	PageNo   int    `form:"pageNo"`
+	PageSize int    `form:"pageSize"`
+	Sort     string `form:"sort"` // asc / desc ，按创建时间排序
+}
+
+func (p *ListParams) filters() []db.Filter {
+	var filters []db.Filter
+	for field, v := range map[string]string{
+		"status": p.Status,
+		"type":   p.Type,
+		"queue":  p.Queue,
+	} {
+		if v != "" {
+			filters = append(filters, db.Eq(field, v))
+		}
+	}
+	return filters
+}
+
+// @Title 任务列表
+// @Description 分页查询后台任务，可以按状态查看失败的任务
+// @Param status query string false "状态 pending/running/done/failed"
+// @Param type query string false "任务类型"
+// @Param queue query string false "队列"
+// @Param pageNo query int false "页数"
+// @Param pageSize query int false "每页数量"
+// @Success 200 {array} job.Job res
+// @Router	/license/api/v1/jobs [get]
+func List(c *gin.Context) {
+	params := &ListParams{}
+	if err := c.BindQuery(params); err != nil {
+		api.Fail(c, 400, api.NewMeta(api.CODE_ERR_PARAMMETER, "parmeter error"))
+		return
+	}
+
+	q := db.ListQuery{
+		PageNo:   params.PageNo,
+		PageSize: params.PageSize,
+		Filters:  params.filters(),
+	}
+	if params.Sort != "" {
+		q.Sorts = []db.SortCond{{Field: "created_at", Sort: params.Sort}}
+	}
+
+	res, err := job.Jobs.List(c.Request.Context(), q)
+	if err != nil {
+		api.Fail(c, 400, api.NewMeta(api.CODE_ERR_PARAMMETER, err.Error()))
+		return
+	}
+
+	api.Success(c, res.Items, api.PageMeta(res.PageSize, res.PageNo, res.Total, res.Number))
+}
+
+// @Title 任务统计
+// @Description 各状态的任务数量
+// @Success 200 {object} map[string]int res
+// @Router	/license/api/v1/jobs/stats [get]
+func Stats(c *gin.Context) {
+	stats, err := job.Stats(c.Request.Context())
+	if err != nil {
+		api.Fail(c, 500, api.NewMeta(api.CODE_ERR_INTERNAL, err.Error()))
+		return
+	}
+	api.Success(c, stats, nil)
+}
+
+// @Title 任务详情
+// @Param id path string true "任务 id"
+// @Success 200 {object} job.Job res
+// @Router	/license/api/v1/jobs/{id} [get]
+func Get(c *gin.Context) {
+	j, err := job.Jobs.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		failNotFound(c, err)
+		return
+	}
+	api.Success(c, j, nil)
+}
+
+// @Title 重试任务
+// @Description 重新执行失败的任务
+// @Param id path string true "任务 id"
+// @Success 200 {object} job.Job res
+// @Router	/license/api/v1/jobs/{id}/retry [post]
+func Retry(c *gin.Context) {
+	if err := job.Retry(c.Request.Context(), c.Param("id")); err != nil {
+		failNotFound(c, err)
+		return
+	}
+	Get(c)
+}
+
+// @Title 取消任务
+// @Description 取消未开始的任务
+// @Param id path string true "任务 id"
+// @Success 200 {object} job.Job res
+// @Router	/license/api/v1/jobs/{id}/cancel [post]
+func Cancel(c *gin.Context) {
+	if err := job.Cancel(c.Request.Context(), c.Param("id")); err != nil {
+		failNotFound(c, err)
+		return
+	}
+	Get(c)
+}
+
+func failNotFound(c *gin.Context, err error) {
+	if errors.Is(err, db.Nil) {
+		api.Fail(c, 404, api.NewMeta(api.CODE_ERR_NOTFOUND, "job not found or status not allowed"))
+		return
+	}
+	api.Fail(c, 500, api.NewMeta(api.CODE_ERR_INTERNAL, err.Error()))
+}
@@ -0,0 +1,80 @@
+package system
+
+import (
+	"night-fury/dashboard/api"
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Title 当前配置
+// @Description 合并后的全部配置，密码等敏感值会被替换为 ******
+// @Success 200 {object} map[string]interface{} res
+// @Router	/license/api/v1/system/config [get]
+func Config(c *gin.Context) {
+	api.Success(c, config.Redacted(), map[string]interface{}{
+		"profile": config.Profile(),
+		"files":   config.Files(),
+	})
+}
+
+type LogLevels struct {
+	Level string            `json:"level"` // 全局级别
+	Tags  map[string]string `json:"tags"`  // 单独设置了级别的模块
+}
+
+type SetLogLevelParams struct {
+	Tag   string `json:"tag"`   // 为空时修改全局级别
+	Level string `json:"level"` // 为空时模块恢复使用全局级别
+}
+
+// @Title 日志级别
+// @Description 全局和各模块的日志级别
+// @Success 200 {object} LogLevels res
+// @Router	/license/api/v1/system/log/levels [get]
+func GetLogLevels(c *gin.Context) {
+	api.Success(c, logLevels(), nil)
+}
+
+// @Title 修改日志级别
+// @Description 运行时修改全局或模块的日志级别，重启或配置变化后恢复为配置中的级别
+// @Param data body SetLogLevelParams true "模块, 级别 debug/info/warn/error"
+// @Success 200 {object} LogLevels res
+// @Router	/license/api/v1/system/log/levels [put]
+func SetLogLevel(c *gin.Context) {
+	params := &SetLogLevelParams{}
+	if err := c.BindJSON(params); err != nil {
+		api.Fail(c, 400, api.NewMeta(api.CODE_ERR_PARAMMETER, "parmeter error"))
+		return
+	}
+
+	if params.Tag != "" && params.Level == "" {
+		log.ResetTagLevel(params.Tag)
+	} else {
+		level, err := log.ParseLevel(params.Level)
+		if err != nil {
+			api.Fail(c, 400, api.NewMeta(api.CODE_ERR_PARAMMETER, err.Error()))
+			return
+		}
+		if params.Tag == "" {
+			log.SetLevel(level)
+		} else {
+			log.SetTagLevel(params.Tag, level)
+		}
+	}
+
+	// MiddleWareCasbin 保证已登录
+	log.WithContext(c.Request.Context()).WithTag(log.TagServer).
+		With(log.Params{"tag": params.Tag, "level": params.Level, "operator": api.GetSessUser(c).ID}).
+		Warn("log level changed")
+	api.Success(c, logLevels(), nil)
+}
+
+func logLevels() *LogLevels {
+	res := &LogLevels{Level: log.GetLevel().String(), Tags: map[string]string{}}
+	for tag, level := range log.TagLevels() {
+		res.Tags[tag] = level.String()
+	}
+	return res
+}
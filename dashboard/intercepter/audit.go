@@ -0,0 +1,32 @@
+package intercepter
+
+import (
+	"night-fury/dashboard/api"
+	"night-fury/pkgs/db"
+	"night-fury/pkgs/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MiddleWareAudit 开启审计，请求中的 db 变更都会记录操作人和 request id ，需要放在 MiddleWareRequestID 之后
+// 操作人在写审计记录时才获取，所以该中间件可以放在鉴权中间件之前
+func MiddleWareAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		requestID := log.RequestID(ctx)
+
+		ctx = db.WithAudit(ctx, func() *db.AuditInfo {
+			info := &db.AuditInfo{
+				RequestID: requestID,
+				IP:        c.ClientIP(),
+			}
+			if u := api.GetSessUser(c); u != nil {
+				info.ActorID = u.ID
+				info.ActorName = u.Name
+			}
+			return info
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"night-fury/dashboard/api"
 	"night-fury/pkgs/auth"
 	"night-fury/pkgs/db"
+	"night-fury/pkgs/tenant"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,7 +12,7 @@ import (
 func MiddleWareAuth(c *gin.Context) {
 	authToken := c.GetHeader("x-auth")
 	if authToken == "" {
-		c.Status(403)
+		c.AbortWithStatus(403)
 		return
 	}
 
@@ -22,8 +23,14 @@ func MiddleWareAuth(c *gin.Context) {
 	}
 
 	c.Set("_sess_user", &db.SessUser{
-		Name:  jwtClaims.Name,
-		Email: jwtClaims.Email,
-		ID:    jwtClaims.ID,
+		Name:     jwtClaims.Name,
+		Email:    jwtClaims.Email,
+		ID:       jwtClaims.ID,
+		TenantID: jwtClaims.TenantID,
 	})
+
+	// 租户信息放入 request context ，db 操作会按租户过滤
+	if jwtClaims.TenantID != "" {
+		c.Request = c.Request.WithContext(tenant.WithID(c.Request.Context(), jwtClaims.TenantID))
+	}
 }
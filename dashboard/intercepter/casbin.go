@@ -0,0 +1,29 @@
+package intercepter
+
+import (
+	"night-fury/dashboard/api"
+	"night-fury/pkgs/casbin"
+	"night-fury/pkgs/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MiddleWareCasbin 按 (用户, 租户, 路径, 方法) 鉴权，需要放在 MiddleWareAuth 之后
+func MiddleWareCasbin(c *gin.Context) {
+	u := api.GetSessUser(c)
+	if u == nil {
+		c.AbortWithStatus(403)
+		return
+	}
+
+	ok, err := casbin.Enforce(u.TenantID, u.ID, c.Request.URL.Path, c.Request.Method)
+	if err != nil {
+		log.WithContext(c.Request.Context()).WithTag(log.TagServer).Errorf("casbin enforce error : %s", err)
+		api.Fail(c, 500, api.NewMeta(api.CODE_ERR_INTERNAL, "enforce error"))
+		return
+	}
+	if !ok {
+		api.Fail(c, 403, api.NewMeta(api.CODE_ERR_NOTPERMIT, "permission denied"))
+		return
+	}
+}
@@ -1,18 +1,30 @@
 package intercepter
 
 import (
-	"os"
+	"net/http"
+	"net/url"
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/db"
+	"night-fury/pkgs/log"
+	"night-fury/pkgs/metrics"
+	"night-fury/pkgs/tracing"
+	"night-fury/pkgs/utils"
+	"strconv"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	jsoniter "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func MiddleWareCors() gin.HandlerFunc {
 	return cors.New(cors.Config{
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", log.RequestIDHeader, "traceparent", "tracestate"},
+		ExposeHeaders:    []string{log.RequestIDHeader},
 		AllowCredentials: true,
 		AllowOriginFunc: func(origin string) bool { // 所有
 			return true
@@ -21,15 +33,149 @@ func MiddleWareCors() gin.HandlerFunc {
 	})
 }
 
+// MiddleWareRequestID 从 X-Request-ID 读取 request id ，没有或不合法时生成，放到 context 和响应 header 中
+// 需要放在其他中间件之前，后续的日志、审计和 ws 消息都会带上 request id
+func MiddleWareRequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(log.RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = utils.GetID()
+		}
+
+		c.Request = c.Request.WithContext(log.WithRequestID(c.Request.Context(), requestID))
+		c.Header(log.RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// validRequestID 只接受 64 位以内的字母、数字和 - _ . ，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// MiddleWareTrace 为每个请求创建 span ，上游通过 traceparent header 传递上下文
+func MiddleWareTrace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "not_found"
+		}
+
+		ctx := tracing.ExtractHTTP(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.HTTPTarget(c.Request.URL.Path),
+				semconv.HTTPClientIP(c.ClientIP()),
+				attribute.String("request_id", log.RequestID(ctx)),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+var (
+	httpRequests = metrics.NewCounterVec("http", "requests_total", "http requests by route and status", "method", "route", "status")
+	httpLatency  = metrics.NewHistogramVec("http", "request_duration_seconds", "http request latency", nil, "method", "route")
+)
+
+// MiddleWareMetrics 请求数、状态码和耗时，按路由模板统计，未匹配的路由统一为 not_found
+func MiddleWareMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "not_found"
+		}
+		method := c.Request.Method
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpLatency.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MiddleWareLog 请求日志，5xx 为 error 级别，4xx 为 warn 级别
+// query 中的敏感参数（如 ws 连接的 token）会被脱敏
 func MiddleWareLog() gin.HandlerFunc {
-	if os.Getenv("ENV") == "local" { // text formatter
-		return gin.Logger()
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		if q := redactQuery(c.Request.URL.RawQuery); q != "" {
+			path += "?" + q
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := log.Params{
+			"method":    c.Request.Method,
+			"path":      path,
+			"status":    status,
+			"latency":   time.Since(start).String(),
+			"client_ip": c.ClientIP(),
+			"size":      c.Writer.Size(),
+		}
+		if len(c.Errors) > 0 {
+			fields["errors"] = c.Errors.String()
+		}
+
+		l := log.WithContext(c.Request.Context()).WithTag(log.TagServer).With(fields)
+		switch {
+		case status >= 500:
+			l.Error("request")
+		case status >= 400:
+			l.Warn("request")
+		default:
+			l.Info("request")
+		}
 	}
-	// json formatter
-	return gin.LoggerWithFormatter(jsonFormatLogger)
 }
 
-func jsonFormatLogger(params gin.LogFormatterParams) string {
-	res, _ := jsoniter.MarshalToString(params)
-	return res
+// redactQuery 把敏感参数的值替换为 config.RedactedValue ，解析失败时不记录 query
+func redactQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return ""
+	}
+	for k, vs := range values {
+		if config.IsSensitive(k) {
+			for i := range vs {
+				vs[i] = config.RedactedValue
+			}
+		}
+	}
+	return values.Encode()
+}
+
+// MiddleWareSticky 开启 db 的 "读自己的写" ，请求中发生写操作后，后续的读都走主库
+func MiddleWareSticky() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(db.WithSticky(c.Request.Context()))
+		c.Next()
+	}
 }
@@ -0,0 +1,42 @@
+package intercepter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/log"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactQuery(t *testing.T) {
+	assert.Equal(t, "", redactQuery(""))
+	assert.Equal(t, "", redactQuery("a=%zz"))
+
+	q, err := url.ParseQuery(redactQuery("token=eyJhbGciOi.xxx.yyy&room=r1&access_token=abc&api_key=k"))
+	assert.Nil(t, err)
+	assert.Equal(t, config.RedactedValue, q.Get("token"))
+	assert.Equal(t, config.RedactedValue, q.Get("access_token"))
+	assert.Equal(t, config.RedactedValue, q.Get("api_key"))
+	assert.Equal(t, "r1", q.Get("room"))
+}
+
+func TestMiddleWareLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	old := log.Default().SetHandlers(log.NewWriterHandler(buf, &log.JSONFormatter{}))
+	defer log.Default().SetHandlers(old...)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MiddleWareLog())
+	r.GET("/ws", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws?token=eyJhbGciOi.secret.sig&room=r1", nil))
+	assert.NotContains(t, buf.String(), "eyJhbGciOi")
+	assert.Contains(t, buf.String(), "room=r1")
+	assert.Contains(t, buf.String(), "token="+url.QueryEscape(config.RedactedValue))
+}
@@ -1,7 +1,13 @@
 package dashboard
 
 import (
+	"night-fury/dashboard/api/audit"
+	"night-fury/dashboard/api/health"
+	"night-fury/dashboard/api/job"
 	"night-fury/dashboard/api/session"
+	"night-fury/dashboard/api/system"
+	"night-fury/dashboard/intercepter"
+	"night-fury/pkgs/metrics"
 	wsserver "night-fury/ws_server"
 
 	"github.com/gin-gonic/gin"
@@ -17,21 +23,47 @@ import (
 // @contact.url https://mastergo.com
 // @contact.email huhailong@jwzg.com
 
+const (
+	apiPrefix = "/license/api/v1" // lisence 服务的路径
+	wsPath    = "/hiboss"
+)
+
 //@host 127.0.0.1:8088
 func loadRouter(engine *gin.Engine) {
 
+	// 存活检查，不检查依赖
 	engine.GET("/ping", func(c *gin.Context) {
 		c.String(200, "ok")
 	})
+	engine.GET("/healthz", health.Healthz)
+	engine.GET("/readyz", health.Readyz)
 	engine.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	if conf := metrics.GetConfig(); conf.Enabled {
+		engine.GET(conf.Path, gin.WrapH(metrics.Handler()))
+	}
 
-	apiGroup := engine.Group("/license/api/v1") // lisence 服务的路径
+	apiGroup := engine.Group(apiPrefix)
 
 	apiGroup.Group("/user").
 		POST("/signin", session.Signin)
 
+	apiGroup.Group("/audit", intercepter.MiddleWareAuth, intercepter.MiddleWareCasbin).
+		GET("", audit.List)
+
+	apiGroup.Group("/jobs", intercepter.MiddleWareAuth, intercepter.MiddleWareCasbin).
+		GET("", job.List).
+		GET("/stats", job.Stats).
+		GET("/:id", job.Get).
+		POST("/:id/retry", job.Retry).
+		POST("/:id/cancel", job.Cancel)
+
+	apiGroup.Group("/system", intercepter.MiddleWareAuth, intercepter.MiddleWareCasbin).
+		GET("/config", system.Config).
+		GET("/log/levels", system.GetLogLevels).
+		PUT("/log/levels", system.SetLogLevel)
+
 	// ws server
-	apiGroup.GET("/hiboss", func(c *gin.Context) {
+	apiGroup.GET(wsPath, func(c *gin.Context) {
 		wsserver.Serve(c, c.Writer, c.Request)
 	})
 }
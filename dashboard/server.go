@@ -1,34 +1,49 @@
 package dashboard
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
 	"night-fury/dashboard/intercepter"
+	"night-fury/pkgs/config"
 	"night-fury/pkgs/log"
-	"os"
+	"night-fury/pkgs/nacos"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"gitlab.lanhuapp.com/gopkgs/config"
+	"github.com/pkg/errors"
 )
 
 type Server struct {
 	engine *gin.Engine
+	srv    *http.Server
+
+	registry *nacos.Registry
+	instance *nacos.Instance
 }
 
 func NewServer() *Server {
-	var engine *gin.Engine
+	// gin 的调试信息和 panic 堆栈都输出到 log
+	gin.DefaultWriter = log.Writer(log.TagServer, log.LevelDebug)
+	gin.DefaultErrorWriter = log.Writer(log.TagServer, log.LevelError)
 
-	switch os.Getenv("ENV") {
-	case "local":
-		engine = gin.Default()
+	if config.Profile() == "local" {
 		gin.SetMode(gin.DebugMode)
-	default:
-		engine = gin.New()
-		engine.Use(gin.Recovery())
+	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	engine := gin.New()
+	engine.Use(gin.Recovery())
+
 	engine.Use(intercepter.MiddleWareCors())
+	engine.Use(intercepter.MiddleWareRequestID())
+	engine.Use(intercepter.MiddleWareTrace())
+	engine.Use(intercepter.MiddleWareMetrics())
 	engine.Use(intercepter.MiddleWareLog())
+	engine.Use(intercepter.MiddleWareSticky())
+	engine.Use(intercepter.MiddleWareAudit())
 
 	loadRouter(engine)
 
@@ -37,13 +52,58 @@ func NewServer() *Server {
 	}
 }
 
-func (s *Server) Serve() {
+// Start 监听端口并在后台处理请求，端口被占用等错误直接返回
+func (s *Server) Start() error {
 	host := config.GetString("server.host")
 	port := config.GetInt64("server.port")
 
 	addr := fmt.Sprintf("%s:%d", host, port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "listen http server")
+	}
+	s.srv = &http.Server{Handler: s.engine, ReadHeaderTimeout: time.Second * 10}
 
 	log.Infof(log.TagServer, "HTTP server listening on %s", addr)
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorf(log.TagServer, "HTTP server error : %s", err)
+		}
+	}()
+	return nil
+}
+
+// Stop 停止接收新的请求，等待处理中的请求完成， ws 连接需要单独关闭
+func (s *Server) Stop(ctx context.Context) error {
+	if s.srv == nil {
+		return nil
+	}
+	return s.srv.Shutdown(ctx)
+}
 
-	log.Fatalf(log.TagServer, "HTTP server start error : %s", s.engine.Run(addr))
+// Register 把当前实例注册到 nacos ，metadata 中带上 ws 的路径，ws_client 可以据此发现 ws server
+func (s *Server) Register(r *nacos.Registry, service string) error {
+	inst := &nacos.Instance{
+		Service: service,
+		Port:    uint64(config.GetInt64("server.port")),
+		Metadata: map[string]string{
+			nacos.MetaWSScheme: "ws",
+			nacos.MetaWSPath:   apiPrefix + wsPath,
+		},
+	}
+	if err := r.Register(inst); err != nil {
+		return err
+	}
+
+	s.registry, s.instance = r, inst
+	log.Infof(log.TagServer, "registered to nacos as %s %s", service, inst.Addr())
+	return nil
+}
+
+// Deregister 从 nacos 注销，需要在停止服务之前调用
+func (s *Server) Deregister() error {
+	if s.registry == nil {
+		return nil
+	}
+	return s.registry.Deregister(s.instance)
 }
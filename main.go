@@ -1,37 +1,87 @@
 package main
 
 import (
-	"night-fury/dashboard"
+	"context"
+	"night-fury/pkgs/app"
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/db"
 	"night-fury/pkgs/log"
+	"night-fury/pkgs/nacos"
 	"night-fury/pkgs/utils"
 
-	"net/http"
-	_ "net/http/pprof"
 	_ "night-fury/docs"
-
-	_ "gitlab.lanhuapp.com/gopkgs/config"
+	"os"
 )
 
 func init() {
 	// 设置最大进程数
 	utils.SetMaxProcs()
-
-	// 开启 pprof
-	go func() {
-		http.ListenAndServe(":6060", nil)
-	}()
 }
 
 func main() {
-	apiServer := dashboard.NewServer()
+	// nacos 的远程配置需要在读取其他配置之前加载
+	nacosConf, err := nacos.LoadConfig()
+	if err != nil {
+		log.Fatalf(log.TagInit, "load nacos config error : %s", err)
+	}
+	if nacosConf.Enabled() {
+		if err := nacos.Init(nacosConf); err != nil {
+			log.Fatalf(log.TagInit, "init nacos error : %s", err)
+		}
+		if nacosConf.DataID != "" {
+			source := nacos.NewConfigSource(nacos.GetConfigClient(), nacosConf.DataID, nacosConf.Group, nacosConf.Format)
+			if err := config.AddSource(source); err != nil {
+				log.Fatalf(log.TagInit, "load config from nacos error : %s", err)
+			}
+		}
+	}
+
+	// 远程配置中可能有日志配置，重新初始化
+	logConf, err := log.LoadConfig()
+	if err != nil {
+		log.Fatalf(log.TagInit, "load log config error : %s", err)
+	}
+	if err := log.Init(logConf); err != nil {
+		log.Fatalf(log.TagInit, "init log error : %s", err)
+	}
+
+	// 子命令按需初始化，如 encrypt 不需要连接 db
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatalf(log.TagInit, "run command error : %s", err)
+		}
+		return
+	}
 
-	go apiServer.Serve()
+	appConf, err := app.LoadConfig()
+	if err != nil {
+		log.Fatalf(log.TagInit, "load app config error : %s", err)
+	}
+	a := app.New(appConf)
+	if err := registerComponents(a, nacosConf); err != nil {
+		log.Fatalf(log.TagInit, "register components error : %s", err)
+	}
 
-	sig, err := utils.GraceShutdown([]func() error{
-		func() error { // 退出回调函数，例如 kafka 断联等
-			return nil
-		},
-	})
+	// 按依赖顺序启动，收到退出信号后按逆序关闭
+	sig, err := a.Run(context.Background())
+	if err != nil && sig == nil {
+		log.Fatalf(log.TagInit, "start error : %s", err)
+	}
+
+	log.Infof(log.TagInit, "server shutdown via signal: %v, err : %v", sig, err)
+	log.Close()
+}
+
+func initDB() {
+	if err := openDB(context.Background()); err != nil {
+		log.Fatalf(log.TagInit, "init db error : %s", err)
+	}
+}
 
-	log.Infof(log.TagInit, "server shutdown via signal: %v, err : %s", sig, err)
+func openDB(ctx context.Context) error {
+	dbConf, err := db.LoadConfig()
+	if err != nil {
+		return err
+	}
+	return db.Init(ctx, dbConf)
 }
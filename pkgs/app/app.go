@@ -0,0 +1,300 @@
+package app
+
+// 应用生命周期
+// 组件注册 Start 、 Stop ，按依赖顺序启动，没有依赖关系的组件按注册顺序启动，关闭时按启动的逆序
+//   a := app.New(conf)
+//   a.Register(app.Component{Name: "db", Start: ..., Stop: ...})
+//   a.Register(app.Component{Name: "http", DependsOn: []string{"db"}, Start: ..., Stop: ...})
+//   err := a.Run(ctx)  启动后等待退出信号，然后关闭
+// 启动失败时关闭已经启动的组件（包括启动超时的组件，等它的 Start 返回后再关闭），关闭时某个组件失败不影响其他组件，所有错误合并返回
+
+import (
+	"context"
+	"fmt"
+	"night-fury/pkgs/log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrComponentExist    = errors.New("component already exist")
+	ErrUnknownDependency = errors.New("unknown dependency")
+	ErrDependencyCycle   = errors.New("dependency cycle")
+	ErrAlreadyStarted    = errors.New("app already started")
+	ErrTimeout           = errors.New("timeout")
+)
+
+// Hook 启动或关闭，需要在 ctx 取消时返回
+// ctx 在启动完成后就会取消，后台运行的任务不能使用它，需要使用 context.Background()
+type Hook func(ctx context.Context) error
+
+// Component 应用中的组件， Start 、 Stop 都可以为空
+type Component struct {
+	Name      string
+	DependsOn []string // 在这些组件之后启动、之前关闭
+	Start     Hook
+	Stop      Hook
+
+	StartTimeout time.Duration // 为 0 时使用 Config.StartTimeout
+	StopTimeout  time.Duration // 为 0 时使用 Config.StopTimeout
+}
+
+// Errors 多个组件的错误
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is 任意一个错误匹配即可，用于 errors.Is
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// errOrNil 没有错误时返回 nil ，避免返回非 nil 的空 Errors
+func (e Errors) errOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// App 管理组件的启动和关闭
+type App struct {
+	conf Config
+
+	mu         sync.Mutex
+	components []*Component
+	started    []*Component                // 按启动顺序
+	pending    map[*Component]<-chan error // 启动超时、Start 还没有返回的组件
+	running    bool
+}
+
+func New(conf *Config) *App {
+	return &App{conf: *conf}
+}
+
+// Register 注册组件，需要在 Start 之前调用
+func (a *App) Register(c Component) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.running {
+		return ErrAlreadyStarted
+	}
+	for _, exist := range a.components {
+		if exist.Name == c.Name {
+			return errors.WithMessage(ErrComponentExist, fmt.Sprintf("name : %s", c.Name))
+		}
+	}
+	a.components = append(a.components, &c)
+	return nil
+}
+
+// Start 按依赖顺序启动所有组件，失败时关闭已经启动的组件
+func (a *App) Start(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.running {
+		return ErrAlreadyStarted
+	}
+	order, err := a.sort()
+	if err != nil {
+		return err
+	}
+	a.running = true
+
+	for _, c := range order {
+		if c.Start != nil {
+			start := time.Now()
+			if pending, err := run(ctx, c.Start, timeoutOf(c.StartTimeout, a.conf.StartTimeout)); err != nil {
+				err = errors.Wrapf(err, "start %s", c.Name)
+				// 超时后 Start 可能还在后台运行，也需要执行它的 Stop ，关闭前等待 Start 返回
+				if pending != nil {
+					a.started = append(a.started, c)
+					a.pending = map[*Component]<-chan error{c: pending}
+				}
+				if stopErr := a.stop(context.Background()); stopErr != nil {
+					return Errors{err, stopErr}
+				}
+				return err
+			}
+			log.Infof(log.TagInit, "%s started in %s", c.Name, time.Since(start))
+		}
+		a.started = append(a.started, c)
+	}
+	return nil
+}
+
+// Stop 按启动的逆序关闭已经启动的组件，返回所有组件的错误
+func (a *App) Stop(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stop(ctx)
+}
+
+func (a *App) stop(ctx context.Context) error {
+	var errs Errors
+	for i := len(a.started) - 1; i >= 0; i-- {
+		c := a.started[i]
+		if c.Stop == nil {
+			continue
+		}
+		timeout := timeoutOf(c.StopTimeout, a.conf.StopTimeout)
+		// Stop 不能和还在运行的 Start 并发执行
+		if pending, ok := a.pending[c]; ok {
+			if err := wait(ctx, pending, timeout); err != nil {
+				log.Errorf(log.TagInit, "stop %s error : wait start : %s", c.Name, err)
+				errs = append(errs, errors.Wrapf(err, "stop %s: wait start", c.Name))
+				continue
+			}
+		}
+		if _, err := run(ctx, c.Stop, timeout); err != nil {
+			log.Errorf(log.TagInit, "stop %s error : %s", c.Name, err)
+			errs = append(errs, errors.Wrapf(err, "stop %s", c.Name))
+			continue
+		}
+		log.Infof(log.TagInit, "%s stopped", c.Name)
+	}
+	a.started = nil
+	a.pending = nil
+	a.running = false
+	return errs.errOrNil()
+}
+
+// Run 启动后等待退出信号或 ctx 取消，然后关闭，返回收到的信号
+func (a *App) Run(ctx context.Context, sigs ...os.Signal) (os.Signal, error) {
+	if err := a.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+
+	var sig os.Signal
+	select {
+	case sig = <-ch:
+	case <-ctx.Done():
+	}
+	return sig, a.Stop(context.Background())
+}
+
+// sort 按依赖排序，没有依赖关系的组件保持注册顺序
+func (a *App) sort() ([]*Component, error) {
+	byName := make(map[string]*Component, len(a.components))
+	for _, c := range a.components {
+		byName[c.Name] = c
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(a.components))
+	order := make([]*Component, 0, len(a.components))
+
+	var visit func(c *Component, path []string) error
+	visit = func(c *Component, path []string) error {
+		switch state[c.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.WithMessage(ErrDependencyCycle, strings.Join(append(path, c.Name), " -> "))
+		}
+		state[c.Name] = visiting
+		for _, name := range c.DependsOn {
+			dep, ok := byName[name]
+			if !ok {
+				return errors.WithMessage(ErrUnknownDependency, fmt.Sprintf("%s depends on %s", c.Name, name))
+			}
+			if err := visit(dep, append(path, c.Name)); err != nil {
+				return err
+			}
+		}
+		state[c.Name] = visited
+		order = append(order, c)
+		return nil
+	}
+
+	for _, c := range a.components {
+		if err := visit(c, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func timeoutOf(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// run 执行 hook ，超时或 ctx 取消后不再等待 hook 返回，此时 pending 在 hook 返回后收到它的结果
+func run(ctx context.Context, hook Hook, timeout time.Duration) (pending <-chan error, err error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				done <- errors.Errorf("panic : %v", e)
+			}
+		}()
+		done <- hook(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return nil, err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return done, errors.WithMessage(ErrTimeout, timeout.String())
+		}
+		return done, ctx.Err()
+	}
+}
+
+// wait 等待 run 超时后仍在运行的 hook 返回
+func wait(ctx context.Context, pending <-chan error, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	select {
+	case <-pending:
+		return nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errors.WithMessage(ErrTimeout, timeout.String())
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) hook(name string, err error) Hook {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.calls = append(r.calls, name)
+		return err
+	}
+}
+
+func TestApp(t *testing.T) {
+	errCloseDB := errors.New("close db")
+	r := &recorder{}
+	a := New(&Config{StartTimeout: time.Second, StopTimeout: time.Second})
+	assert.Nil(t, a.Register(Component{Name: "http", DependsOn: []string{"db", "cache"}, Start: r.hook("start http", nil), Stop: r.hook("stop http", nil)}))
+	assert.Nil(t, a.Register(Component{Name: "db", Start: r.hook("start db", nil), Stop: r.hook("stop db", errCloseDB)}))
+	assert.Nil(t, a.Register(Component{Name: "cache", DependsOn: []string{"db"}, Start: r.hook("start cache", nil), Stop: r.hook("stop cache", errors.New("close cache"))}))
+	assert.Nil(t, a.Register(Component{Name: "metrics", Start: r.hook("start metrics", nil)}))
+	assert.ErrorIs(t, a.Register(Component{Name: "db"}), ErrComponentExist)
+
+	assert.Nil(t, a.Start(context.Background()))
+	assert.ErrorIs(t, a.Start(context.Background()), ErrAlreadyStarted)
+
+	// 关闭时所有组件都会执行，错误合并返回
+	err := a.Stop(context.Background())
+	assert.ErrorIs(t, err, errCloseDB)
+	assert.Len(t, err.(Errors), 2)
+	assert.Equal(t, "stop cache: close cache; stop db: close db", err.Error())
+	assert.Equal(t, []string{
+		"start db", "start cache", "start http", "start metrics",
+		"stop http", "stop cache", "stop db",
+	}, r.calls)
+}
+
+func TestAppStartFailed(t *testing.T) {
+	r := &recorder{}
+	a := New(&Config{StartTimeout: time.Millisecond * 50})
+	assert.Nil(t, a.Register(Component{Name: "db", Start: r.hook("start db", nil), Stop: r.hook("stop db", nil)}))
+	startKafka := r.hook("start kafka returned", nil)
+	assert.Nil(t, a.Register(Component{Name: "kafka", Start: func(ctx context.Context) error {
+		// 不响应 ctx 也会超时
+		time.Sleep(time.Millisecond * 200)
+		return startKafka(ctx)
+	}, Stop: r.hook("stop kafka", nil)}))
+	assert.Nil(t, a.Register(Component{Name: "http", Start: r.hook("start http", nil)}))
+
+	err := a.Start(context.Background())
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Contains(t, err.Error(), "start kafka")
+	// 关闭已经启动的组件，超时的组件可能还在后台启动，等它启动完成后关闭
+	assert.Equal(t, []string{"start db", "start kafka returned", "stop kafka", "stop db"}, r.calls)
+
+	// Start 一直不返回时，等待超时后不执行 Stop
+	r = &recorder{}
+	block := make(chan struct{})
+	defer close(block)
+	a = New(&Config{StartTimeout: time.Millisecond * 50, StopTimeout: time.Millisecond * 50})
+	assert.Nil(t, a.Register(Component{Name: "kafka", Start: func(ctx context.Context) error {
+		<-block
+		return nil
+	}, Stop: r.hook("stop kafka", nil)}))
+	err = a.Start(context.Background())
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Contains(t, err.Error(), "stop kafka: wait start")
+	assert.Empty(t, r.calls)
+
+	// 依赖错误
+	a = New(&Config{})
+	assert.Nil(t, a.Register(Component{Name: "a", DependsOn: []string{"b"}}))
+	assert.ErrorIs(t, a.Start(context.Background()), ErrUnknownDependency)
+	assert.Nil(t, a.Register(Component{Name: "b", DependsOn: []string{"a"}}))
+	err = a.Start(context.Background())
+	assert.ErrorIs(t, err, ErrDependencyCycle)
+	assert.Contains(t, err.Error(), "a -> b -> a")
+}
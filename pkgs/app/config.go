@@ -0,0 +1,21 @@
+package app
+
+import (
+	"night-fury/pkgs/config"
+	"time"
+)
+
+// Config 启动和关闭的超时，组件可以单独设置
+type Config struct {
+	StartTimeout time.Duration `mapstructure:"start_timeout" default:"30s"` // 单个组件启动的超时
+	StopTimeout  time.Duration `mapstructure:"stop_timeout" default:"15s"`  // 单个组件关闭的超时
+}
+
+// LoadConfig 读取 app 下的配置
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	if err := config.Unmarshal("app", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
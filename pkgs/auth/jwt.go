@@ -2,19 +2,62 @@ package auth
 
 import (
 	"errors"
+	"night-fury/pkgs/config"
+	"sync"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 )
 
-var ErrInvalidToken = errors.New("invalid token")
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrNoSecret     = errors.New("jwt secret not configured")
 
-var sk = []byte("FAnrKbNawqhX3pTpC9FKUsm4hYXpVsHfRddtTuAkn4CYimAp94zwapbxzvFvvEVw")
+	sk   []byte
+	skMu sync.RWMutex
+)
+
+// Config 鉴权配置
+type Config struct {
+	// JWTSecret 签名 token 的密钥，建议使用 ENC(...) 加密后写入配置
+	JWTSecret config.Secret `mapstructure:"jwt_secret" validate:"required,min=32"`
+}
+
+// LoadConfig 读取 auth 下的配置
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	if err := config.Unmarshal("auth", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Init 设置 jwt 密钥，需要在签发和校验 token 之前调用
+func Init(conf *Config) error {
+	if conf.JWTSecret == "" {
+		return ErrNoSecret
+	}
+
+	skMu.Lock()
+	defer skMu.Unlock()
+	sk = []byte(conf.JWTSecret.Value())
+	return nil
+}
+
+func secret() ([]byte, error) {
+	skMu.RLock()
+	defer skMu.RUnlock()
+	if len(sk) == 0 {
+		return nil, ErrNoSecret
+	}
+	return sk, nil
+}
 
 type JWTClaims struct {
-	ID    string `json:"IDs"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID       string `json:"IDs"`
+	TenantID string `json:"tid"` // 租户 id
+	Name     string `json:"name"`
+	Email    string `json:"email"`
 	jwt.StandardClaims
 }
 
@@ -30,19 +73,34 @@ func NewClaim() *JWTClaims {
 }
 
 func GenJwtToken(ID, name, email string) (string, error) {
+	return GenTenantJwtToken("", ID, name, email)
+}
+
+// GenTenantJwtToken 生成带有租户信息的 token
+func GenTenantJwtToken(tenantID, ID, name, email string) (string, error) {
 	c := NewClaim()
+	c.TenantID = tenantID
 	c.Name = name
 	c.Email = email
 	c.ID = ID
 
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
-	t, err := token.SignedString(sk)
-	return t, err
+	return token.SignedString(key)
 }
 
 func JwtTokenValidate(token string) (*JWTClaims, error) {
+	key, err := secret()
+	if err != nil {
+		return nil, err
+	}
+
 	jwtToken, err := jwt.ParseWithClaims(token, &JWTClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return sk, nil
+		return key, nil
 	})
 
 	if err != nil {
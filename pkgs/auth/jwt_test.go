@@ -8,6 +8,10 @@ import (
 )
 
 func TestJwt(t *testing.T) {
+	_, err := GenJwtToken("52341i7367", "longalong", "longalong@longalong.cn")
+	assert.Equal(t, ErrNoSecret, err)
+	assert.Nil(t, Init(&Config{JWTSecret: "FAnrKbNawqhX3pTpC9FKUsm4hYXpVsHf"}))
+
 	s, err := GenJwtToken("52341i7367", "longalong", "longalong@longalong.cn")
 	fmt.Printf("err : %s\n", err)
 
@@ -18,5 +22,11 @@ func TestJwt(t *testing.T) {
 	fmt.Printf("err : %s\n", err)
 
 	assert.Nil(t, err)
-	fmt.Printf("email : %s\n", e)
+	fmt.Printf("email : %s\n", e.Email)
+
+	s, err = GenTenantJwtToken("tenant1", "52341i7367", "longalong", "longalong@longalong.cn")
+	assert.Nil(t, err)
+	e, err = JwtTokenValidate(s)
+	assert.Nil(t, err)
+	assert.Equal(t, "tenant1", e.TenantID)
 }
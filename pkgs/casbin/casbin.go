@@ -1,5 +1,122 @@
 package casbin
 
-func init() {
+// 基于 casbin 的权限控制，使用带租户(domain)的 RBAC 模型
+// 请求为 (用户, 租户, 路径, 方法) ，角色只在被授予的租户内生效，保证权限不会跨租户
+// domain 为 * 的 policy 作为角色模板，对所有租户生效，但用户仍需在该租户内被授予角色
+//
+// policy 文件格式(csv)：
+//   p, admin, *, /license/api/v1/*, *
+//   p, auditor, tenant1, /license/api/v1/audit, GET
+//   g, userID, admin, tenant1
 
+import (
+	"sync"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	fileadapter "github.com/casbin/casbin/v2/persist/file-adapter"
+	"github.com/pkg/errors"
+)
+
+// AnyDomain 对所有租户生效的 domain
+const AnyDomain = "*"
+
+const modelText = `
+[request_definition]
+r = sub, dom, obj, act
+
+[policy_definition]
+p = sub, dom, obj, act
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub, r.dom) && (p.dom == r.dom || p.dom == "*") && keyMatch2(r.obj, p.obj) && (p.act == r.act || p.act == "*")
+`
+
+var (
+	ErrNotInit = errors.New("casbin not init")
+
+	enforcer *casbin.SyncedEnforcer
+	mu       sync.RWMutex
+)
+
+// Init 初始化，policyFile 为空时不加载 policy ，可以通过 AddPolicy 等方法添加
+func Init(policyFile string) error {
+	m, err := model.NewModelFromString(modelText)
+	if err != nil {
+		return errors.Wrap(err, "load casbin model")
+	}
+
+	var e *casbin.SyncedEnforcer
+	if policyFile != "" {
+		e, err = casbin.NewSyncedEnforcer(m, fileadapter.NewAdapter(policyFile))
+	} else {
+		e, err = casbin.NewSyncedEnforcer(m)
+	}
+	if err != nil {
+		return errors.Wrap(err, "new casbin enforcer")
+	}
+
+	mu.Lock()
+	enforcer = e
+	mu.Unlock()
+	return nil
+}
+
+func getEnforcer() (*casbin.SyncedEnforcer, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if enforcer == nil {
+		return nil, ErrNotInit
+	}
+	return enforcer, nil
+}
+
+// Enforce 判断用户在租户内是否有权限访问
+func Enforce(tenantID, sub, obj, act string) (bool, error) {
+	e, err := getEnforcer()
+	if err != nil {
+		return false, err
+	}
+	// 没有租户时不允许匹配到任何租户的权限
+	if tenantID == "" || tenantID == AnyDomain {
+		return false, nil
+	}
+	return e.Enforce(sub, tenantID, obj, act)
+}
+
+// AddPolicy 添加租户内角色的权限，tenantID 为 AnyDomain 时对所有租户生效
+func AddPolicy(role, tenantID, obj, act string) error {
+	e, err := getEnforcer()
+	if err != nil {
+		return err
+	}
+	_, err = e.AddPolicy(role, tenantID, obj, act)
+	return err
+}
+
+// AddRoleForUser 在租户内为用户授予角色
+func AddRoleForUser(user, role, tenantID string) error {
+	e, err := getEnforcer()
+	if err != nil {
+		return err
+	}
+	_, err = e.AddRoleForUserInDomain(user, role, tenantID)
+	return err
+}
+
+// DeleteRoleForUser 删除用户在租户内的角色
+func DeleteRoleForUser(user, role, tenantID string) error {
+	e, err := getEnforcer()
+	if err != nil {
+		return err
+	}
+	_, err = e.DeleteRoleForUserInDomain(user, role, tenantID)
+	return err
 }
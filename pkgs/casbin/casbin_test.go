@@ -0,0 +1,32 @@
+package casbin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforce(t *testing.T) {
+	assert.Nil(t, Init(""))
+
+	assert.Nil(t, AddPolicy("admin", AnyDomain, "/license/api/v1/*", "*"))
+	assert.Nil(t, AddPolicy("auditor", "tenant1", "/license/api/v1/audit", "GET"))
+	assert.Nil(t, AddRoleForUser("alice", "admin", "tenant1"))
+	assert.Nil(t, AddRoleForUser("bob", "auditor", "tenant1"))
+
+	ok, err := Enforce("tenant1", "alice", "/license/api/v1/audit", "GET")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// 角色只在授予的租户内生效
+	ok, _ = Enforce("tenant2", "alice", "/license/api/v1/audit", "GET")
+	assert.False(t, ok)
+
+	ok, _ = Enforce("tenant1", "bob", "/license/api/v1/audit", "GET")
+	assert.True(t, ok)
+	ok, _ = Enforce("tenant1", "bob", "/license/api/v1/audit", "DELETE")
+	assert.False(t, ok)
+
+	ok, _ = Enforce("", "alice", "/license/api/v1/audit", "GET")
+	assert.False(t, ok)
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// 结构体字段支持的 tag ，密码等敏感配置使用 Secret 类型：
+//   mapstructure:"name"  配置中的 key ，为空时使用小写的字段名
+//   default:"10s"        没有配置时使用的值
+//   validate:"required"  go-playground/validator 的校验规则
+//
+// time.Duration 支持 10s 、 1m 格式，[]string 支持逗号分隔的字符串
+
+var (
+	validate     = validator.New()
+	durationType = reflect.TypeOf(time.Duration(0))
+	secretType   = reflect.TypeOf(Secret(""))
+)
+
+// Unmarshal 把 key 下的配置绑定到结构体 out ，key 为空时绑定全部配置
+// 会依次处理 default tag 、环境变量和 validate tag
+func Unmarshal(key string, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("config: unmarshal %s into non struct pointer %T", key, out)
+	}
+
+	// 逐个字段读取，保证只通过环境变量配置的字段也能被绑定
+	mu.RLock()
+	input, err := collect(v, key, rv.Elem().Type())
+	mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           out,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			stringToListHook,
+		),
+	})
+	if err != nil {
+		return errors.Wrap(err, "config: create decoder")
+	}
+	if err = decoder.Decode(input); err != nil {
+		return errors.Wrapf(err, "config: decode %s", key)
+	}
+
+	if err = validate.Struct(out); err != nil {
+		return errors.Wrapf(err, "config: validate %s", key)
+	}
+	return nil
+}
+
+// collect 按结构体字段读取配置并解析引用，没有配置时使用 default tag
+func collect(g *viper.Viper, prefix string, t reflect.Type) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := fieldName(f)
+		if name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != durationType {
+			sub, err := collect(g, key, ft)
+			if err != nil {
+				return nil, err
+			}
+			m[name] = sub
+			continue
+		}
+
+		var val interface{}
+		if g.IsSet(key) {
+			val = g.Get(key)
+		} else if def, ok := f.Tag.Lookup("default"); ok {
+			val = def
+		} else {
+			continue
+		}
+
+		r, err := resolve(val)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "config: resolve %s", key)
+		}
+		// 直接写在配置中的 Secret 也需要在日志中脱敏
+		if s, ok := r.(string); ok && ft == secretType {
+			addSecretValue(s)
+		}
+		m[name] = r
+	}
+	return m, nil
+}
+
+func fieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("mapstructure"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// stringToListHook 把逗号分隔的字符串转为 []string ，忽略空项
+func stringToListHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to.Kind() != reflect.Slice || to.Elem().Kind() != reflect.String {
+		return data, nil
+	}
+
+	list := []string{}
+	for _, item := range strings.Split(data.(string), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
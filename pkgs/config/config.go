@@ -1,5 +1,200 @@
 package config
 
+// 基于 viper 的配置，按以下顺序加载，后面的覆盖前面的：
+//   1. 代码中的 SetDefault 和结构体的 default tag
+//   2. 配置目录下的 config.{yaml,yml,toml,json}
+//   3. 配置目录下与 ENV 同名的 profile ，如 ENV=production 时为 config.production.yaml
+//   4. 通过 AddSource 添加的远程配置，如 nacos
+//   5. 环境变量，key 转大写并把 . 替换为 _ ，如 database.host 对应 DATABASE_HOST
+//   6. 代码中的 Set
+//
+// 配置目录通过 CONFIG_DIR 环境变量指定，为空时依次查找 . 和 ./config
+// 包初始化时会自动加载一次，没有配置文件时只使用环境变量和默认值
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// 配置文件名(不含扩展名)
+const fileName = "config"
+
+// 支持的配置文件格式
+var extensions = []string{"yaml", "yml", "toml", "json"}
+
+var (
+	reloadMu  sync.Mutex
+	mu        sync.RWMutex
+	v         = newViper()
+	dirs      []string
+	files     []string
+	defaults  = map[string]interface{}{}
+	overrides = map[string]interface{}{}
+)
+
 func init() {
+	if err := Init(os.Getenv("CONFIG_DIR")); err != nil {
+		// log 依赖本包，这里不能使用 log
+		fmt.Fprintf(os.Stderr, "load config error : %s\n", err)
+	}
+}
+
+// Init 从 dir 加载配置，dir 为空时依次查找 . 和 ./config
+func Init(dir string) error {
+	mu.Lock()
+	if dir != "" {
+		dirs = []string{dir}
+	} else {
+		dirs = []string{".", "config"}
+	}
+	mu.Unlock()
+
+	return Reload()
+}
+
+// Reload 重新读取所有配置文件，并通知配置发生变化的 OnChange 回调
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	mu.RLock()
+	searchDirs, srcs := dirs, append([]Source(nil), sources...)
+	mu.RUnlock()
+
+	nv := newViper()
+	loaded, err := readFiles(nv, searchDirs, Profile())
+	if err != nil {
+		return err
+	}
+	if err := readSources(nv, srcs); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	for k, val := range defaults {
+		nv.SetDefault(k, val)
+	}
+	for k, val := range overrides {
+		nv.Set(k, val)
+	}
+	old := v
+	v, files = nv, loaded
+	mu.Unlock()
+
+	notify(old, nv)
+	return nil
+}
+
+func newViper() *viper.Viper {
+	nv := viper.New()
+	nv.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	nv.AutomaticEnv()
+	return nv
+}
+
+// readFiles 依次读取基础配置和 profile 配置，返回读取的文件
+func readFiles(nv *viper.Viper, searchDirs []string, profile string) ([]string, error) {
+	base := findFile(searchDirs, fileName)
+	if base == "" {
+		return nil, nil
+	}
+	loaded := []string{base}
+
+	// profile 只在基础配置所在的目录查找
+	if profile != "" {
+		if f := findFile([]string{filepath.Dir(base)}, fileName+"."+profile); f != "" {
+			loaded = append(loaded, f)
+		}
+	}
+
+	for _, f := range loaded {
+		nv.SetConfigFile(f)
+		if err := nv.MergeInConfig(); err != nil {
+			return nil, errors.Wrapf(err, "read config file %s", f)
+		}
+	}
+	return loaded, nil
+}
+
+func findFile(searchDirs []string, name string) string {
+	for _, dir := range searchDirs {
+		for _, ext := range extensions {
+			f := filepath.Join(dir, name+"."+ext)
+			if info, err := os.Stat(f); err == nil && !info.IsDir() {
+				return f
+			}
+		}
+	}
+	return ""
+}
+
+// Profile 当前环境，取自 ENV 环境变量
+func Profile() string {
+	return os.Getenv("ENV")
+}
+
+// Files 已加载的配置文件，按加载顺序排列
+func Files() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]string(nil), files...)
+}
+
+// SetDefault 设置默认值，优先级最低
+func SetDefault(key string, value interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaults[key] = value
+	v.SetDefault(key, value)
+}
+
+// Set 设置配置，优先级最高，Reload 后仍然生效
+func Set(key string, value interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	overrides[key] = value
+	v.Set(key, value)
+}
+
+// Get 读取配置，ENC(...) 等引用会被解析为明文，解析失败时返回 nil
+func Get(key string) interface{} {
+	mu.RLock()
+	val := v.Get(key)
+	mu.RUnlock()
+
+	r, err := resolve(val)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve config %s error : %s\n", key, err)
+		return nil
+	}
+	return r
+}
+
+func GetString(key string) string                    { return cast.ToString(Get(key)) }
+func GetInt(key string) int                          { return cast.ToInt(Get(key)) }
+func GetInt64(key string) int64                      { return cast.ToInt64(Get(key)) }
+func GetBool(key string) bool                        { return cast.ToBool(Get(key)) }
+func GetFloat64(key string) float64                  { return cast.ToFloat64(Get(key)) }
+func GetDuration(key string) time.Duration           { return cast.ToDuration(Get(key)) }
+func GetStringSlice(key string) []string             { return cast.ToStringSlice(Get(key)) }
+func GetStringMap(key string) map[string]interface{} { return cast.ToStringMap(Get(key)) }
+
+func IsSet(key string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return v.IsSet(key)
+}
 
+func AllSettings() map[string]interface{} {
+	mu.RLock()
+	defer mu.RUnlock()
+	return v.AllSettings()
 }
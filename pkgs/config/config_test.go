@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	Host    string        `mapstructure:"host" validate:"required"`
+	Port    int           `mapstructure:"port" default:"8080"`
+	Timeout time.Duration `mapstructure:"timeout" default:"3s"`
+	Brokers []string      `mapstructure:"brokers"`
+	Pool    struct {
+		Size int `mapstructure:"size" default:"10" validate:"gte=1"`
+	} `mapstructure:"pool"`
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func initTestConfig(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("ENV", "test")
+	t.Cleanup(func() { Init(os.Getenv("CONFIG_DIR")) })
+
+	writeFile(t, dir, "config.yaml", "app:\n  host: base\n  port: 80\n  timeout: 1s\nlog_level: info\n")
+	writeFile(t, dir, "config.test.toml", "[app]\nhost = \"profile\"\n")
+	assert.Nil(t, Init(dir))
+	return dir
+}
+
+func TestLayers(t *testing.T) {
+	initTestConfig(t)
+	assert.Len(t, Files(), 2)
+
+	// profile 覆盖基础配置
+	assert.Equal(t, "profile", GetString("app.host"))
+	assert.Equal(t, 80, GetInt("app.port"))
+
+	// 环境变量覆盖配置文件
+	t.Setenv("APP_PORT", "81")
+	assert.Equal(t, 81, GetInt("app.port"))
+
+	Set("app.host", "override")
+	assert.Nil(t, Reload())
+	assert.Equal(t, "override", GetString("app.host"))
+	delete(overrides, "app.host")
+}
+
+func TestUnmarshal(t *testing.T) {
+	initTestConfig(t)
+	t.Setenv("APP_BROKERS", "a:9092, b:9092")
+	t.Setenv("APP_POOL_SIZE", "20")
+
+	c := &testConfig{}
+	assert.Nil(t, Unmarshal("app", c))
+	assert.Equal(t, "profile", c.Host)
+	assert.Equal(t, 80, c.Port)
+	assert.Equal(t, time.Second, c.Timeout)
+	assert.Equal(t, []string{"a:9092", "b:9092"}, c.Brokers)
+	assert.Equal(t, 20, c.Pool.Size)
+
+	// 没有配置时使用 default tag
+	c = &testConfig{}
+	t.Setenv("OTHER_HOST", "h")
+	assert.Nil(t, Unmarshal("other", c))
+	assert.Equal(t, 8080, c.Port)
+	assert.Equal(t, time.Second*3, c.Timeout)
+	assert.Equal(t, 10, c.Pool.Size)
+
+	// 校验失败
+	assert.NotNil(t, Unmarshal("missing", &testConfig{}))
+	t.Setenv("APP_TIMEOUT", "3x")
+	assert.NotNil(t, Unmarshal("app", &testConfig{}))
+}
+
+func TestWatch(t *testing.T) {
+	dir := initTestConfig(t)
+
+	changed := make(chan string, 10)
+	OnChange("app.port", func() { changed <- GetString("app.port") })
+	OnChange("log_level", func() { changed <- GetString("log_level") })
+	t.Cleanup(func() { callbacks = nil })
+
+	assert.Nil(t, Watch())
+	defer StopWatch()
+
+	writeFile(t, dir, "config.yaml", "app:\n  host: base\n  port: 90\n  timeout: 1s\nlog_level: info\n")
+	select {
+	case port := <-changed:
+		assert.Equal(t, "90", port)
+	case <-time.After(time.Second * 5):
+		t.Fatal("change callback not called")
+	}
+
+	// 没有变化的配置不回调
+	assert.Len(t, changed, 0)
+}
@@ -0,0 +1,388 @@
+package config
+
+// 配置中的敏感值支持以下写法，在读取时解析：
+//   ${env:DB_PASS}      读取环境变量
+//   ${file:/run/secret} 读取文件内容，去掉首尾空白
+//   ENC(AES:xxx)        AES 加密，密钥为 CONFIG_AES_KEY 环境变量(16/24/32 字节)，xxx 为 base64(iv + 密文)
+//   ENC(RSA:xxx)        RSA 加密，私钥为 CONFIG_RSA_KEY_FILE 环境变量指向的 pem 文件，xxx 为 hex 密文
+//   ENC(xxx)            同 ENC(AES:xxx)
+// 加密后的值可以通过 `night-fury encrypt` 子命令生成
+//
+// 解析出的值会被记录下来，日志中出现时替换为 ******
+// Redacted 返回的配置中，引用和敏感 key(password 、 secret 、 token 等)的值都会被替换
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"night-fury/pkgs/crypto"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// RedactedValue 敏感值的替代文本
+const RedactedValue = "******"
+
+// 短于该长度的值不会在日志中替换，避免误伤正常内容
+const minRedactLen = 6
+
+const (
+	AlgAES = "AES"
+	AlgRSA = "RSA"
+)
+
+var (
+	ErrNoSecretKey = errors.New("config: secret key not configured")
+
+	refPattern = regexp.MustCompile(`^\$\{(env|file):([^}]+)\}$`)
+
+	// 最后一段 key 等于或以 _ 加这些词结尾时视为敏感配置
+	sensitiveWords = []string{"password", "passwd", "pass", "pwd", "secret", "token", "key", "dsn", "credential"}
+
+	secretMu      sync.RWMutex
+	aesKey        []byte
+	rsaPrivateKey []byte
+	rsaPKCS8      bool
+	keysLoaded    bool
+	secretKeys    = map[string]bool{}   // MarkSecret 标记的 key
+	secretValues  = map[string]string{} // 解析出的明文 -> 引用，用于日志脱敏
+)
+
+// Secret 敏感配置，打印和序列化时输出 ******
+type Secret string
+
+// Value 明文
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return RedactedValue
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// MarkSecret 把 key 标记为敏感配置，Redacted 时会被替换，如包含密码的 dsn
+func MarkSecret(keys ...string) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	for _, k := range keys {
+		secretKeys[strings.ToLower(k)] = true
+	}
+}
+
+// SetAESKey 设置解密 ENC(AES:xxx) 的密钥，默认读取 CONFIG_AES_KEY 环境变量
+func SetAESKey(key []byte) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	aesKey = key
+}
+
+// SetRSAPrivateKey 设置解密 ENC(RSA:xxx) 的 pem 格式私钥，默认读取 CONFIG_RSA_KEY_FILE 指向的文件
+func SetRSAPrivateKey(pemData []byte) error {
+	der, pkcs8, err := decodePem(pemData)
+	if err != nil {
+		return err
+	}
+
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	rsaPrivateKey, rsaPKCS8 = der, pkcs8
+	return nil
+}
+
+// loadKeys 第一次解密时从环境变量读取密钥
+func loadKeys() error {
+	secretMu.Lock()
+	loaded := keysLoaded
+	keysLoaded = true
+	if aesKey == nil {
+		if k := os.Getenv("CONFIG_AES_KEY"); k != "" {
+			aesKey = []byte(k)
+		}
+	}
+	hasRSA := rsaPrivateKey != nil
+	secretMu.Unlock()
+
+	if loaded || hasRSA {
+		return nil
+	}
+	if f := os.Getenv("CONFIG_RSA_KEY_FILE"); f != "" {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return errors.Wrap(err, "config: read rsa key file")
+		}
+		return SetRSAPrivateKey(data)
+	}
+	return nil
+}
+
+func decodePem(data []byte) ([]byte, bool, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, false, errors.New("config: invalid pem data")
+	}
+	// PKCS1 为 RSA PRIVATE KEY / RSA PUBLIC KEY ，PKCS8 为 PRIVATE KEY / PUBLIC KEY
+	return block.Bytes, !strings.HasPrefix(block.Type, "RSA "), nil
+}
+
+// Encrypt 加密 value ，返回 ENC(...) 格式的配置值
+// AES 使用 SetAESKey 或 CONFIG_AES_KEY 的密钥，RSA 使用 pem 格式的公钥 publicKey
+func Encrypt(alg, value string, publicKey []byte) (string, error) {
+	switch strings.ToUpper(alg) {
+	case AlgAES:
+		if err := loadKeys(); err != nil {
+			return "", err
+		}
+		secretMu.RLock()
+		key := aesKey
+		secretMu.RUnlock()
+		if key == nil {
+			return "", errors.WithMessage(ErrNoSecretKey, "CONFIG_AES_KEY")
+		}
+
+		iv := make([]byte, 16)
+		if _, err := rand.Read(iv); err != nil {
+			return "", errors.Wrap(err, "config: generate iv")
+		}
+		cipherText, err := crypto.AESEncryptCBC([]byte(value), key, iv)
+		if err != nil {
+			return "", errors.Wrap(err, "config: aes encrypt")
+		}
+		return "ENC(" + AlgAES + ":" + base64.StdEncoding.EncodeToString(append(iv, cipherText...)) + ")", nil
+	case AlgRSA:
+		der, pkcs8, err := decodePem(publicKey)
+		if err != nil {
+			return "", err
+		}
+		cipherText, err := crypto.RsaEncrypt(value, der, pkcs8)
+		if err != nil {
+			return "", errors.Wrap(err, "config: rsa encrypt")
+		}
+		return "ENC(" + AlgRSA + ":" + cipherText + ")", nil
+	default:
+		return "", errors.Errorf("config: unknown encrypt algorithm %s", alg)
+	}
+}
+
+func decrypt(s string) (string, error) {
+	if err := loadKeys(); err != nil {
+		return "", err
+	}
+
+	alg, data := AlgAES, s
+	if i := strings.Index(s, ":"); i > 0 {
+		alg, data = strings.ToUpper(s[:i]), s[i+1:]
+	}
+
+	secretMu.RLock()
+	key, rsaKey, pkcs8 := aesKey, rsaPrivateKey, rsaPKCS8
+	secretMu.RUnlock()
+
+	switch alg {
+	case AlgAES:
+		if key == nil {
+			return "", errors.WithMessage(ErrNoSecretKey, "CONFIG_AES_KEY")
+		}
+		raw, err := base64.StdEncoding.DecodeString(data)
+		if err != nil || len(raw) <= 16 {
+			return "", errors.New("config: invalid aes encrypted value")
+		}
+		plain, err := crypto.AESDecryptCBC(raw[16:], key, raw[:16])
+		if err != nil {
+			return "", errors.Wrap(err, "config: aes decrypt")
+		}
+		return string(plain), nil
+	case AlgRSA:
+		if rsaKey == nil {
+			return "", errors.WithMessage(ErrNoSecretKey, "CONFIG_RSA_KEY_FILE")
+		}
+		plain, err := crypto.RsaDecrypt(data, rsaKey, pkcs8)
+		if err != nil {
+			return "", errors.Wrap(err, "config: rsa decrypt")
+		}
+		return plain, nil
+	default:
+		return "", errors.Errorf("config: unknown encrypt algorithm %s", alg)
+	}
+}
+
+// isReference 是否为 ENC(...) 或 ${env:...} 、 ${file:...}
+func isReference(s string) bool {
+	return (strings.HasPrefix(s, "ENC(") && strings.HasSuffix(s, ")")) || refPattern.MatchString(s)
+}
+
+// resolveString 解析引用，不是引用时原样返回
+func resolveString(s string) (string, error) {
+	var (
+		plain string
+		err   error
+	)
+	switch {
+	case strings.HasPrefix(s, "ENC(") && strings.HasSuffix(s, ")"):
+		plain, err = decrypt(s[4 : len(s)-1])
+	case refPattern.MatchString(s):
+		m := refPattern.FindStringSubmatch(s)
+		if m[1] == "env" {
+			var ok bool
+			if plain, ok = os.LookupEnv(m[2]); !ok {
+				err = errors.Errorf("config: env %s not set", m[2])
+			}
+		} else {
+			var data []byte
+			if data, err = os.ReadFile(m[2]); err == nil {
+				plain = strings.TrimSpace(string(data))
+			}
+			err = errors.Wrapf(err, "config: read secret file %s", m[2])
+		}
+	default:
+		return s, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	addSecretValue(plain)
+	return plain, nil
+}
+
+// resolve 解析配置值中的引用，支持嵌套的 map 和 slice
+func resolve(val interface{}) (interface{}, error) {
+	switch t := val.(type) {
+	case string:
+		return resolveString(t)
+	case []string:
+		list := make([]string, len(t))
+		for i, s := range t {
+			r, err := resolveString(s)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = r
+		}
+		return list, nil
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, item := range t {
+			r, err := resolve(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = r
+		}
+		return list, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			r, err := resolve(item)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = r
+		}
+		return m, nil
+	default:
+		return val, nil
+	}
+}
+
+func addSecretValue(plain string) {
+	if len(plain) < minRedactLen {
+		return
+	}
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	secretValues[plain] = RedactedValue
+}
+
+// Redact 把 s 中出现的敏感值替换为 ******
+func Redact(s string) string {
+	secretMu.RLock()
+	defer secretMu.RUnlock()
+	for plain, r := range secretValues {
+		if strings.Contains(s, plain) {
+			s = strings.ReplaceAll(s, plain, r)
+		}
+	}
+	return s
+}
+
+// IsSensitive key 是否为敏感配置
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+
+	secretMu.RLock()
+	marked := secretKeys[key]
+	secretMu.RUnlock()
+	if marked {
+		return true
+	}
+
+	last := key[strings.LastIndex(key, ".")+1:]
+	for _, w := range sensitiveWords {
+		if last == w || strings.HasSuffix(last, "_"+w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted 返回全部配置，敏感配置和引用替换为 ******
+func Redacted() map[string]interface{} {
+	return redactMap("", AllSettings())
+}
+
+func redactMap(prefix string, m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, val := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		out[k] = redactValue(key, val)
+	}
+	return out
+}
+
+func redactValue(key string, val interface{}) interface{} {
+	if sub, ok := val.(map[string]interface{}); ok {
+		return redactMap(key, sub)
+	}
+	if IsSensitive(key) {
+		return RedactedValue
+	}
+	switch t := val.(type) {
+	case string:
+		if isReference(t) {
+			return RedactedValue
+		}
+		return Redact(t)
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, item := range t {
+			list[i] = redactValue(key, item)
+		}
+		return list
+	case []string:
+		list := make([]interface{}, len(t))
+		for i, item := range t {
+			list[i] = redactValue(key, item)
+		}
+		return list
+	default:
+		return val
+	}
+}
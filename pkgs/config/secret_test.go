@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"night-fury/pkgs/crypto"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type secretConfig struct {
+	User     string `mapstructure:"user"`
+	Password Secret `mapstructure:"password" validate:"required"`
+	Token    Secret `mapstructure:"token"`
+}
+
+func TestSecretReference(t *testing.T) {
+	dir := initTestConfig(t)
+	t.Setenv("TEST_DB_PASS", "env-password")
+	writeFile(t, dir, "token", "file-token\n")
+	t.Setenv("DB_PASSWORD", "${env:TEST_DB_PASS}")
+	t.Setenv("DB_TOKEN", "${file:"+filepath.Join(dir, "token")+"}")
+
+	c := &secretConfig{}
+	assert.Nil(t, Unmarshal("db", c))
+	assert.Equal(t, "env-password", c.Password.Value())
+	assert.Equal(t, "file-token", c.Token.Value())
+
+	// 打印和序列化时不输出明文
+	assert.Equal(t, RedactedValue, fmt.Sprintf("%v", c.Password))
+	data, _ := json.Marshal(c)
+	assert.NotContains(t, string(data), "env-password")
+
+	t.Setenv("DB_PASSWORD", "${env:TEST_NOT_SET}")
+	assert.NotNil(t, Unmarshal("db", &secretConfig{}))
+}
+
+func TestEncrypt(t *testing.T) {
+	initTestConfig(t)
+	SetAESKey([]byte("0123456789abcdef"))
+	t.Cleanup(func() { SetAESKey(nil) })
+
+	enc, err := Encrypt(AlgAES, "aes-password", nil)
+	assert.Nil(t, err)
+	t.Setenv("DB_PASSWORD", enc)
+	assert.Equal(t, "aes-password", GetString("db.password"))
+
+	pub, priv := crypto.GenerateRsaKey(true)
+	assert.Nil(t, SetRSAPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priv})))
+	enc, err = Encrypt(AlgRSA, "rsa-password", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	assert.Nil(t, err)
+	t.Setenv("DB_PASSWORD", enc)
+	c := &secretConfig{}
+	assert.Nil(t, Unmarshal("db", c))
+	assert.Equal(t, "rsa-password", c.Password.Value())
+
+	t.Setenv("DB_PASSWORD", "ENC(AES:invalid)")
+	assert.NotNil(t, Unmarshal("db", &secretConfig{}))
+}
+
+func TestRedact(t *testing.T) {
+	initTestConfig(t)
+	t.Setenv("TEST_REDIS_PASS", "redis-password")
+	Set("redis.password", "${env:TEST_REDIS_PASS}")
+	Set("redis.addr", "127.0.0.1:6379")
+	Set("database.dsn", "root:dsn-password@tcp(127.0.0.1)/db")
+	t.Cleanup(func() {
+		delete(overrides, "redis.password")
+		delete(overrides, "redis.addr")
+		delete(overrides, "database.dsn")
+	})
+
+	assert.Equal(t, "redis-password", GetString("redis.password"))
+	assert.Equal(t, "auth with ****** failed", Redact("auth with redis-password failed"))
+
+	settings := Redacted()
+	assert.Equal(t, RedactedValue, settings["redis"].(map[string]interface{})["password"])
+	assert.Equal(t, "127.0.0.1:6379", settings["redis"].(map[string]interface{})["addr"])
+	assert.Equal(t, RedactedValue, settings["database"].(map[string]interface{})["dsn"])
+
+	assert.True(t, IsSensitive("auth.jwt_secret"))
+	assert.False(t, IsSensitive("app.host"))
+}
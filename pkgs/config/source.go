@@ -0,0 +1,67 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Source 远程配置源，如 nacos ，优先级高于配置文件，低于环境变量
+type Source interface {
+	Name() string
+	// Format 配置格式，yaml / toml / json
+	Format() string
+	Read() ([]byte, error)
+	// Watch 配置变化时调用 onChange
+	Watch(onChange func()) error
+}
+
+var sources []Source
+
+// AddSource 添加远程配置源，添加后立即 Reload ，并在配置源变化时自动 Reload
+func AddSource(s Source) error {
+	mu.Lock()
+	sources = append(sources, s)
+	mu.Unlock()
+
+	if err := Reload(); err != nil {
+		removeSource(s)
+		return err
+	}
+
+	err := s.Watch(func() {
+		if err := Reload(); err != nil {
+			fmt.Fprintf(os.Stderr, "reload config from %s error : %s\n", s.Name(), err)
+		}
+	})
+	return errors.Wrapf(err, "config: watch source %s", s.Name())
+}
+
+func removeSource(s Source) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range sources {
+		if sources[i] == s {
+			sources = append(sources[:i], sources[i+1:]...)
+			return
+		}
+	}
+}
+
+// readSources 依次合并远程配置源
+func readSources(nv *viper.Viper, list []Source) error {
+	for _, s := range list {
+		data, err := s.Read()
+		if err != nil {
+			return errors.Wrapf(err, "read config source %s", s.Name())
+		}
+		nv.SetConfigType(s.Format())
+		if err := nv.MergeConfig(bytes.NewReader(data)); err != nil {
+			return errors.Wrapf(err, "parse config source %s", s.Name())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// 编辑器保存文件时会产生多个事件，合并后只重新加载一次
+const reloadDelay = time.Millisecond * 100
+
+type callback struct {
+	key string
+	f   func()
+}
+
+var (
+	cbMu      sync.RWMutex
+	callbacks []callback
+
+	watchMu sync.Mutex
+	watcher *fsnotify.Watcher
+)
+
+// OnChange 注册配置变化的回调，key 为空时任意配置变化都会回调
+// 回调在 Reload 中同步执行，需要通过 Get 等方法读取新的配置
+func OnChange(key string, f func()) {
+	cbMu.Lock()
+	defer cbMu.Unlock()
+	callbacks = append(callbacks, callback{key: key, f: f})
+}
+
+func notify(old, nv *viper.Viper) {
+	cbMu.RLock()
+	cbs := append([]callback(nil), callbacks...)
+	cbMu.RUnlock()
+
+	for _, cb := range cbs {
+		if changed(old, nv, cb.key) {
+			cb.f()
+		}
+	}
+}
+
+func changed(old, nv *viper.Viper, key string) bool {
+	if key == "" {
+		return !reflect.DeepEqual(old.AllSettings(), nv.AllSettings())
+	}
+	return !reflect.DeepEqual(old.Get(key), nv.Get(key))
+}
+
+// Watch 监听配置目录，配置文件变化时自动 Reload
+func Watch() error {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if watcher != nil {
+		return nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "config: create watcher")
+	}
+
+	// 监听目录而不是文件，k8s configmap 等通过替换文件更新的场景也能生效
+	mu.RLock()
+	watchDirs := dirs
+	if len(files) > 0 {
+		watchDirs = []string{filepath.Dir(files[0])}
+	}
+	mu.RUnlock()
+
+	for _, dir := range watchDirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return errors.Wrapf(err, "config: watch %s", dir)
+		}
+	}
+
+	watcher = w
+	go watchLoop(w)
+	return nil
+}
+
+// StopWatch 停止监听
+func StopWatch() error {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if watcher == nil {
+		return nil
+	}
+	err := watcher.Close()
+	watcher = nil
+	return err
+}
+
+func watchLoop(w *fsnotify.Watcher) {
+	var timer *time.Timer
+	for {
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+			if !isConfigFile(e.Name) || e.Op == fsnotify.Chmod {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(reloadDelay, func() {
+				if err := Reload(); err != nil {
+					fmt.Fprintf(os.Stderr, "reload config error : %s\n", err)
+				}
+			})
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "watch config error : %s\n", err)
+		}
+	}
+}
+
+func isConfigFile(name string) bool {
+	base := filepath.Base(name)
+	if !strings.HasPrefix(base, fileName+".") {
+		return false
+	}
+	ext := strings.TrimPrefix(filepath.Ext(base), ".")
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
@@ -265,6 +265,9 @@ func RsaDecrypt(value string, privateKey []byte, usePKCS8 bool) (string, error)
 	}
 	key := keyInterface.(*rsa.PrivateKey)
 	buffer, err = rsa.DecryptPKCS1v15(rand.Reader, key, buffer)
+	if err != nil {
+		return "", err
+	}
 	return string(buffer), nil
 }
 
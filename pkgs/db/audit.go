@@ -0,0 +1,384 @@
+package db
+
+// 审计日志
+// 通过 gorm callback 记录 创建/更新/删除 操作，包括操作人、资源、变更前后的数据以及 request id
+// 只有 context 中带有审计信息(见 WithAudit)的操作才会被记录，dashboard 已通过中间件开启
+// 审计记录与业务变更在同一个事务中写入
+// 带有 audit:"-" tag 的字段不记录，敏感字段(见 config.IsSensitive ，例如 password)只记录为 config.RedactedValue
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/log"
+	"night-fury/pkgs/utils"
+
+	"github.com/gogf/gf/util/gconv"
+	jsoniter "github.com/json-iterator/go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+
+	auditBeforeKey = "night-fury:audit_before"
+	auditMaxRows   = 100 // 单次操作最多记录的行数
+)
+
+// AuditEvent 审计记录
+type AuditEvent struct {
+	ID         string    `gorm:"primarykey" json:"id"`
+	TenantID   string    `gorm:"type:varchar(64);index" json:"tenantID"`
+	CreatedAt  time.Time `gorm:"index" json:"createdAt"`
+	ActorID    string    `gorm:"type:varchar(64);index" json:"actorID"`
+	ActorName  string    `gorm:"type:varchar(200)" json:"actorName"`
+	Action     string    `gorm:"type:varchar(20);index" json:"action"`
+	Resource   string    `gorm:"type:varchar(100);index:idx_audit_resource" json:"resource"`
+	ResourceID string    `gorm:"type:varchar(64);index:idx_audit_resource" json:"resourceID"`
+	Before     string    `gorm:"type:text" json:"before"`
+	After      string    `gorm:"type:text" json:"after"`
+	Changes    string    `gorm:"type:text" json:"changes"` // 更新时变化的字段 {"col": {"from": x, "to": y}}
+	RequestID  string    `gorm:"type:varchar(64);index" json:"requestID"`
+	IP         string    `gorm:"type:varchar(64)" json:"ip"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// AuditEvents 审计记录的 repository
+var AuditEvents = NewRepository[AuditEvent](RepoConfig{
+	SortFields:  []string{"created_at"},
+	DefaultSort: SortCond{Field: "created_at", Sort: "desc"},
+})
+
+// AuditInfo 审计相关的上下文信息
+type AuditInfo struct {
+	ActorID   string
+	ActorName string
+	RequestID string
+	IP        string
+}
+
+type auditKey struct{}
+
+// WithAudit 开启审计，getter 在写审计记录时调用，所以可以延迟获取登录用户等信息
+func WithAudit(ctx context.Context, getter func() *AuditInfo) context.Context {
+	return context.WithValue(ctx, auditKey{}, getter)
+}
+
+func auditInfo(ctx context.Context) *AuditInfo {
+	if ctx == nil {
+		return nil
+	}
+	getter, ok := ctx.Value(auditKey{}).(func() *AuditInfo)
+	if !ok || getter == nil {
+		return nil
+	}
+	return getter()
+}
+
+var (
+	auditSkipTables = map[string]bool{
+		"audit_events":      true,
+		"schema_migrations": true,
+	}
+	auditSkipMu sync.RWMutex
+)
+
+// SkipAudit 不需要审计的表
+func SkipAudit(tables ...string) {
+	auditSkipMu.Lock()
+	defer auditSkipMu.Unlock()
+
+	for _, t := range tables {
+		auditSkipTables[t] = true
+	}
+}
+
+func shouldAudit(tx *gorm.DB) bool {
+	if tx.Error != nil || tx.Statement.Table == "" || tx.Statement.Schema == nil {
+		return false
+	}
+	auditSkipMu.RLock()
+	skip := auditSkipTables[tx.Statement.Table]
+	auditSkipMu.RUnlock()
+
+	return !skip && auditInfo(tx.Statement.Context) != nil
+}
+
+func registerAuditCallbacks(gdb *gorm.DB) error {
+	cb := gdb.Callback()
+	if err := cb.Create().After("gorm:create").Register("night-fury:audit_create", auditAfterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("night-fury:audit_before_update", auditBefore); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("night-fury:audit_update", auditAfterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("night-fury:audit_before_delete", auditBefore); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("night-fury:audit_delete", auditAfterDelete)
+}
+
+// newSession 在同一个连接(事务)中执行的干净会话
+func newSession(tx *gorm.DB) *gorm.DB {
+	return tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Clauses(dbresolver.Write)
+}
+
+type auditRow = map[string]interface{}
+
+func auditBefore(tx *gorm.DB) {
+	if !shouldAudit(tx) {
+		return
+	}
+	rows, ok := loadAffectedRows(tx)
+	if ok {
+		tx.InstanceSet(auditBeforeKey, rows)
+	}
+}
+
+// loadAffectedRows 根据 where 条件和 model 的主键查询将要变更的数据，没有条件时不查询
+func loadAffectedRows(tx *gorm.DB) ([]auditRow, bool) {
+	stmt := tx.Statement
+	q := newSession(tx).Table(stmt.Table)
+	hasCond := false
+
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			q = q.Clauses(where)
+			hasCond = true
+		}
+	}
+	if pk := stmt.Schema.PrioritizedPrimaryField; pk != nil && stmt.ReflectValue.Kind() == reflect.Struct {
+		if v, zero := pk.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+			q = q.Where(clause.Eq{Column: clause.Column{Name: pk.DBName}, Value: v})
+			hasCond = true
+		}
+	}
+	if !hasCond {
+		return nil, false
+	}
+
+	var rows []auditRow
+	if err := q.Limit(auditMaxRows).Find(&rows).Error; err != nil {
+		log.Warnf(log.TagDB, "audit load rows of %s error : %s", stmt.Table, err)
+		return nil, false
+	}
+	return rows, true
+}
+
+func auditAfterCreate(tx *gorm.DB) {
+	if !shouldAudit(tx) {
+		return
+	}
+	stmt := tx.Statement
+
+	var rows []auditRow
+	appendRow := func(rv reflect.Value) {
+		row := auditRow{}
+		for _, f := range stmt.Schema.Fields {
+			if f.DBName == "" {
+				continue
+			}
+			v, _ := f.ValueOf(stmt.Context, rv)
+			row[f.DBName] = v
+		}
+		rows = append(rows, row)
+	}
+
+	switch rv := reflect.Indirect(stmt.ReflectValue); rv.Kind() {
+	case reflect.Struct:
+		appendRow(rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len() && i < auditMaxRows; i++ {
+			appendRow(reflect.Indirect(rv.Index(i)))
+		}
+	}
+
+	events := make([]*AuditEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, newAuditEvent(tx, AuditActionCreate, nil, row))
+	}
+	saveAuditEvents(tx, events)
+}
+
+func auditAfterUpdate(tx *gorm.DB) {
+	if !shouldAudit(tx) {
+		return
+	}
+	before, ok := tx.InstanceGet(auditBeforeKey)
+	if !ok {
+		return
+	}
+	beforeRows := before.([]auditRow)
+	pk := primaryKeyName(tx)
+
+	// 根据主键重新查询变更后的数据
+	afterRows := map[string]auditRow{}
+	if pk != "" && len(beforeRows) > 0 {
+		ids := make([]interface{}, 0, len(beforeRows))
+		for _, row := range beforeRows {
+			ids = append(ids, row[pk])
+		}
+		var rows []auditRow
+		err := newSession(tx).Table(tx.Statement.Table).Where(clause.IN{Column: clause.Column{Name: pk}, Values: ids}).Find(&rows).Error
+		if err != nil {
+			log.Warnf(log.TagDB, "audit load rows of %s error : %s", tx.Statement.Table, err)
+		}
+		for _, row := range rows {
+			afterRows[gconv.String(row[pk])] = row
+		}
+	}
+
+	events := make([]*AuditEvent, 0, len(beforeRows))
+	for _, row := range beforeRows {
+		events = append(events, newAuditEvent(tx, AuditActionUpdate, row, afterRows[gconv.String(row[pk])]))
+	}
+	saveAuditEvents(tx, events)
+}
+
+func auditAfterDelete(tx *gorm.DB) {
+	if !shouldAudit(tx) {
+		return
+	}
+	before, ok := tx.InstanceGet(auditBeforeKey)
+	if !ok {
+		return
+	}
+
+	beforeRows := before.([]auditRow)
+	events := make([]*AuditEvent, 0, len(beforeRows))
+	for _, row := range beforeRows {
+		events = append(events, newAuditEvent(tx, AuditActionDelete, row, nil))
+	}
+	saveAuditEvents(tx, events)
+}
+
+func primaryKeyName(tx *gorm.DB) string {
+	if pk := tx.Statement.Schema.PrioritizedPrimaryField; pk != nil {
+		return pk.DBName
+	}
+	return ""
+}
+
+func newAuditEvent(tx *gorm.DB, action string, before, after auditRow) *AuditEvent {
+	info := auditInfo(tx.Statement.Context)
+	e := &AuditEvent{
+		ID:        utils.GetID(),
+		ActorID:   info.ActorID,
+		ActorName: info.ActorName,
+		Action:    action,
+		Resource:  tx.Statement.Table,
+		RequestID: info.RequestID,
+		IP:        info.IP,
+	}
+
+	pk := primaryKeyName(tx)
+	for _, row := range []auditRow{after, before} {
+		if row != nil && row[pk] != nil {
+			e.ResourceID = gconv.String(row[pk])
+			break
+		}
+	}
+	if before != nil && after != nil {
+		if changes := diffRows(before, after); len(changes) > 0 {
+			for k := range changes {
+				switch auditColumnMode(tx, k) {
+				case auditOmit:
+					delete(changes, k)
+				case auditMask:
+					changes[k] = auditChange{From: config.RedactedValue, To: config.RedactedValue}
+				}
+			}
+			if len(changes) > 0 {
+				e.Changes, _ = auditJSON.MarshalToString(changes)
+			}
+		}
+	}
+	if before != nil {
+		e.Before, _ = auditJSON.MarshalToString(redactRow(tx, before))
+	}
+	if after != nil {
+		e.After, _ = auditJSON.MarshalToString(redactRow(tx, after))
+	}
+	return e
+}
+
+const (
+	auditKeep = iota
+	auditOmit // 不记录
+	auditMask // 只记录为 config.RedactedValue
+)
+
+// auditColumnMode 列在审计记录中的处理方式，主键总是保留
+func auditColumnMode(tx *gorm.DB, column string) int {
+	if column == primaryKeyName(tx) {
+		return auditKeep
+	}
+	if f := tx.Statement.Schema.LookUpField(column); f != nil && f.Tag.Get("audit") == "-" {
+		return auditOmit
+	}
+	if config.IsSensitive(column) {
+		return auditMask
+	}
+	return auditKeep
+}
+
+// redactRow 返回去掉不记录的字段、脱敏敏感字段后的数据
+func redactRow(tx *gorm.DB, row auditRow) auditRow {
+	out := make(auditRow, len(row))
+	for k, v := range row {
+		switch auditColumnMode(tx, k) {
+		case auditOmit:
+		case auditMask:
+			out[k] = config.RedactedValue
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// auditJSON 按 key 排序序列化，同样的变更得到同样的结果
+var auditJSON = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// auditChange 字段的变化，使用结构体保证序列化后 from 在 to 之前
+type auditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// diffRows 对比变更前后的数据，以 json 序列化的结果作为比较依据，兼容不同 driver 返回的类型
+func diffRows(before, after auditRow) map[string]auditChange {
+	changes := map[string]auditChange{}
+	for k, to := range after {
+		from := before[k]
+		fromStr, _ := jsoniter.MarshalToString(from)
+		toStr, _ := jsoniter.MarshalToString(to)
+		if fromStr != toStr {
+			changes[k] = auditChange{From: from, To: to}
+		}
+	}
+	return changes
+}
+
+func saveAuditEvents(tx *gorm.DB, events []*AuditEvent) {
+	if len(events) == 0 {
+		return
+	}
+	if err := newSession(tx).Create(&events).Error; err != nil {
+		// 审计失败时整个操作失败，保证审计记录完整
+		tx.AddError(err)
+	}
+}
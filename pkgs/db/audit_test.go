@@ -0,0 +1,87 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	tenantCtx := initTestDB(t)
+	ctx := WithAudit(tenantCtx, func() *AuditInfo {
+		return &AuditInfo{ActorID: "actor", ActorName: "longalong", RequestID: "req1"}
+	})
+
+	u := &User{Name: "audit", Email: "audit@longalong.cn", Phone: "audit"}
+	u.ID = "audit"
+	assert.Nil(t, Users.Create(ctx, u))
+	assert.Nil(t, Users.UpdateFields(ctx, "audit", map[string]interface{}{"phone": "audit2", "name": "audit2"}))
+	assert.Nil(t, Users.Delete(ctx, "audit"))
+
+	// 没有审计信息的操作不记录
+	u2 := &User{Name: "noaudit", Email: "noaudit@longalong.cn", Phone: "noaudit"}
+	u2.ID = "noaudit"
+	assert.Nil(t, Users.Create(tenantCtx, u2))
+
+	res, err := AuditEvents.List(tenantCtx, ListQuery{
+		Filters: []Filter{Eq("request_id", "req1")},
+		Sorts:   []SortCond{{Field: "id", Sort: "asc"}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, res.Total)
+
+	actions := []string{}
+	for _, e := range res.Items {
+		assert.Equal(t, "actor", e.ActorID)
+		assert.Equal(t, "tenant1", e.TenantID)
+		assert.Equal(t, "users", e.Resource)
+		assert.Equal(t, "audit", e.ResourceID)
+		actions = append(actions, e.Action)
+	}
+	assert.Equal(t, []string{AuditActionCreate, AuditActionUpdate, AuditActionDelete}, actions)
+	// 字段按名称排序
+	assert.Regexp(t, `^\{"name":\{"from":"audit","to":"audit2"\},"phone":\{"from":"audit","to":"audit2"\},"updated_at":`, res.Items[1].Changes)
+}
+
+func TestAuditRedact(t *testing.T) {
+	tenantCtx := initTestDB(t)
+	ctx := WithAudit(tenantCtx, func() *AuditInfo {
+		return &AuditInfo{ActorID: "actor", RequestID: "redact"}
+	})
+
+	u := &User{Name: "redact", Email: "redact@longalong.cn", Phone: "redact", Password: "hash-v1"}
+	u.ID = "redact"
+	assert.Nil(t, Users.Create(ctx, u))
+	assert.Nil(t, Users.UpdateFields(ctx, "redact", map[string]interface{}{"password": "hash-v2"}))
+
+	// audit:"-" 的字段不记录
+	type Note struct {
+		Model
+		Title    string
+		Internal string `audit:"-"`
+	}
+	assert.Nil(t, GetDb().AutoMigrate(&Note{}))
+	n := &Note{Title: "note", Internal: "internal-v1"}
+	n.ID = "note"
+	assert.Nil(t, NewRepository[Note](RepoConfig{}).Create(ctx, n))
+
+	res, err := AuditEvents.List(tenantCtx, ListQuery{Filters: []Filter{Eq("request_id", "redact")}})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, res.Total)
+	for _, e := range res.Items {
+		for _, s := range []string{e.Before, e.After, e.Changes} {
+			assert.NotContains(t, s, "hash-v")
+			assert.NotContains(t, s, "internal-v1")
+			assert.NotContains(t, s, `"internal"`)
+		}
+		switch e.Resource {
+		case "users":
+			assert.Contains(t, e.After, `"password":"******"`)
+			if e.Action == AuditActionUpdate {
+				assert.Contains(t, e.Changes, `"password":{"from":"******","to":"******"}`)
+			}
+		case "notes":
+			assert.Contains(t, e.After, `"title":"note"`)
+		}
+	}
+}
@@ -2,91 +2,154 @@ package db
 
 // db 的 orm 使用 gorm v2 , 具体使用方法参考链接 :
 // https://www.kancloud.cn/sliver_horn/gorm/1861153
+//
+// 包初始化时不再连接数据库，需要在启动时显式调用 Init/Open ：
+//   conf, err := db.LoadConfig()
+//   if err := db.Init(ctx, conf); err != nil { ... }
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"night-fury/pkgs/log"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
-	"gitlab.lanhuapp.com/gopkgs/config"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 var (
-	connString string
-	db         *gorm.DB
-	Nil        = gorm.ErrRecordNotFound
+	db  *gorm.DB
+	Nil = gorm.ErrRecordNotFound
+
+	ErrNotInit = errors.New("db not init")
 )
 
 type Model struct {
-	ID        string     `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time  `json:"createdAt"`
-	UpdatedAt time.Time  `json:"updatedAt"`
-	DeletedAt *time.Time `sql:"index" json:"-"`
-}
-
-func init() {
-	initConnString()
-	initDB()
-	migrate()
+	ID        string         `gorm:"primarykey" json:"id"`
+	TenantID  string         `gorm:"type:varchar(64);index" json:"tenantID"` // 租户 id ，自动按租户隔离，见 tenant.go
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"` // 软删除，查询时自动过滤
 }
 
-func initConnString() {
-	host := config.GetString("database.host")
-	port := config.GetString("database.port")
-	user := config.GetString("database.user")
-	pass := config.GetString("database.pass")
-	name := config.GetString("database.name")
-	sslmode := config.GetString("database.sslmode")
-	connString = fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s", host, port, user, name, pass, sslmode)
-}
+// Open 根据配置打开一个新的连接，并检查连接是否可用
+func Open(ctx context.Context, conf *Config) (*gorm.DB, error) {
+	conf.setDefaults()
 
-func initDB() {
-	var err error
+	dialector, err := openDialector(conf, conf.DSN)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err = gorm.Open(postgres.Open(connString), &gorm.Config{})
+	// sql 为 debug 级别，慢查询和错误分别为 warn 、 error 级别
+	gdb, err := gorm.Open(dialector, &gorm.Config{
+		Logger: log.GormLogger(log.TagDB),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "open db with driver %s", conf.Driver)
+	}
 
+	sqlDB, err := gdb.DB()
 	if err != nil {
-		log.Fatalf(log.TagDB, "init db error : %s", err)
-		return
+		return nil, err
 	}
 
-	if os.Getenv("ENV") == "local" { // 开发环境调试
-		logger.Default.LogMode(logger.Info)
-	} else { // 生产环境静默
-		logger.Default.LogMode(logger.Silent)
+	// 设置连接参数
+	sqlDB.SetMaxIdleConns(conf.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(conf.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, errors.Wrapf(err, "ping db with driver %s", conf.Driver)
 	}
-	sqlDB, err := db.DB()
+
+	if err := useResolver(gdb, conf); err != nil {
+		sqlDB.Close()
+		return nil, errors.Wrap(err, "register db replicas")
+	}
+	if err := registerStickyCallbacks(gdb); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	if err := registerTenantCallbacks(gdb); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	if err := registerAuditCallbacks(gdb); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	if err := registerTracingCallbacks(gdb); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	return gdb, nil
+}
+
+// Init 打开连接并设置为全局 db
+func Init(ctx context.Context, conf *Config) error {
+	gdb, err := Open(ctx, conf)
 	if err != nil {
-		panic(errors.Wrap(err, "init db error"))
+		return err
 	}
+	SetDb(gdb)
 
-	// 设置连接参数
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(50)
-	sqlDB.SetConnMaxLifetime(time.Minute * 10)
+	log.Infof(log.TagDB, "db connected : %s", conf)
+	return nil
+}
+
+// SetDb 设置全局 db ，测试时可以设置为 sqlite 的实例
+func SetDb(gdb *gorm.DB) {
+	db = gdb
 }
 
-func migrate() {
-	err := db.AutoMigrate(&User{})
+// Close 关闭全局 db
+func Close() error {
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
-		panic(err)
+		return err
 	}
+	return sqlDB.Close()
 }
 
 func GetStats() sql.DBStats {
+	if db == nil {
+		return sql.DBStats{}
+	}
 	sqlDB, _ := db.DB()
 	return sqlDB.Stats()
 }
 
 func PingDB() error {
-	sqlDB, _ := db.DB()
-	return sqlDB.Ping()
+	return HealthCheck(context.Background())
+}
+
+// HealthCheck 检查 db 是否可用
+func HealthCheck(ctx context.Context) error {
+	if db == nil {
+		return ErrNotInit
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Dialect 当前 db 的方言名，例如 postgres、mysql、sqlite
+func Dialect() string {
+	if db == nil {
+		return ""
+	}
+	return db.Dialector.Name()
 }
 
 type SortCond struct {
@@ -94,10 +157,31 @@ type SortCond struct {
 	Sort  string
 }
 
+// Stringify 拼接成 order 语句，Field 和 Sort 会直接拼接进 sql ，使用前需要先 Validate
 func (c *SortCond) Stringify() string {
 	return fmt.Sprintf("%s %s", c.Field, c.Sort)
 }
 
+// Validate 校验排序字段是否在白名单中，以及排序方式是否为 asc/desc，防止 sql 注入
+func (c *SortCond) Validate(allowed ...string) error {
+	switch strings.ToLower(c.Sort) {
+	case "", "asc", "desc":
+	default:
+		return errors.WithMessage(ErrInvalidSort, c.Sort)
+	}
+
+	for _, f := range allowed {
+		if c.Field == f {
+			return nil
+		}
+	}
+	return errors.WithMessage(ErrInvalidSort, c.Field)
+}
+
+func (c *SortCond) isDesc() bool {
+	return strings.ToLower(c.Sort) == "desc"
+}
+
 func getDB(dbs ...*gorm.DB) *gorm.DB {
 	if len(dbs) > 0 {
 		return dbs[0]
@@ -108,17 +192,25 @@ func getDB(dbs ...*gorm.DB) *gorm.DB {
 func initTransaction() (*gorm.DB, func(*error), error) {
 	var dberr error
 
+	if db == nil {
+		return nil, nil, ErrNotInit
+	}
 	tx := db.Begin()
 	if dberr = tx.Error; dberr != nil {
 		return nil, nil, dberr
 	}
+	// finally 需要直接 defer 调用，才能 recover 住 panic
 	finally := func(err *error) {
-		if *err == nil {
-			err = &(tx.Commit().Error)
-		}
-		if *err != nil {
+		if r := recover(); r != nil {
 			tx.Rollback()
+			panic(r)
+		}
+
+		if *err == nil {
+			*err = tx.Commit().Error
+			return
 		}
+		tx.Rollback()
 	}
 	return tx, finally, nil
 }
@@ -128,7 +220,8 @@ func GetDb(dbs ...*gorm.DB) *gorm.DB {
 	return getDB(dbs...)
 }
 
-// InitTransaction 初始化事务
+// InitTransaction 初始化事务，获取后需要 defer finally(&err) ，根据 err 决定提交还是回滚
+// 新代码推荐使用 WithTx
 func InitTransaction() (*gorm.DB, func(*error), error) {
 	return initTransaction()
 }
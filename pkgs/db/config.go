@@ -0,0 +1,65 @@
+package db
+
+import (
+	"fmt"
+	"night-fury/pkgs/config"
+	"time"
+)
+
+// Config db 配置
+type Config struct {
+	Driver string `mapstructure:"driver" validate:"omitempty,oneof=postgres mysql sqlite"` // 默认 postgres
+	DSN    string `mapstructure:"dsn"`                                                     // 为空时根据下面的字段拼接
+
+	Host    string        `mapstructure:"host"`
+	Port    string        `mapstructure:"port"`
+	User    string        `mapstructure:"user"`
+	Pass    config.Secret `mapstructure:"pass"`
+	Name    string        `mapstructure:"name"`
+	SSLMode string        `mapstructure:"sslmode"`
+
+	// 只读副本的 DSN ，与主库使用相同的 driver ，为空时读写都走主库
+	Replicas []string `mapstructure:"replicas"`
+
+	// 连接池参数，主库和只读副本使用相同的配置
+	MaxIdleConns    int           `mapstructure:"max_idle_conns" validate:"gte=0"`
+	MaxOpenConns    int           `mapstructure:"max_open_conns" validate:"gte=0"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+}
+
+func init() {
+	// 副本的 dsn 中包含密码
+	config.MarkSecret("database.replicas")
+}
+
+// LoadConfig 读取 database 下的配置
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	if err := config.Unmarshal("database", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) setDefaults() {
+	if c.Driver == "" {
+		c.Driver = DriverPostgres
+	}
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = 10
+	}
+	if c.MaxOpenConns <= 0 {
+		c.MaxOpenConns = 50
+	}
+	if c.ConnMaxLifetime <= 0 {
+		c.ConnMaxLifetime = time.Minute * 10
+	}
+	if c.ConnMaxIdleTime <= 0 {
+		c.ConnMaxIdleTime = time.Minute * 5
+	}
+}
+
+func (c *Config) String() string {
+	return fmt.Sprintf("driver=%s host=%s port=%s user=%s dbname=%s replicas=%d", c.Driver, c.Host, c.Port, c.User, c.Name, len(c.Replicas))
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+)
+
+var ErrUnknownDriver = errors.New("unknown db driver")
+
+// Driver 根据配置创建 gorm 的 dialector ， dsn 不为空时直接使用 dsn
+type Driver func(conf *Config, dsn string) (gorm.Dialector, error)
+
+var (
+	drivers   = map[string]Driver{}
+	driversMu sync.RWMutex
+)
+
+// RegisterDriver 注册 driver ，同名会覆盖
+func RegisterDriver(name string, d Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	drivers[name] = d
+}
+
+func openDialector(conf *Config, dsn string) (gorm.Dialector, error) {
+	driversMu.RLock()
+	d, ok := drivers[conf.Driver]
+	driversMu.RUnlock()
+
+	if !ok {
+		return nil, errors.WithMessage(ErrUnknownDriver, conf.Driver)
+	}
+	return d(conf, dsn)
+}
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func init() {
+	RegisterDriver(DriverMySQL, func(conf *Config, dsn string) (gorm.Dialector, error) {
+		if dsn == "" {
+			dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+				conf.User, conf.Pass.Value(), conf.Host, conf.Port, conf.Name)
+		}
+		return mysql.Open(dsn), nil
+	})
+}
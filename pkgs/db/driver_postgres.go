@@ -0,0 +1,18 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func init() {
+	RegisterDriver(DriverPostgres, func(conf *Config, dsn string) (gorm.Dialector, error) {
+		if dsn == "" {
+			dsn = fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s",
+				conf.Host, conf.Port, conf.User, conf.Name, conf.Pass.Value(), conf.SSLMode)
+		}
+		return postgres.Open(dsn), nil
+	})
+}
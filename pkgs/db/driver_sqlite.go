@@ -0,0 +1,20 @@
+package db
+
+// sqlite 主要用于本地开发和单元测试，DSN 为文件路径，或者 file::memory:?cache=shared 使用内存数据库
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	RegisterDriver(DriverSQLite, func(conf *Config, dsn string) (gorm.Dialector, error) {
+		if dsn == "" {
+			dsn = conf.Name
+		}
+		if dsn == "" {
+			dsn = "file::memory:?cache=shared"
+		}
+		return sqlite.Open(dsn), nil
+	})
+}
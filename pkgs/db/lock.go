@@ -0,0 +1,122 @@
+package db
+
+// 基于 postgres advisory lock 的分布式锁
+// 锁与连接绑定，持有锁期间占用一个连接，连接断开时锁自动释放
+// fencing token 保存在 lock_fences 表中
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+	"night-fury/pkgs/lock"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrLockUnsupported = errors.New("advisory lock only supported by postgres")
+
+// AdvisoryLocker 基于 postgres advisory lock 的 lock.Locker
+type AdvisoryLocker struct{}
+
+// NewAdvisoryLocker 创建基于 postgres advisory lock 的 lock.Locker
+func NewAdvisoryLocker() *AdvisoryLocker {
+	return &AdvisoryLocker{}
+}
+
+// lockID 将 key 转换为 advisory lock 的 id
+func lockID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+// TryLock advisory lock 不会过期，ttl 只用于续期间隔
+func (l *AdvisoryLocker) TryLock(ctx context.Context, key string, _ time.Duration) (lock.Lease, error) {
+	if db == nil {
+		return nil, ErrNotInit
+	}
+	if Dialect() != DriverPostgres {
+		return nil, ErrLockUnsupported
+	}
+
+	sqlDB, err := GetDb().DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ok bool
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockID(key)).Scan(&ok); err != nil {
+		// 不确定是否已经拿到锁
+		discardConn(conn)
+		return nil, err
+	}
+	if !ok {
+		// 没有拿到锁，连接可以放回连接池
+		conn.Close()
+		return nil, lock.ErrNotAcquired
+	}
+
+	lease := &advisoryLease{conn: conn, key: key}
+	err = conn.QueryRowContext(ctx,
+		"INSERT INTO lock_fences (key, token) VALUES ($1, 1) ON CONFLICT (key) DO UPDATE SET token = lock_fences.token + 1 RETURNING token",
+		key).Scan(&lease.token)
+	if err != nil {
+		lease.Release(context.Background())
+		return nil, errors.Wrap(err, "incr lock fence")
+	}
+	return lease, nil
+}
+
+type advisoryLease struct {
+	conn  *sql.Conn
+	key   string
+	token int64
+}
+
+func (l *advisoryLease) Token() int64 {
+	return l.token
+}
+
+// Refresh 检查连接是否可用，连接断开时锁已经被释放
+func (l *advisoryLease) Refresh(ctx context.Context, _ time.Duration) error {
+	var held bool
+	err := l.conn.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_locks WHERE locktype = 'advisory' AND pid = pg_backend_pid() AND objid = ($1::bigint & x'ffffffff'::bigint)::oid)",
+		lockID(l.key)).Scan(&held)
+	if err != nil {
+		if errors.Is(err, sql.ErrConnDone) {
+			return lock.ErrLockLost
+		}
+		return err
+	}
+	if !held {
+		return lock.ErrLockLost
+	}
+	return nil
+}
+
+func (l *advisoryLease) Release(ctx context.Context) error {
+	var ok bool
+	if err := l.conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", lockID(l.key)).Scan(&ok); err != nil {
+		discardConn(l.conn)
+		return err
+	}
+	l.conn.Close()
+	if !ok {
+		return lock.ErrLockLost
+	}
+	return nil
+}
+
+// discardConn 关闭连接并且不放回连接池
+// advisory lock 是 session 级别的，unlock 失败时连接可能还持有锁，放回连接池后其他请求会在不知情的情况下一直持有它
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	conn.Close()
+}
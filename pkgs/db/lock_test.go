@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseFailedDiscardsConn(t *testing.T) {
+	// 不共享缓存的内存 sqlite ，每个连接是独立的库，可以用来区分连接
+	gdb, err := Open(context.Background(), &Config{Driver: DriverSQLite, DSN: "file:lock_discard?mode=memory", MaxOpenConns: 1})
+	assert.Nil(t, err)
+	sqlDB, err := gdb.DB()
+	assert.Nil(t, err)
+	defer sqlDB.Close()
+
+	ctx := context.Background()
+	hasMark := func() bool {
+		conn, err := sqlDB.Conn(ctx)
+		assert.Nil(t, err)
+		defer conn.Close()
+		var n int
+		assert.Nil(t, conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'mark'").Scan(&n))
+		return n == 1
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	assert.Nil(t, err)
+	_, err = conn.ExecContext(ctx, "CREATE TABLE mark (id int)")
+	assert.Nil(t, err)
+	conn.Close()
+	assert.True(t, hasMark(), "normal close returns the conn to the pool")
+
+	// sqlite 没有 pg_advisory_unlock ，unlock 失败，连接不能再被复用
+	conn, err = sqlDB.Conn(ctx)
+	assert.Nil(t, err)
+	lease := &advisoryLease{conn: conn, key: "k"}
+	assert.NotNil(t, lease.Release(ctx))
+	assert.False(t, hasMark())
+}
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"night-fury/pkgs/metrics"
+)
+
+// 连接池指标，采集时读取 GetStats ，未初始化时都为 0
+func init() {
+	gauge := func(name, help string, f func() float64) {
+		metrics.NewGaugeFunc("db", name, help, f)
+	}
+	counter := func(name, help string, f func() float64) {
+		metrics.NewCounterFunc("db", name, help, f)
+	}
+
+	gauge("max_open_connections", "max open connections of the pool", func() float64 { return float64(GetStats().MaxOpenConnections) })
+	gauge("open_connections", "established connections, in use and idle", func() float64 { return float64(GetStats().OpenConnections) })
+	gauge("in_use_connections", "connections in use", func() float64 { return float64(GetStats().InUse) })
+	gauge("idle_connections", "idle connections", func() float64 { return float64(GetStats().Idle) })
+	counter("wait_count_total", "connections waited for", func() float64 { return float64(GetStats().WaitCount) })
+	counter("wait_duration_seconds_total", "time blocked waiting for a connection", func() float64 { return GetStats().WaitDuration.Seconds() })
+	counter("max_idle_closed_total", "connections closed due to max idle conns", func() float64 { return float64(GetStats().MaxIdleClosed) })
+	counter("max_idle_time_closed_total", "connections closed due to max idle time", func() float64 { return float64(GetStats().MaxIdleTimeClosed) })
+	counter("max_lifetime_closed_total", "connections closed due to max lifetime", func() float64 { return float64(GetStats().MaxLifetimeClosed) })
+}
@@ -0,0 +1,317 @@
+package db
+
+// 版本化的数据库迁移，替代启动时的 AutoMigrate
+// 每个 Migration 有唯一的 Version ，已执行的版本记录在 schema_migrations 表中
+// 执行迁移时通过 advisory lock (postgres) / GET_LOCK (mysql) 保证同一时刻只有一个实例在迁移
+// 迁移只增不改: 已执行的迁移被修改(校验和不一致)，或新增了比已执行版本更小的迁移时，MigrateUp 报错
+// 通过命令行执行 : ./night-fury migrate up|down|status [steps]
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"night-fury/pkgs/log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// migrateLockKey advisory lock 的 key ，所有实例一致即可
+const migrateLockKey = 7_301_202_108
+
+var (
+	ErrMigrationExist   = errors.New("migration version already exist")
+	ErrMigrationChanged = errors.New("applied migration changed")
+	ErrMigrationGap     = errors.New("migration older than applied version")
+
+	migrations   []Migration
+	migrationsMu sync.Mutex
+
+	// migrateMu 保证同一进程内只有一个迁移在执行，跨实例由 advisory lock 保证
+	migrateMu sync.Mutex
+)
+
+// Migration 一次数据库变更，Up/Down 在同一个事务中执行
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+	// Checksum 迁移内容的校验和，为空时不校验，SQLMigration 自动计算
+	Checksum string
+}
+
+// MigrationState 迁移状态
+type MigrationState struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   int64  `gorm:"primarykey;autoIncrement:false"`
+	Name      string `gorm:"type:varchar(200)"`
+	Checksum  string `gorm:"type:varchar(64)"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// SQLMigration 使用原生 sql 的迁移
+func SQLMigration(version int64, name, up, down string) Migration {
+	sum := sha256.Sum256([]byte(up))
+	m := Migration{
+		Version: version,
+		Name:    name,
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec(up).Error
+		},
+		Checksum: hex.EncodeToString(sum[:]),
+	}
+	if down != "" {
+		m.Down = func(tx *gorm.DB) error {
+			return tx.Exec(down).Error
+		}
+	}
+	return m
+}
+
+// RegisterMigration 注册迁移，一般在 init 中调用
+func RegisterMigration(ms ...Migration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	for _, m := range ms {
+		for _, exist := range migrations {
+			if exist.Version == m.Version {
+				panic(errors.WithMessage(ErrMigrationExist, fmt.Sprintf("version : %d", m.Version)))
+			}
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+func registeredMigrations() []Migration {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	return append([]Migration{}, migrations...)
+}
+
+// MigrateUp 执行未执行的迁移，steps <= 0 时执行全部
+func MigrateUp(ctx context.Context, steps int) (applied []Migration, err error) {
+	err = withMigrateLock(ctx, func(tx *gorm.DB) error {
+		done, err := appliedVersions(tx)
+		if err != nil {
+			return err
+		}
+		ms := registeredMigrations()
+		if err := checkMigrations(ms, done); err != nil {
+			return err
+		}
+
+		for _, m := range ms {
+			if steps > 0 && len(applied) >= steps {
+				break
+			}
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+
+			log.Infof(log.TagDB, "migrate up : %d %s", m.Version, m.Name)
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, Checksum: m.Checksum, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return errors.Wrapf(err, "migrate up %d %s", m.Version, m.Name)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown 回滚最近执行的迁移，steps <= 0 时回滚一个
+func MigrateDown(ctx context.Context, steps int) (reverted []Migration, err error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	err = withMigrateLock(ctx, func(tx *gorm.DB) error {
+		done, err := appliedVersions(tx)
+		if err != nil {
+			return err
+		}
+
+		ms := registeredMigrations()
+		for i := len(ms) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := ms[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if m.Down == nil {
+				return errors.Errorf("migration %d %s can not be reverted", m.Version, m.Name)
+			}
+
+			log.Infof(log.TagDB, "migrate down : %d %s", m.Version, m.Name)
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, m.Version).Error
+			})
+			if err != nil {
+				return errors.Wrapf(err, "migrate down %d %s", m.Version, m.Name)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// MigrationStatus 获取所有迁移的执行状态
+func MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	if db == nil {
+		return nil, ErrNotInit
+	}
+	tx := Conn(WithPrimary(ctx))
+	if err := tx.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, err
+	}
+	done, err := appliedVersions(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := registeredMigrations()
+	states := make([]MigrationState, 0, len(ms))
+	for _, m := range ms {
+		state := MigrationState{Version: m.Version, Name: m.Name}
+		if sm, ok := done[m.Version]; ok {
+			state.Applied = true
+			state.AppliedAt = &sm.AppliedAt
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func appliedVersions(tx *gorm.DB) (map[int64]schemaMigration, error) {
+	var list []schemaMigration
+	if err := tx.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[int64]schemaMigration, len(list))
+	for _, sm := range list {
+		done[sm.Version] = sm
+	}
+	return done, nil
+}
+
+// checkMigrations 检查已执行的迁移是否被修改，以及是否有比已执行版本更小的未执行迁移
+func checkMigrations(ms []Migration, done map[int64]schemaMigration) error {
+	var latest int64
+	for v := range done {
+		if v > latest {
+			latest = v
+		}
+	}
+
+	for _, m := range ms {
+		sm, ok := done[m.Version]
+		if !ok {
+			if m.Version < latest {
+				return errors.WithMessage(ErrMigrationGap, fmt.Sprintf("version : %d, applied : %d", m.Version, latest))
+			}
+			continue
+		}
+		// 旧版本没有记录校验和
+		if m.Checksum != "" && sm.Checksum != "" && m.Checksum != sm.Checksum {
+			return errors.WithMessage(ErrMigrationChanged, fmt.Sprintf("version : %d", m.Version))
+		}
+	}
+	return nil
+}
+
+// withMigrateLock 在 advisory lock 中执行迁移，锁与连接绑定，所以需要单独占用一个连接
+func withMigrateLock(ctx context.Context, f func(tx *gorm.DB) error) (err error) {
+	if db == nil {
+		return ErrNotInit
+	}
+
+	migrateMu.Lock()
+	defer migrateMu.Unlock()
+
+	// sqlite 只有单进程访问，不需要 advisory lock
+	if Dialect() != DriverSQLite {
+		sqlDB, err := GetDb().DB()
+		if err != nil {
+			return err
+		}
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err = advisoryLock(ctx, conn); err != nil {
+			// 不确定是否已经拿到锁，不能放回连接池
+			discardConn(conn)
+			return errors.Wrap(err, "acquire migrate lock")
+		}
+		defer func() {
+			unlockErr := advisoryUnlock(conn)
+			if unlockErr == nil {
+				conn.Close()
+				return
+			}
+			discardConn(conn)
+			if err == nil {
+				err = errors.Wrap(unlockErr, "release migrate lock")
+			}
+		}()
+	}
+
+	tx := Conn(WithPrimary(ctx))
+	if err = tx.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+	return f(tx)
+}
+
+// advisoryLock 获取跨实例的锁
+func advisoryLock(ctx context.Context, conn *sql.Conn) error {
+	var err error
+	switch Dialect() {
+	case DriverPostgres:
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrateLockKey)
+	case DriverMySQL:
+		_, err = conn.ExecContext(ctx, "SELECT GET_LOCK(?, -1)", fmt.Sprint(migrateLockKey))
+	}
+	return err
+}
+
+func advisoryUnlock(conn *sql.Conn) error {
+	var err error
+	switch Dialect() {
+	case DriverPostgres:
+		_, err = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrateLockKey)
+	case DriverMySQL:
+		_, err = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", fmt.Sprint(migrateLockKey))
+	}
+	return err
+}
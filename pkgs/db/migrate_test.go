@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// useMigrations 测试期间替换已注册的迁移
+func useMigrations(t *testing.T, ms ...Migration) {
+	migrationsMu.Lock()
+	old := migrations
+	migrations = nil
+	migrationsMu.Unlock()
+
+	RegisterMigration(ms...)
+	t.Cleanup(func() {
+		migrationsMu.Lock()
+		migrations = old
+		migrationsMu.Unlock()
+	})
+}
+
+func initMigrateDB(t *testing.T) {
+	err := Init(context.Background(), &Config{
+		Driver: DriverSQLite,
+		DSN:    fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+	})
+	assert.Nil(t, err)
+}
+
+func versions(ms []Migration) []int64 {
+	vs := make([]int64, 0, len(ms))
+	for _, m := range ms {
+		vs = append(vs, m.Version)
+	}
+	return vs
+}
+
+func testMigrations() []Migration {
+	return []Migration{
+		SQLMigration(1, "create_a", "CREATE TABLE a (id INTEGER PRIMARY KEY)", "DROP TABLE a"),
+		{
+			Version: 2,
+			Name:    "create_b",
+			Up: func(tx *gorm.DB) error {
+				return tx.Exec("CREATE TABLE b (id INTEGER PRIMARY KEY)").Error
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Exec("DROP TABLE b").Error
+			},
+		},
+		SQLMigration(3, "alter_a", "ALTER TABLE a ADD COLUMN name TEXT", "ALTER TABLE a DROP COLUMN name"),
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	useMigrations(t, testMigrations()...)
+	initMigrateDB(t)
+
+	states, err := MigrationStatus(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, states, 3)
+	for _, s := range states {
+		assert.False(t, s.Applied)
+	}
+
+	applied, err := MigrateUp(ctx, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, versions(applied))
+	applied, err = MigrateUp(ctx, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{3}, versions(applied))
+	applied, err = MigrateUp(ctx, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, applied)
+
+	states, err = MigrationStatus(ctx)
+	assert.Nil(t, err)
+	for _, s := range states {
+		assert.True(t, s.Applied)
+		assert.NotNil(t, s.AppliedAt)
+	}
+	assert.True(t, GetDb().Migrator().HasColumn("a", "name"))
+
+	// 回滚后再执行
+	reverted, err := MigrateDown(ctx, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{3, 2}, versions(reverted))
+	assert.False(t, GetDb().Migrator().HasTable("b"))
+	assert.False(t, GetDb().Migrator().HasColumn("a", "name"))
+
+	states, err = MigrationStatus(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, false, false}, []bool{states[0].Applied, states[1].Applied, states[2].Applied})
+
+	applied, err = MigrateUp(ctx, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{2, 3}, versions(applied))
+	assert.True(t, GetDb().Migrator().HasTable("b"))
+	assert.True(t, GetDb().Migrator().HasColumn("a", "name"))
+
+	// 没有 Down 的迁移不能回滚
+	useMigrations(t, append(testMigrations(), SQLMigration(4, "create_c", "CREATE TABLE c (id INTEGER PRIMARY KEY)", ""))...)
+	_, err = MigrateUp(ctx, 0)
+	assert.Nil(t, err)
+	_, err = MigrateDown(ctx, 1)
+	assert.NotNil(t, err)
+	assert.True(t, GetDb().Migrator().HasTable("c"))
+}
+
+func TestMigrateCheck(t *testing.T) {
+	ctx := context.Background()
+	useMigrations(t, testMigrations()...)
+	initMigrateDB(t)
+
+	_, err := MigrateUp(ctx, 0)
+	assert.Nil(t, err)
+
+	// 已执行的迁移被修改
+	changed := testMigrations()
+	changed[0] = SQLMigration(1, "create_a", "CREATE TABLE a (id INTEGER PRIMARY KEY, name TEXT)", "DROP TABLE a")
+	useMigrations(t, changed...)
+	_, err = MigrateUp(ctx, 0)
+	assert.ErrorIs(t, err, ErrMigrationChanged)
+
+	// 新增的迁移版本比已执行的小
+	useMigrations(t, append(testMigrations(), SQLMigration(0, "create_d", "CREATE TABLE d (id INTEGER PRIMARY KEY)", "DROP TABLE d"))...)
+	applied, err := MigrateUp(ctx, 0)
+	assert.ErrorIs(t, err, ErrMigrationGap)
+	assert.Empty(t, applied)
+	assert.False(t, GetDb().Migrator().HasTable("d"))
+}
+
+func TestMigrateLock(t *testing.T) {
+	ctx := context.Background()
+
+	var runs int32
+	useMigrations(t, Migration{
+		Version: 1,
+		Name:    "count",
+		Up: func(tx *gorm.DB) error {
+			atomic.AddInt32(&runs, 1)
+			// 放大并发窗口
+			time.Sleep(50 * time.Millisecond)
+			return tx.Exec("CREATE TABLE a (id INTEGER PRIMARY KEY)").Error
+		},
+	})
+	initMigrateDB(t)
+
+	// 并发执行，迁移只会执行一次
+	var (
+		wg    sync.WaitGroup
+		total int32
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := MigrateUp(ctx, 0)
+			assert.Nil(t, err)
+			atomic.AddInt32(&total, int32(len(applied)))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), runs)
+	assert.Equal(t, int32(1), total)
+}
@@ -0,0 +1,221 @@
+package db
+
+// 所有的迁移都在这里注册，Version 使用 年月日序号 ，只增不改
+// 迁移中使用的 model 需要在迁移内部定义（冻结当时的结构），不要直接引用业务 model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	RegisterMigration(
+		Migration{
+			Version: 2021072001,
+			Name:    "create_users",
+			Up: func(tx *gorm.DB) error {
+				type User struct {
+					ID        string `gorm:"primarykey"`
+					CreatedAt time.Time
+					UpdatedAt time.Time
+					DeletedAt gorm.DeletedAt `gorm:"index"`
+					Name      string         `gorm:"type:varchar(200)"`
+					Email     string         `gorm:"type:varchar(200);uniqueIndex;"`
+					Password  string         `gorm:"type:varchar(200)"`
+					Phone     string         `gorm:"type:varchar(200);uniqueIndex;"`
+					Gender    string         `gorm:"type:varchar(20);default:male"`
+				}
+				// 兼容之前通过 AutoMigrate 创建的表
+				return tx.AutoMigrate(&User{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("users")
+			},
+		},
+		Migration{
+			Version: 2021072002,
+			Name:    "create_audit_events",
+			Up: func(tx *gorm.DB) error {
+				type AuditEvent struct {
+					ID         string    `gorm:"primarykey"`
+					CreatedAt  time.Time `gorm:"index"`
+					ActorID    string    `gorm:"type:varchar(64);index"`
+					ActorName  string    `gorm:"type:varchar(200)"`
+					Action     string    `gorm:"type:varchar(20);index"`
+					Resource   string    `gorm:"type:varchar(100);index:idx_audit_resource"`
+					ResourceID string    `gorm:"type:varchar(64);index:idx_audit_resource"`
+					Before     string    `gorm:"type:text"`
+					After      string    `gorm:"type:text"`
+					Changes    string    `gorm:"type:text"`
+					RequestID  string    `gorm:"type:varchar(64);index"`
+					IP         string    `gorm:"type:varchar(64)"`
+				}
+				return tx.Migrator().CreateTable(&AuditEvent{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("audit_events")
+			},
+		},
+		Migration{
+			Version: 2021072003,
+			Name:    "add_tenant_id",
+			Up: func(tx *gorm.DB) error {
+				type User struct {
+					TenantID string `gorm:"type:varchar(64);index"`
+				}
+				type AuditEvent struct {
+					TenantID string `gorm:"type:varchar(64);index"`
+				}
+				for _, model := range []interface{}{&User{}, &AuditEvent{}} {
+					if err := tx.Migrator().AddColumn(model, "TenantID"); err != nil {
+						return err
+					}
+					if err := tx.Migrator().CreateIndex(model, "TenantID"); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Down: func(tx *gorm.DB) error {
+				for _, table := range []string{"users", "audit_events"} {
+					if err := tx.Migrator().DropIndex(table, "idx_"+table+"_tenant_id"); err != nil {
+						return err
+					}
+					if err := tx.Migrator().DropColumn(table, "tenant_id"); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		Migration{
+			Version: 2021072004,
+			Name:    "create_outbox_events",
+			Up: func(tx *gorm.DB) error {
+				type OutboxEvent struct {
+					ID            int64  `gorm:"primarykey;autoIncrement"`
+					TenantID      string `gorm:"type:varchar(64);index"`
+					Topic         string `gorm:"type:varchar(200)"`
+					AggregateKey  string `gorm:"type:varchar(200);index:idx_outbox_key"`
+					Payload       []byte
+					Headers       string `gorm:"type:text"`
+					Status        string `gorm:"type:varchar(20);index:idx_outbox_status;index:idx_outbox_key"`
+					Attempts      int
+					LastError     string    `gorm:"type:text"`
+					NextAttemptAt time.Time `gorm:"index:idx_outbox_status"`
+					CreatedAt     time.Time
+					SentAt        *time.Time `gorm:"index"`
+				}
+				return tx.Migrator().CreateTable(&OutboxEvent{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("outbox_events")
+			},
+		},
+		Migration{
+			Version: 2021072005,
+			Name:    "create_lock_fences",
+			Up: func(tx *gorm.DB) error {
+				type LockFence struct {
+					Key   string `gorm:"type:varchar(200);primarykey"`
+					Token int64
+				}
+				return tx.Migrator().CreateTable(&LockFence{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("lock_fences")
+			},
+		},
+		Migration{
+			Version: 2021072006,
+			Name:    "create_jobs",
+			Up: func(tx *gorm.DB) error {
+				type Job struct {
+					ID          string  `gorm:"primarykey"`
+					TenantID    string  `gorm:"type:varchar(64);index"`
+					Queue       string  `gorm:"type:varchar(100);index:idx_jobs_fetch"`
+					Type        string  `gorm:"type:varchar(100);index"`
+					Payload     string  `gorm:"type:text"`
+					Status      string  `gorm:"type:varchar(20);index:idx_jobs_fetch"`
+					UniqueKey   *string `gorm:"type:varchar(200);uniqueIndex"`
+					Attempts    int
+					MaxAttempts int
+					LastError   string    `gorm:"type:text"`
+					RunAt       time.Time `gorm:"index:idx_jobs_fetch"`
+					LockedBy    string    `gorm:"type:varchar(100)"`
+					LockedUntil *time.Time
+					FinishedAt  *time.Time
+					CreatedAt   time.Time `gorm:"index"`
+					UpdatedAt   time.Time
+				}
+				return tx.Migrator().CreateTable(&Job{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("jobs")
+			},
+		},
+		Migration{
+			Version: 2021072007,
+			Name:    "users_unique_by_tenant",
+			Up: func(tx *gorm.DB) error {
+				type User struct {
+					TenantID string `gorm:"type:varchar(64);uniqueIndex:idx_users_tenant_email,priority:1;uniqueIndex:idx_users_tenant_phone,priority:1"`
+					Email    string `gorm:"type:varchar(200);uniqueIndex:idx_users_tenant_email,priority:2"`
+					Phone    string `gorm:"type:varchar(200);uniqueIndex:idx_users_tenant_phone,priority:2"`
+				}
+				for _, name := range []string{"idx_users_email", "idx_users_phone"} {
+					if err := tx.Migrator().DropIndex(&User{}, name); err != nil {
+						return err
+					}
+				}
+				for _, name := range []string{"idx_users_tenant_email", "idx_users_tenant_phone"} {
+					if err := tx.Migrator().CreateIndex(&User{}, name); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Down: func(tx *gorm.DB) error {
+				type User struct {
+					Email string `gorm:"type:varchar(200);uniqueIndex"`
+					Phone string `gorm:"type:varchar(200);uniqueIndex"`
+				}
+				for _, name := range []string{"idx_users_tenant_email", "idx_users_tenant_phone"} {
+					if err := tx.Migrator().DropIndex(&User{}, name); err != nil {
+						return err
+					}
+				}
+				for _, field := range []string{"Email", "Phone"} {
+					if err := tx.Migrator().CreateIndex(&User{}, field); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		Migration{
+			Version: 2021072008,
+			Name:    "jobs_unique_by_tenant",
+			Up: func(tx *gorm.DB) error {
+				type Job struct {
+					TenantID  string  `gorm:"type:varchar(64);uniqueIndex:idx_job_unique,priority:1"`
+					UniqueKey *string `gorm:"type:varchar(200);uniqueIndex:idx_job_unique,priority:2"`
+				}
+				if err := tx.Migrator().DropIndex(&Job{}, "idx_jobs_unique_key"); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateIndex(&Job{}, "idx_job_unique")
+			},
+			Down: func(tx *gorm.DB) error {
+				type Job struct {
+					UniqueKey *string `gorm:"type:varchar(200);uniqueIndex"`
+				}
+				if err := tx.Migrator().DropIndex(&Job{}, "idx_job_unique"); err != nil {
+					return err
+				}
+				return tx.Migrator().CreateIndex(&Job{}, "UniqueKey")
+			},
+		},
+	)
+}
@@ -0,0 +1,486 @@
+package db
+
+// 通用的 repository 层，封装常用的 增删改查 + 分页 + 过滤 + 排序
+// 使用方式 :
+//   var Users = NewRepository[User](RepoConfig{SortFields: []string{"created_at", "name"}})
+//   res, err := Users.List(ctx, ListQuery{PageNo: 1, PageSize: 20, Filters: []Filter{Eq("gender", "male")}})
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrInvalidField  = errors.New("invalid field")
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+const (
+	defaultPageSize    = 20
+	defaultMaxPageSize = 200
+)
+
+// Op 过滤操作符
+type Op string
+
+const (
+	OpEq      Op = "="
+	OpNe      Op = "<>"
+	OpGt      Op = ">"
+	OpGte     Op = ">="
+	OpLt      Op = "<"
+	OpLte     Op = "<="
+	OpIn      Op = "IN"
+	OpNotIn   Op = "NOT IN"
+	OpLike    Op = "LIKE"
+	OpIsNull  Op = "IS NULL"
+	OpNotNull Op = "IS NOT NULL"
+)
+
+// Filter 过滤条件，Field 为数据库列名，必须是 model 中存在的列
+type Filter struct {
+	Field string
+	Op    Op
+	Value interface{}
+}
+
+func Eq(field string, v interface{}) Filter    { return Filter{Field: field, Op: OpEq, Value: v} }
+func Ne(field string, v interface{}) Filter    { return Filter{Field: field, Op: OpNe, Value: v} }
+func Gt(field string, v interface{}) Filter    { return Filter{Field: field, Op: OpGt, Value: v} }
+func Gte(field string, v interface{}) Filter   { return Filter{Field: field, Op: OpGte, Value: v} }
+func Lt(field string, v interface{}) Filter    { return Filter{Field: field, Op: OpLt, Value: v} }
+func Lte(field string, v interface{}) Filter   { return Filter{Field: field, Op: OpLte, Value: v} }
+func In(field string, v interface{}) Filter    { return Filter{Field: field, Op: OpIn, Value: v} }
+func NotIn(field string, v interface{}) Filter { return Filter{Field: field, Op: OpNotIn, Value: v} }
+func Like(field string, v string) Filter       { return Filter{Field: field, Op: OpLike, Value: v} }
+func IsNull(field string) Filter               { return Filter{Field: field, Op: OpIsNull} }
+func NotNull(field string) Filter              { return Filter{Field: field, Op: OpNotNull} }
+
+// RepoConfig repository 配置
+type RepoConfig struct {
+	SortFields  []string // 允许排序的列名白名单
+	DefaultSort SortCond // 默认排序，为空时按 id desc
+	MaxPageSize int      // 单页最大数量，默认 200
+}
+
+// ListQuery 偏移分页查询
+type ListQuery struct {
+	PageNo   int // 从 1 开始
+	PageSize int
+	Filters  []Filter
+	Sorts    []SortCond
+}
+
+// CursorQuery 游标分页查询，Cursor 为上一页返回的 NextCursor，为空时表示第一页
+type CursorQuery struct {
+	Cursor  string
+	Limit   int
+	Filters []Filter
+	Sort    SortCond
+}
+
+// PageResult 分页结果，Meta() 与 api.PageMeta 的格式保持一致
+type PageResult[T any] struct {
+	Items      []T
+	PageNo     int
+	PageSize   int
+	Number     int
+	Total      int
+	NextCursor string
+}
+
+// Meta 分页信息
+func (p *PageResult[T]) Meta() map[string]interface{} {
+	meta := map[string]interface{}{
+		"pageSize": p.PageSize,
+		"pageNo":   p.PageNo,
+		"number":   p.Number,
+		"total":    p.Total,
+	}
+	if p.NextCursor != "" {
+		meta["nextCursor"] = p.NextCursor
+	}
+	return meta
+}
+
+// Repository 通用 repository
+type Repository[T any] struct {
+	sortFields  []string
+	defaultSort SortCond
+	maxPageSize int
+	unscoped    bool
+}
+
+// NewRepository 创建 repository
+func NewRepository[T any](conf RepoConfig) *Repository[T] {
+	r := &Repository[T]{
+		sortFields:  append([]string{"id"}, conf.SortFields...),
+		defaultSort: conf.DefaultSort,
+		maxPageSize: conf.MaxPageSize,
+	}
+
+	if r.defaultSort.Field == "" {
+		r.defaultSort = SortCond{Field: "id", Sort: "desc"}
+	}
+	if r.maxPageSize <= 0 {
+		r.maxPageSize = defaultMaxPageSize
+	}
+	return r
+}
+
+// Unscoped 返回一个包含软删除数据的 repository
+func (r *Repository[T]) Unscoped() *Repository[T] {
+	nr := *r
+	nr.unscoped = true
+	return &nr
+}
+
+func (r *Repository[T]) conn(ctx context.Context) *gorm.DB {
+	tx := Conn(ctx).Model(new(T))
+	if r.unscoped {
+		tx = tx.Unscoped()
+	}
+	return tx
+}
+
+func (r *Repository[T]) schema(tx *gorm.DB) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// Get 根据 id 获取
+func (r *Repository[T]) Get(ctx context.Context, id string) (*T, error) {
+	return r.First(ctx, Eq("id", id))
+}
+
+// First 获取符合条件的第一条数据，不存在时返回 Nil
+func (r *Repository[T]) First(ctx context.Context, filters ...Filter) (*T, error) {
+	tx := r.conn(ctx)
+	tx, err := r.applyFilters(tx, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	item := new(T)
+	if err := tx.Take(item).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Count 统计符合条件的数量
+func (r *Repository[T]) Count(ctx context.Context, filters ...Filter) (int, error) {
+	tx, err := r.applyFilters(r.conn(ctx), filters)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return int(total), nil
+}
+
+// Create 创建
+func (r *Repository[T]) Create(ctx context.Context, item *T) error {
+	return r.conn(ctx).Create(item).Error
+}
+
+// Update 根据 id 全量更新（包括零值字段），immutableFields 不会被修改
+// 不使用 Save ，避免记录不存在（或属于其他租户）时退化为 upsert 覆盖其他租户的数据
+func (r *Repository[T]) Update(ctx context.Context, item *T) error {
+	tx := r.conn(ctx)
+	sch, err := r.schema(tx)
+	if err != nil {
+		return err
+	}
+	idField := sch.LookUpField("id")
+	if idField == nil {
+		return errors.WithMessage(ErrInvalidField, "id")
+	}
+	id, zero := idField.ValueOf(ctx, reflect.ValueOf(item).Elem())
+	if zero {
+		return Nil
+	}
+
+	res := tx.Model(item).Where("id = ?", id).Select("*").Omit(immutableFields...).Updates(item)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return Nil
+	}
+	return nil
+}
+
+// immutableFields 更新时不允许修改的列，修改 tenant_id 会把数据移到其他租户
+var immutableFields = []string{"id", tenantField, "created_at"}
+
+// UpdateFields 根据 id 更新部分字段，fields 的 key 为列名，不能包含 immutableFields
+func (r *Repository[T]) UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error {
+	tx := r.conn(ctx)
+	sch, err := r.schema(tx)
+	if err != nil {
+		return err
+	}
+	for k := range fields {
+		f := sch.LookUpField(k)
+		if f == nil {
+			return errors.WithMessage(ErrInvalidField, k)
+		}
+		for _, name := range immutableFields {
+			if f.DBName == name {
+				return errors.WithMessage(ErrInvalidField, k)
+			}
+		}
+	}
+
+	res := tx.Where("id = ?", id).Updates(fields)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return Nil
+	}
+	return nil
+}
+
+// Delete 根据 id 删除，model 带有 gorm.DeletedAt 字段时为软删除
+func (r *Repository[T]) Delete(ctx context.Context, id string) error {
+	res := r.conn(ctx).Where("id = ?", id).Delete(new(T))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return Nil
+	}
+	return nil
+}
+
+// HardDelete 根据 id 物理删除
+func (r *Repository[T]) HardDelete(ctx context.Context, id string) error {
+	return r.Unscoped().Delete(ctx, id)
+}
+
+// Restore 恢复软删除的数据
+func (r *Repository[T]) Restore(ctx context.Context, id string) error {
+	res := r.Unscoped().conn(ctx).Where("id = ?", id).Update("deleted_at", nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return Nil
+	}
+	return nil
+}
+
+// List 偏移分页查询
+func (r *Repository[T]) List(ctx context.Context, q ListQuery) (*PageResult[T], error) {
+	if q.PageNo <= 0 {
+		q.PageNo = 1
+	}
+	q.PageSize = r.pageSize(q.PageSize)
+
+	total, err := r.Count(ctx, q.Filters...)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.applyFilters(r.conn(ctx), q.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	sorts := q.Sorts
+	if len(sorts) == 0 {
+		sorts = []SortCond{r.defaultSort}
+	}
+	for i := range sorts {
+		if err := sorts[i].Validate(r.sortFields...); err != nil {
+			return nil, err
+		}
+		tx = tx.Order(sorts[i].Stringify())
+	}
+
+	items := make([]T, 0, q.PageSize)
+	err = tx.Offset((q.PageNo - 1) * q.PageSize).Limit(q.PageSize).Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &PageResult[T]{
+		Items:    items,
+		PageNo:   q.PageNo,
+		PageSize: q.PageSize,
+		Number:   len(items),
+		Total:    total,
+	}, nil
+}
+
+// cursor 游标内容，记录上一页最后一条数据的排序列值和 id
+// Value 保留原始 json ，解码时按排序列的类型还原，避免 time 变成 string 、 int64 变成 float64
+type cursor struct {
+	Value jsoniter.RawMessage `json:"v"`
+	ID    string              `json:"id"`
+}
+
+func encodeCursor(v interface{}, id string) (string, error) {
+	raw, err := jsoniter.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	b, err := jsoniter.Marshal(&cursor{Value: raw, ID: id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &cursor{}
+	if err := jsoniter.Unmarshal(b, c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// value 按排序列的类型解码游标中的值
+func (c *cursor) value(typ reflect.Type) (interface{}, error) {
+	v := reflect.New(typ)
+	if err := jsoniter.Unmarshal(c.Value, v.Interface()); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return v.Elem().Interface(), nil
+}
+
+// ListByCursor 游标分页查询，使用 (排序列, id) 作为 keyset，适合大表深分页
+func (r *Repository[T]) ListByCursor(ctx context.Context, q CursorQuery) (*PageResult[T], error) {
+	q.Limit = r.pageSize(q.Limit)
+	if q.Sort.Field == "" {
+		q.Sort = r.defaultSort
+	}
+	if err := q.Sort.Validate(r.sortFields...); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.applyFilters(r.conn(ctx), q.Filters)
+	if err != nil {
+		return nil, err
+	}
+	sch, err := r.schema(tx)
+	if err != nil {
+		return nil, err
+	}
+	sortField := sch.LookUpField(q.Sort.Field)
+	if sortField == nil {
+		return nil, errors.WithMessage(ErrInvalidSort, q.Sort.Field)
+	}
+	idField := sch.LookUpField("id")
+	if idField == nil {
+		return nil, errors.WithMessage(ErrInvalidField, "id")
+	}
+
+	cmp := ">"
+	if q.Sort.isDesc() {
+		cmp = "<"
+	}
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if sortField.DBName == "id" {
+			tx = tx.Where(fmt.Sprintf("id %s ?", cmp), c.ID)
+		} else {
+			v, err := c.value(sortField.FieldType)
+			if err != nil {
+				return nil, err
+			}
+			col := clause.Column{Name: sortField.DBName}
+			tx = tx.Where(fmt.Sprintf("(?, id) %s (?, ?)", cmp), col, v, c.ID)
+		}
+	}
+
+	tx = tx.Order(q.Sort.Stringify())
+	if sortField.DBName != "id" {
+		tx = tx.Order(fmt.Sprintf("id %s", strings.ToLower(q.Sort.Sort)))
+	}
+
+	// 多取一条，用于判断是否还有下一页
+	items := make([]T, 0, q.Limit+1)
+	if err := tx.Limit(q.Limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	res := &PageResult[T]{PageSize: q.Limit}
+	if len(items) > q.Limit {
+		items = items[:q.Limit]
+
+		last := reflect.ValueOf(&items[len(items)-1]).Elem()
+		v, _ := sortField.ValueOf(ctx, last)
+		id, _ := idField.ValueOf(ctx, last)
+		res.NextCursor, err = encodeCursor(v, fmt.Sprint(id))
+		if err != nil {
+			return nil, err
+		}
+	}
+	res.Items = items
+	res.Number = len(items)
+	return res, nil
+}
+
+func (r *Repository[T]) pageSize(size int) int {
+	if size <= 0 {
+		return defaultPageSize
+	}
+	if size > r.maxPageSize {
+		return r.maxPageSize
+	}
+	return size
+}
+
+// applyFilters 拼接过滤条件，列名必须在 model 的 schema 中存在，防止注入
+func (r *Repository[T]) applyFilters(tx *gorm.DB, filters []Filter) (*gorm.DB, error) {
+	if len(filters) == 0 {
+		return tx, nil
+	}
+	sch, err := r.schema(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range filters {
+		field := sch.LookUpField(f.Field)
+		if field == nil || field.DBName == "" {
+			return nil, errors.WithMessage(ErrInvalidField, f.Field)
+		}
+		col := clause.Column{Name: field.DBName}
+
+		switch f.Op {
+		case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpLike, OpIn, OpNotIn:
+			tx = tx.Where(fmt.Sprintf("? %s ?", f.Op), col, f.Value)
+		case OpIsNull, OpNotNull:
+			tx = tx.Where(fmt.Sprintf("? %s", f.Op), col)
+		default:
+			return nil, errors.WithMessage(ErrInvalidField, fmt.Sprintf("unsupported op %s", f.Op))
+		}
+	}
+	return tx, nil
+}
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"night-fury/pkgs/tenant"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// initTestDB 使用内存 sqlite ，返回带有租户信息的 context
+func initTestDB(t *testing.T) context.Context {
+	err := Init(context.Background(), &Config{
+		Driver: DriverSQLite,
+		DSN:    fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+	})
+	assert.Nil(t, err)
+
+	_, err = MigrateUp(context.Background(), 0)
+	assert.Nil(t, err)
+
+	return tenant.WithID(context.Background(), "tenant1")
+}
+
+func TestRepository(t *testing.T) {
+	ctx := initTestDB(t)
+
+	for i := 0; i < 5; i++ {
+		u := &User{Name: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("u%d@longalong.cn", i), Phone: fmt.Sprint(i)}
+		u.ID = fmt.Sprintf("id%d", i)
+		assert.Nil(t, Users.Create(ctx, u))
+	}
+
+	res, err := Users.List(ctx, ListQuery{PageNo: 2, PageSize: 2, Sorts: []SortCond{{Field: "name", Sort: "asc"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, res.Total)
+	assert.Equal(t, 2, res.Number)
+	assert.Equal(t, "user2", res.Items[0].Name)
+
+	_, err = Users.List(ctx, ListQuery{Sorts: []SortCond{{Field: "name; drop table users", Sort: "asc"}}})
+	assert.ErrorIs(t, err, ErrInvalidSort)
+	_, err = Users.First(ctx, Eq("1=1 or name", "x"))
+	assert.ErrorIs(t, err, ErrInvalidField)
+
+	// 游标分页
+	var names []string
+	q := CursorQuery{Limit: 2, Sort: SortCond{Field: "name", Sort: "desc"}}
+	for {
+		page, err := Users.ListByCursor(ctx, q)
+		assert.Nil(t, err)
+		for _, u := range page.Items {
+			names = append(names, u.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"user4", "user3", "user2", "user1", "user0"}, names)
+
+	// 软删除
+	assert.Nil(t, Users.Delete(ctx, "id1"))
+	_, err = Users.Get(ctx, "id1")
+	assert.ErrorIs(t, err, Nil)
+	total, err := Users.Unscoped().Count(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, total)
+	assert.Nil(t, Users.Restore(ctx, "id1"))
+	u, err := Users.Get(ctx, "id1")
+	assert.Nil(t, err)
+	assert.Equal(t, "user1", u.Name)
+}
+
+func TestSortCondValidate(t *testing.T) {
+	allowed := []string{"created_at", "name"}
+
+	assert.Nil(t, (&SortCond{Field: "name", Sort: "ASC"}).Validate(allowed...))
+	assert.Nil(t, (&SortCond{Field: "created_at"}).Validate(allowed...))
+	assert.ErrorIs(t, (&SortCond{Field: "name", Sort: "desc, id"}).Validate(allowed...), ErrInvalidSort)
+	assert.ErrorIs(t, (&SortCond{Field: "email", Sort: "asc"}).Validate(allowed...), ErrInvalidSort)
+	assert.ErrorIs(t, (&SortCond{Field: "name"}).Validate(), ErrInvalidSort)
+}
+
+func TestRepositoryFilters(t *testing.T) {
+	ctx := initTestDB(t)
+
+	for i := 0; i < 5; i++ {
+		u := &User{Name: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("u%d@longalong.cn", i), Phone: fmt.Sprint(i)}
+		u.ID = fmt.Sprintf("id%d", i)
+		if i%2 == 1 {
+			u.Gender = "female"
+		}
+		assert.Nil(t, Users.Create(ctx, u))
+	}
+
+	cases := []struct {
+		filters []Filter
+		want    int
+	}{
+		{[]Filter{Eq("name", "user1")}, 1},
+		{[]Filter{Ne("name", "user1")}, 4},
+		{[]Filter{Gt("phone", "2")}, 2},
+		{[]Filter{Gte("phone", "2")}, 3},
+		{[]Filter{Lt("phone", "2")}, 2},
+		{[]Filter{Lte("phone", "2")}, 3},
+		{[]Filter{In("id", []string{"id0", "id3", "id404"})}, 2},
+		{[]Filter{NotIn("id", []string{"id0", "id3"})}, 3},
+		{[]Filter{Like("email", "u%@longalong.cn")}, 5},
+		{[]Filter{IsNull("deleted_at")}, 5},
+		{[]Filter{NotNull("deleted_at")}, 0},
+		// 字段名和 go 字段名都可以
+		{[]Filter{Eq("Gender", "female"), Gte("phone", "2")}, 1},
+	}
+	for _, c := range cases {
+		total, err := Users.Count(ctx, c.filters...)
+		assert.Nil(t, err)
+		assert.Equal(t, c.want, total, "%+v", c.filters)
+	}
+
+	// 不存在的列和不支持的操作符
+	for _, f := range []Filter{
+		Eq("not_exists", 1),
+		Eq("name = name or 1", 1),
+		{Field: "name", Op: "= 1 or 1 =", Value: 1},
+	} {
+		_, err := Users.Count(ctx, f)
+		assert.ErrorIs(t, err, ErrInvalidField)
+		_, err = Users.List(ctx, ListQuery{Filters: []Filter{f}})
+		assert.ErrorIs(t, err, ErrInvalidField)
+		_, err = Users.ListByCursor(ctx, CursorQuery{Filters: []Filter{f}})
+		assert.ErrorIs(t, err, ErrInvalidField)
+	}
+
+	// 排序列不在白名单内
+	_, err := Users.List(ctx, ListQuery{Sorts: []SortCond{{Field: "password", Sort: "asc"}}})
+	assert.ErrorIs(t, err, ErrInvalidSort)
+	_, err = Users.ListByCursor(ctx, CursorQuery{Sort: SortCond{Field: "password", Sort: "asc"}})
+	assert.ErrorIs(t, err, ErrInvalidSort)
+}
+
+func TestCursorCodec(t *testing.T) {
+	s, err := encodeCursor(int64(1)<<40, "id1")
+	assert.Nil(t, err)
+	c, err := decodeCursor(s)
+	assert.Nil(t, err)
+	assert.Equal(t, "id1", c.ID)
+	v, err := c.value(reflect.TypeOf(int64(0)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1)<<40, v)
+
+	now := time.Date(2021, 7, 20, 10, 0, 0, 123456789, time.UTC)
+	s, err = encodeCursor(now, "id2")
+	assert.Nil(t, err)
+	c, err = decodeCursor(s)
+	assert.Nil(t, err)
+	v, err = c.value(reflect.TypeOf(time.Time{}))
+	assert.Nil(t, err)
+	assert.True(t, now.Equal(v.(time.Time)))
+
+	// 值类型和排序列不一致
+	_, err = c.value(reflect.TypeOf(int64(0)))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	for _, s := range []string{"bad cursor", base64.RawURLEncoding.EncodeToString([]byte("{")), base64.RawURLEncoding.EncodeToString([]byte(`{"v":1}`))} {
+		_, err = decodeCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	}
+}
+
+// listAll 按游标依次取完所有页，返回每条数据的 id
+func listAll[T any](t *testing.T, ctx context.Context, r *Repository[T], q CursorQuery, id func(T) string) []string {
+	var ids []string
+	for i := 0; i < 10; i++ {
+		page, err := r.ListByCursor(ctx, q)
+		if !assert.Nil(t, err) {
+			return ids
+		}
+		for _, item := range page.Items {
+			ids = append(ids, id(item))
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		q.Cursor = page.NextCursor
+	}
+	t.Fatal("cursor does not end")
+	return ids
+}
+
+func TestListByCursorTyped(t *testing.T) {
+	ctx := initTestDB(t)
+
+	// 时间列，id 顺序和时间顺序相反，且有相同时间的数据
+	base := time.Date(2021, 7, 20, 10, 0, 0, 123456789, time.Local)
+	for i := 0; i < 5; i++ {
+		u := &User{Name: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("u%d@longalong.cn", i), Phone: fmt.Sprint(i)}
+		u.ID = fmt.Sprintf("id%d", i)
+		u.CreatedAt = base.Add(-time.Duration(i/2) * time.Millisecond)
+		assert.Nil(t, Users.Create(ctx, u))
+	}
+	userID := func(u User) string { return u.ID }
+
+	ids := listAll(t, ctx, Users, CursorQuery{Limit: 2, Sort: SortCond{Field: "created_at", Sort: "asc"}}, userID)
+	assert.Equal(t, []string{"id4", "id2", "id3", "id0", "id1"}, ids)
+	ids = listAll(t, ctx, Users, CursorQuery{Limit: 2, Sort: SortCond{Field: "created_at", Sort: "desc"}}, userID)
+	assert.Equal(t, []string{"id1", "id0", "id3", "id2", "id4"}, ids)
+
+	// int 列
+	type Score struct {
+		Model
+		Value int64
+	}
+	assert.Nil(t, GetDb().AutoMigrate(&Score{}))
+	scores := NewRepository[Score](RepoConfig{SortFields: []string{"value"}})
+	for i, v := range []int64{30, 2, 100, 2, 1 << 40} {
+		s := &Score{Value: v}
+		s.ID = fmt.Sprintf("id%d", i)
+		assert.Nil(t, scores.Create(ctx, s))
+	}
+	scoreID := func(s Score) string { return s.ID }
+
+	ids = listAll(t, ctx, scores, CursorQuery{Limit: 2, Sort: SortCond{Field: "value", Sort: "asc"}}, scoreID)
+	assert.Equal(t, []string{"id1", "id3", "id0", "id2", "id4"}, ids)
+	ids = listAll(t, ctx, scores, CursorQuery{Limit: 2, Sort: SortCond{Field: "value", Sort: "desc"}}, scoreID)
+	assert.Equal(t, []string{"id4", "id2", "id0", "id3", "id1"}, ids)
+
+	_, err := scores.ListByCursor(ctx, CursorQuery{Sort: SortCond{Field: "value"}, Cursor: "bad cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestUpdateCrossTenant(t *testing.T) {
+	ctx1 := initTestDB(t)
+	ctx2 := tenant.WithID(context.Background(), "tenant2")
+
+	u := &User{Name: "owner", Email: "owner@longalong.cn", Phone: "1"}
+	u.ID = "id1"
+	assert.Nil(t, Users.Create(ctx1, u))
+
+	// 其他租户使用同样的 id 更新，不能覆盖，也不能插入新数据
+	evil := &User{Name: "evil", Email: "evil@longalong.cn", Phone: "2"}
+	evil.ID = "id1"
+	assert.ErrorIs(t, Users.Update(ctx2, evil), Nil)
+	evil.TenantID = "tenant1"
+	assert.ErrorIs(t, Users.Update(ctx2, evil), Nil)
+
+	got, err := Users.Get(ctx1, "id1")
+	assert.Nil(t, err)
+	assert.Equal(t, "owner", got.Name)
+	_, err = Users.Get(ctx2, "id1")
+	assert.ErrorIs(t, err, Nil)
+	total, err := Users.Count(tenant.WithSystem(context.Background()))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, total)
+
+	// 本租户正常更新，零值字段也会更新，tenant_id 和 created_at 不变
+	got.Name = "owner2"
+	got.Phone = ""
+	got.TenantID = "tenant2"
+	assert.Nil(t, Users.Update(ctx1, got))
+	got, err = Users.Get(ctx1, "id1")
+	assert.Nil(t, err)
+	assert.Equal(t, "owner2", got.Name)
+	assert.Equal(t, "", got.Phone)
+	assert.Equal(t, "tenant1", got.TenantID)
+	assert.False(t, got.CreatedAt.IsZero())
+
+	// UpdateFields 不能修改 id 、 tenant_id 、 created_at
+	for _, k := range []string{"tenant_id", "TenantID", "id", "created_at"} {
+		assert.ErrorIs(t, Users.UpdateFields(ctx1, "id1", map[string]interface{}{k: "tenant2"}), ErrInvalidField)
+	}
+	_, err = Users.Get(ctx1, "id1")
+	assert.Nil(t, err)
+	_, err = Users.Get(ctx2, "id1")
+	assert.ErrorIs(t, err, Nil)
+
+	// 不存在的记录
+	missing := &User{Name: "missing"}
+	missing.ID = "id404"
+	assert.ErrorIs(t, Users.Update(ctx1, missing), Nil)
+}
@@ -0,0 +1,126 @@
+package db
+
+// 读写分离
+// 配置了 Replicas 时，普通的查询会路由到只读副本，写操作和事务走主库
+// 主从同步有延迟，对于需要 "读自己的写" 的场景，可以使用：
+//   ctx = db.WithSticky(ctx)   // 该 context 上发生写操作后，后续的读都走主库 (dashboard 已通过中间件开启)
+//   ctx = db.WithPrimary(ctx)  // 该 context 上的读写全部走主库
+// 需要通过 db.Conn(ctx) 获取实例，路由才会生效
+
+import (
+	"context"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+)
+
+type stickyKey struct{}
+
+type stickyState struct {
+	primary int32
+}
+
+// WithSticky 开启 "读自己的写" ，在该 context 上发生写操作后，后续的读都走主库
+func WithSticky(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(stickyKey{}).(*stickyState); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, stickyKey{}, &stickyState{})
+}
+
+// WithPrimary 该 context 上的读写全部走主库
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stickyKey{}, &stickyState{primary: 1})
+}
+
+func usePrimary(ctx context.Context) bool {
+	s, ok := ctx.Value(stickyKey{}).(*stickyState)
+	return ok && atomic.LoadInt32(&s.primary) == 1
+}
+
+func markPrimary(ctx context.Context) {
+	if s, ok := ctx.Value(stickyKey{}).(*stickyState); ok {
+		atomic.StoreInt32(&s.primary, 1)
+	}
+}
+
+// Conn 获取带 context 的 db 实例，会根据 context 决定读写路由，未初始化时所有操作都返回 ErrNotInit
+// context 中有事务时(见 WithTx)，返回该事务
+func Conn(ctx context.Context) *gorm.DB {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+
+	if db == nil {
+		return notInitDB(ctx)
+	}
+
+	tx := GetDb().WithContext(ctx)
+	if usePrimary(ctx) {
+		// Session 保证返回的实例可以被重复使用，不会互相污染条件
+		tx = tx.Clauses(dbresolver.Write).Session(&gorm.Session{})
+	}
+	return tx
+}
+
+// notInitDB 未初始化时返回的实例，不连接数据库，所有操作都返回 ErrNotInit
+func notInitDB(ctx context.Context) *gorm.DB {
+	// 没有 Dialector ，日志不能输出 sql
+	gdb, err := gorm.Open(nil, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		gdb = &gorm.DB{Config: &gorm.Config{}}
+	}
+	gdb = gdb.WithContext(ctx)
+	gdb.AddError(ErrNotInit)
+	return gdb
+}
+
+// useResolver 注册只读副本
+func useResolver(gdb *gorm.DB, conf *Config) error {
+	if len(conf.Replicas) == 0 {
+		return nil
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(conf.Replicas))
+	for _, dsn := range conf.Replicas {
+		dialector, err := openDialector(conf, dsn)
+		if err != nil {
+			return err
+		}
+		replicas = append(replicas, dialector)
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}).
+		SetMaxIdleConns(conf.MaxIdleConns).
+		SetMaxOpenConns(conf.MaxOpenConns).
+		SetConnMaxLifetime(conf.ConnMaxLifetime).
+		SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+
+	return gdb.Use(resolver)
+}
+
+// registerStickyCallbacks 写操作成功后，标记该 context 后续走主库
+func registerStickyCallbacks(gdb *gorm.DB) error {
+	mark := func(tx *gorm.DB) {
+		if tx.Error == nil && tx.Statement.Context != nil {
+			markPrimary(tx.Statement.Context)
+		}
+	}
+
+	cb := gdb.Callback()
+	if err := cb.Create().After("gorm:create").Register("night-fury:sticky_create", mark); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("night-fury:sticky_update", mark); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("night-fury:sticky_delete", mark); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("night-fury:sticky_raw", mark)
+}
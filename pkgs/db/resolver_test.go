@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"night-fury/pkgs/tenant"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSticky(t *testing.T) {
+	ctx := WithSticky(initTestDB(t))
+	assert.False(t, usePrimary(ctx))
+
+	var users []User
+	assert.Nil(t, Conn(ctx).Find(&users).Error)
+	assert.False(t, usePrimary(ctx))
+
+	u := &User{Name: "sticky", Email: "sticky@longalong.cn", Phone: "sticky"}
+	u.ID = "sticky"
+	assert.Nil(t, Conn(ctx).Create(u).Error)
+	assert.True(t, usePrimary(ctx))
+
+	assert.True(t, usePrimary(WithPrimary(context.Background())))
+}
+
+// openTestDB 打开一个迁移好的内存 sqlite ，并写入一个用户，用于区分主库和副本
+func openTestDB(t *testing.T, dsn, name string) *gorm.DB {
+	assert.Nil(t, Init(context.Background(), &Config{Driver: DriverSQLite, DSN: dsn}))
+	_, err := MigrateUp(context.Background(), 0)
+	assert.Nil(t, err)
+
+	ctx := tenant.WithID(context.Background(), "tenant1")
+	u := &User{Name: name, Email: name + "@longalong.cn", Phone: name}
+	u.ID = name
+	assert.Nil(t, Users.Create(ctx, u))
+	return GetDb()
+}
+
+func TestResolver(t *testing.T) {
+	primaryDSN := fmt.Sprintf("file:%s_primary?mode=memory&cache=shared", t.Name())
+	replicaDSN := fmt.Sprintf("file:%s_replica?mode=memory&cache=shared", t.Name())
+	// 保持连接，内存库才不会被销毁
+	primary := openTestDB(t, primaryDSN, "primary")
+	replica := openTestDB(t, replicaDSN, "replica")
+
+	assert.Nil(t, Init(context.Background(), &Config{Driver: DriverSQLite, DSN: primaryDSN, Replicas: []string{replicaDSN}}))
+	ctx := tenant.WithID(context.Background(), "tenant1")
+
+	names := func(tx *gorm.DB) []string {
+		var users []User
+		assert.Nil(t, tx.Order("id").Find(&users).Error)
+		list := make([]string, 0, len(users))
+		for _, u := range users {
+			list = append(list, u.Name)
+		}
+		return list
+	}
+
+	// 普通的读走副本， WithPrimary 走主库
+	assert.Equal(t, []string{"replica"}, names(Conn(ctx)))
+	assert.Equal(t, []string{"primary"}, names(Conn(WithPrimary(ctx))))
+
+	// 写操作走主库，之后同一个 sticky context 的读也走主库
+	sticky := WithSticky(ctx)
+	assert.Equal(t, []string{"replica"}, names(Conn(sticky)))
+	u := &User{Name: "written", Email: "written@longalong.cn", Phone: "written"}
+	u.ID = "written"
+	assert.Nil(t, Users.Create(sticky, u))
+	assert.Equal(t, []string{"primary", "written"}, names(Conn(sticky)))
+	assert.Equal(t, []string{"replica"}, names(Conn(ctx)))
+
+	assert.Nil(t, Users.UpdateFields(WithPrimary(ctx), "primary", map[string]interface{}{"name": "primary2"}))
+	assert.Nil(t, Users.Delete(WithPrimary(ctx), "written"))
+
+	// 副本上没有任何写入
+	assert.Equal(t, []string{"replica"}, names(replica.WithContext(ctx)))
+	assert.Equal(t, []string{"primary2"}, names(primary.WithContext(ctx)))
+}
+
+func TestConnNotInit(t *testing.T) {
+	old := db
+	db = nil
+	defer SetDb(old)
+
+	// 未初始化时返回错误，不能 panic
+	ctx := tenant.WithID(context.Background(), "tenant1")
+	_, err := Users.Get(ctx, "id1")
+	assert.ErrorIs(t, err, ErrNotInit)
+	_, err = Users.ListByCursor(ctx, CursorQuery{})
+	assert.ErrorIs(t, err, ErrNotInit)
+	assert.ErrorIs(t, Users.Create(ctx, &User{}), ErrNotInit)
+	assert.ErrorIs(t, Conn(ctx).Exec("SELECT 1").Error, ErrNotInit)
+	assert.ErrorIs(t, WithTx(ctx, func(ctx context.Context) error { return nil }), ErrNotInit)
+}
@@ -0,0 +1,114 @@
+package db
+
+// 多租户隔离
+// model 中有 TenantID 字段(db.Model 已包含)时：
+//   查询/更新/删除 自动加上 tenant_id = ? 条件
+//   创建时自动填充 tenant_id ，与 context 中的租户不一致时报错
+// context 中没有租户时报错 tenant.ErrNoTenant ，系统级操作使用 tenant.WithSystem
+// 原生 sql (Raw/Exec) 不会自动过滤，需要自己加条件
+
+import (
+	"night-fury/pkgs/tenant"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const tenantField = "tenant_id"
+
+var ErrTenantMismatch = errors.New("tenant mismatch")
+
+func tenantSchemaField(tx *gorm.DB) *schema.Field {
+	if tx.Statement.Schema == nil {
+		return nil
+	}
+	return tx.Statement.Schema.LookUpField(tenantField)
+}
+
+// tenantOf 获取 context 中的租户，返回 false 表示不需要过滤
+func tenantOf(tx *gorm.DB) (string, bool) {
+	ctx := tx.Statement.Context
+	if tenant.IsSystem(ctx) {
+		return "", false
+	}
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		tx.AddError(tenant.ErrNoTenant)
+		return "", false
+	}
+	return id, true
+}
+
+func tenantScope(tx *gorm.DB) {
+	if tx.Error != nil {
+		return
+	}
+	field := tenantSchemaField(tx)
+	if field == nil {
+		return
+	}
+	tenantID, ok := tenantOf(tx)
+	if !ok {
+		return
+	}
+	tx.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenantID},
+	}})
+}
+
+func tenantCreate(tx *gorm.DB) {
+	if tx.Error != nil {
+		return
+	}
+	field := tenantSchemaField(tx)
+	if field == nil {
+		return
+	}
+	tenantID, ok := tenantOf(tx)
+	if !ok {
+		return
+	}
+
+	ctx := tx.Statement.Context
+	setTenant := func(rv reflect.Value) {
+		v, zero := field.ValueOf(ctx, rv)
+		if zero {
+			if err := field.Set(ctx, rv, tenantID); err != nil {
+				tx.AddError(err)
+			}
+			return
+		}
+		if v != tenantID {
+			tx.AddError(ErrTenantMismatch)
+		}
+	}
+
+	switch rv := reflect.Indirect(tx.Statement.ReflectValue); rv.Kind() {
+	case reflect.Struct:
+		setTenant(rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			setTenant(reflect.Indirect(rv.Index(i)))
+		}
+	}
+}
+
+func registerTenantCallbacks(gdb *gorm.DB) error {
+	cb := gdb.Callback()
+	if err := cb.Create().Before("*").Register("night-fury:tenant_create", tenantCreate); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("*").Register("night-fury:tenant_query", tenantScope); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("*").Register("night-fury:tenant_update", tenantScope); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("*").Register("night-fury:tenant_delete", tenantScope); err != nil {
+		return err
+	}
+	return cb.Row().Before("*").Register("night-fury:tenant_row", tenantScope)
+}
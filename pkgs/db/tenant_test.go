@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"night-fury/pkgs/tenant"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenant(t *testing.T) {
+	ctx1 := initTestDB(t)
+	ctx2 := tenant.WithID(context.Background(), "tenant2")
+
+	u := &User{Name: "t1", Email: "t1@longalong.cn", Phone: "t1"}
+	u.ID = "t1"
+	assert.Nil(t, Users.Create(ctx1, u))
+	assert.Equal(t, "tenant1", u.TenantID)
+
+	// 其他租户不可见，也不能修改和删除
+	_, err := Users.Get(ctx2, "t1")
+	assert.ErrorIs(t, err, Nil)
+	assert.ErrorIs(t, Users.UpdateFields(ctx2, "t1", map[string]interface{}{"name": "hack"}), Nil)
+	assert.ErrorIs(t, Users.Delete(ctx2, "t1"), Nil)
+	total, err := Users.Count(ctx2)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, total)
+
+	// 不能以其他租户的身份创建
+	u2 := &User{Name: "t2", Email: "t2@longalong.cn", Phone: "t2"}
+	u2.ID = "t2"
+	u2.TenantID = "tenant1"
+	assert.ErrorIs(t, Users.Create(ctx2, u2), ErrTenantMismatch)
+
+	// email/phone 只在租户内唯一
+	u3 := &User{Name: "t3", Email: "t1@longalong.cn", Phone: "t1"}
+	u3.ID = "t3"
+	assert.Nil(t, Users.Create(ctx2, u3))
+	u4 := &User{Name: "t4", Email: "t1@longalong.cn", Phone: "t4"}
+	u4.ID = "t4"
+	assert.NotNil(t, Users.Create(ctx1, u4))
+
+	// 没有租户信息时拒绝访问
+	_, err = Users.Get(context.Background(), "t1")
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+
+	// 系统级操作可以跨租户
+	total, err = Users.Count(tenant.WithSystem(context.Background()))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, total)
+}
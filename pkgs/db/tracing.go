@@ -0,0 +1,89 @@
+package db
+
+// 每条 sql 创建一个 span ，context 中有上游 span 时作为它的子 span
+// 需要通过 WithContext 传入 context ，否则是独立的调用链
+
+import (
+	"night-fury/pkgs/tracing"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const tracingSpanKey = "night-fury:span"
+
+func tracingBefore(name string) func(tx *gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx, span := tracing.Start(tx.Statement.Context, "db."+name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(tx.Dialector.Name()),
+				semconv.DBOperation(name),
+			),
+		)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(tracingSpanKey, span)
+	}
+}
+
+func tracingAfter(tx *gorm.DB) {
+	v, ok := tx.InstanceGet(tracingSpanKey)
+	if !ok {
+		return
+	}
+	span := v.(trace.Span)
+	if span.IsRecording() {
+		span.SetAttributes(
+			semconv.DBStatement(tx.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+		)
+		if tx.Statement.Table != "" {
+			span.SetAttributes(semconv.DBSQLTable(tx.Statement.Table))
+		}
+	}
+
+	err := tx.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+func registerTracingCallbacks(gdb *gorm.DB) error {
+	cb := gdb.Callback()
+	register := []func() error{
+		func() error {
+			return cb.Create().Before("*").Register("night-fury:tracing_before_create", tracingBefore("create"))
+		},
+		func() error { return cb.Create().After("*").Register("night-fury:tracing_after_create", tracingAfter) },
+		func() error {
+			return cb.Query().Before("*").Register("night-fury:tracing_before_query", tracingBefore("query"))
+		},
+		func() error { return cb.Query().After("*").Register("night-fury:tracing_after_query", tracingAfter) },
+		func() error {
+			return cb.Update().Before("*").Register("night-fury:tracing_before_update", tracingBefore("update"))
+		},
+		func() error { return cb.Update().After("*").Register("night-fury:tracing_after_update", tracingAfter) },
+		func() error {
+			return cb.Delete().Before("*").Register("night-fury:tracing_before_delete", tracingBefore("delete"))
+		},
+		func() error { return cb.Delete().After("*").Register("night-fury:tracing_after_delete", tracingAfter) },
+		func() error {
+			return cb.Row().Before("*").Register("night-fury:tracing_before_row", tracingBefore("row"))
+		},
+		func() error { return cb.Row().After("*").Register("night-fury:tracing_after_row", tracingAfter) },
+		func() error {
+			return cb.Raw().Before("*").Register("night-fury:tracing_before_raw", tracingBefore("raw"))
+		},
+		func() error { return cb.Raw().After("*").Register("night-fury:tracing_after_raw", tracingAfter) },
+	}
+	for _, r := range register {
+		if err := r(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
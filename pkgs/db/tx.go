@@ -0,0 +1,94 @@
+package db
+
+// 基于 context 的事务
+//   err := db.WithTx(ctx, func(ctx context.Context) error {
+//       if err := db.Conn(ctx).Create(u).Error; err != nil { // 通过 db.Conn(ctx) 获取的实例都在事务中
+//           return err
+//       }
+//       return db.WithTx(ctx, func(ctx context.Context) error { ... }) // 嵌套时使用 savepoint
+//   })
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	txMaxRetries   = 3
+	txRetryBackoff = time.Millisecond * 50
+)
+
+type txKey struct{}
+
+func txFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txKey{}).(*gorm.DB)
+	return tx, ok
+}
+
+// WithTx 在事务中执行 f ，事务通过 ctx 传递给 f
+// 嵌套调用时使用 savepoint ，内层返回错误只会回滚到 savepoint
+// f panic 时回滚事务并继续 panic ，commit 失败时返回 commit 的错误
+// 遇到序列化失败或死锁时，最外层事务会整体重试，所以 f 需要是可重入的
+func WithTx(ctx context.Context, f func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	if tx, ok := txFromContext(ctx); ok {
+		// gorm 在已有事务中调用 Transaction 时会自动使用 savepoint
+		return tx.Transaction(func(tx *gorm.DB) error {
+			return f(context.WithValue(ctx, txKey{}, tx))
+		})
+	}
+
+	if db == nil {
+		return ErrNotInit
+	}
+
+	var err error
+	for i := 0; i < txMaxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.WithMessage(ctx.Err(), err.Error())
+			case <-time.After(txRetryBackoff * time.Duration(i)):
+			}
+		}
+
+		err = Conn(WithPrimary(ctx)).Transaction(func(tx *gorm.DB) error {
+			return f(context.WithValue(ctx, txKey{}, tx))
+		}, opts...)
+		if !isRetryableTxError(err) {
+			return err
+		}
+	}
+	return errors.Wrapf(err, "transaction failed after %d retries", txMaxRetries)
+}
+
+// InTx 当前 context 是否在事务中
+func InTx(ctx context.Context) bool {
+	_, ok := txFromContext(ctx)
+	return ok
+}
+
+// isRetryableTxError 序列化失败和死锁可以重试
+func isRetryableTxError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 40001 serialization_failure, 40P01 deadlock_detected
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// 1213 deadlock, 1205 lock wait timeout
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTx(t *testing.T) {
+	ctx := initTestDB(t)
+	errInner := errors.New("inner error")
+
+	newUser := func(id string) *User {
+		u := &User{Name: id, Email: id, Phone: id}
+		u.ID = id
+		return u
+	}
+
+	// 内层回滚到 savepoint ，外层正常提交
+	err := WithTx(ctx, func(ctx context.Context) error {
+		assert.True(t, InTx(ctx))
+		if err := Users.Create(ctx, newUser("outer")); err != nil {
+			return err
+		}
+		err := WithTx(ctx, func(ctx context.Context) error {
+			if err := Users.Create(ctx, newUser("inner")); err != nil {
+				return err
+			}
+			return errInner
+		})
+		assert.Equal(t, errInner, err)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	_, err = Users.Get(ctx, "outer")
+	assert.Nil(t, err)
+	_, err = Users.Get(ctx, "inner")
+	assert.ErrorIs(t, err, Nil)
+
+	// panic 时回滚
+	assert.Panics(t, func() {
+		WithTx(ctx, func(ctx context.Context) error {
+			Users.Create(ctx, newUser("panic"))
+			panic("boom")
+		})
+	})
+	_, err = Users.Get(ctx, "panic")
+	assert.ErrorIs(t, err, Nil)
+
+	// 序列化失败时重试
+	times := 0
+	err = WithTx(ctx, func(ctx context.Context) error {
+		times++
+		if times == 1 {
+			return &pgconn.PgError{Code: "40001"}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, times)
+}
+
+func TestInitTransaction(t *testing.T) {
+	ctx := initTestDB(t)
+
+	create := func(id string, fail bool) (err error) {
+		tx, finally, err := InitTransaction()
+		if err != nil {
+			return err
+		}
+		defer finally(&err)
+
+		u := &User{Name: id, Email: id, Phone: id}
+		u.ID = id
+		if err = tx.WithContext(ctx).Create(u).Error; err != nil {
+			return err
+		}
+		if fail {
+			return errors.New("fail")
+		}
+		return nil
+	}
+
+	assert.Nil(t, create("commit", false))
+	assert.NotNil(t, create("rollback", true))
+
+	_, err := Users.Get(ctx, "commit")
+	assert.Nil(t, err)
+	_, err = Users.Get(ctx, "rollback")
+	assert.ErrorIs(t, err, Nil)
+}
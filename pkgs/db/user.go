@@ -1,27 +1,30 @@
 package db
 
+import "context"
+
 type User struct {
 	Model
+	TenantID string `gorm:"type:varchar(64);index;uniqueIndex:idx_users_tenant_email,priority:1;uniqueIndex:idx_users_tenant_phone,priority:1" json:"tenantID"` // 覆盖 Model.TenantID ，email/phone 在租户内唯一
 	Name     string `gorm:"type:varchar(200)" json:"name"`
-	Email    string `gorm:"type:varchar(200);uniqueIndex;" json:"email"`
+	Email    string `gorm:"type:varchar(200);uniqueIndex:idx_users_tenant_email,priority:2" json:"email"`
 	Password string `gorm:"type:varchar(200)" json:"password"`
-	Phone    string `gorm:"type:varchar(200);uniqueIndex;" json:"phone"`
+	Phone    string `gorm:"type:varchar(200);uniqueIndex:idx_users_tenant_phone,priority:2" json:"phone"`
 	Gender   string `gorm:"type:varchar(20);default:male"`
 }
 
 type SessUser struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	ID    string `json:"ID"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	ID       string `json:"ID"`
+	TenantID string `json:"tenantID"`
 }
 
-func GetUser(uid string) (*User, error) {
-	u := &User{}
-	u.ID = uid
-
-	if result := db.First(u); result.Error != nil {
-		return nil, result.Error
-	}
+// Users user 的 repository
+var Users = NewRepository[User](RepoConfig{
+	SortFields: []string{"created_at", "updated_at", "name", "email"},
+})
 
-	return u, nil
+// GetUser 获取用户，ctx 中需要带有租户信息
+func GetUser(ctx context.Context, uid string) (*User, error) {
+	return Users.Get(ctx, uid)
 }
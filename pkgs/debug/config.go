@@ -0,0 +1,25 @@
+package debug
+
+import "night-fury/pkgs/config"
+
+// Config 调试服务配置，设置了 Token 或 Username 时需要鉴权
+type Config struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Addr     string        `mapstructure:"addr" default:"127.0.0.1:6060"`
+	Token    config.Secret `mapstructure:"token"` // Authorization: Bearer <token>
+	Username string        `mapstructure:"username"`
+	Password config.Secret `mapstructure:"password"` // basic auth
+}
+
+// LoadConfig 读取 debug 下的配置
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	if err := config.Unmarshal("debug", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) authEnabled() bool {
+	return c.Token != "" || c.Username != ""
+}
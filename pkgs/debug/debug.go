@@ -0,0 +1,163 @@
+package debug
+
+// 调试服务，与业务端口分开，默认只监听本机
+//   /debug/pprof/       pprof
+//   /debug/vars         expvar
+//   /debug/goroutines   所有 goroutine 的堆栈
+//   /debug/config       当前配置，敏感值替换为 ******
+//   /debug/stats        RegisterStats 注册的运行状态，如 ws 连接
+// 设置了 token 或 basic auth 时所有接口都需要鉴权
+
+import (
+	"context"
+	"crypto/subtle"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/log"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+var (
+	statsMu sync.RWMutex
+	stats   = map[string]func() interface{}{}
+)
+
+// RegisterStats 注册 /debug/stats 中的一项，f 在请求时调用
+func RegisterStats(name string, f func() interface{}) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	stats[name] = f
+}
+
+// Server 调试服务
+type Server struct {
+	conf *Config
+	srv  *http.Server
+}
+
+func NewServer(conf *Config) *Server {
+	s := &Server{conf: conf}
+	s.srv = &http.Server{Addr: conf.Addr, Handler: s.auth(s.mux()), ReadHeaderTimeout: time.Second * 10}
+	return s
+}
+
+// Start 在后台监听，未开启时不做任何事
+func (s *Server) Start() error {
+	if !s.conf.Enabled {
+		return nil
+	}
+	ln, err := net.Listen("tcp", s.conf.Addr)
+	if err != nil {
+		return errors.Wrap(err, "listen debug server")
+	}
+	if !s.conf.authEnabled() {
+		log.Warnf(log.TagServer, "debug server listening on %s without auth", ln.Addr())
+	} else {
+		log.Infof(log.TagServer, "debug server listening on %s", ln.Addr())
+	}
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorf(log.TagServer, "debug server error : %s", err)
+		}
+	}()
+	return nil
+}
+
+// Close 关闭服务
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
+
+// Handler 所有调试接口，包含鉴权
+func (s *Server) Handler() http.Handler {
+	return s.srv.Handler
+}
+
+func (s *Server) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/goroutines", goroutines)
+	mux.HandleFunc("/debug/config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"profile": config.Profile(),
+			"files":   config.Files(),
+			"config":  config.Redacted(),
+		})
+	})
+	mux.HandleFunc("/debug/stats", func(w http.ResponseWriter, r *http.Request) {
+		statsMu.RLock()
+		res := make(map[string]interface{}, len(stats))
+		for name, f := range stats {
+			res[name] = f()
+		}
+		statsMu.RUnlock()
+		writeJSON(w, res)
+	})
+	return mux
+}
+
+func (s *Server) auth(next http.Handler) http.Handler {
+	if !s.conf.authEnabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.authorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.conf.Username != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="debug"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if token := s.conf.Token.Value(); token != "" {
+		h := r.Header.Get("Authorization")
+		if strings.HasPrefix(h, "Bearer ") && secureEqual(strings.TrimPrefix(h, "Bearer "), token) {
+			return true
+		}
+	}
+	if s.conf.Username != "" {
+		user, pass, ok := r.BasicAuth()
+		if ok && secureEqual(user, s.conf.Username) && secureEqual(pass, s.conf.Password.Value()) {
+			return true
+		}
+	}
+	return false
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// goroutines 所有 goroutine 的完整堆栈，与 panic 时的格式相同
+func goroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := jsoniter.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf(log.TagServer, "write debug response error : %s", err)
+	}
+}
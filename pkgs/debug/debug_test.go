@@ -0,0 +1,42 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	RegisterStats("test", func() interface{} { return map[string]int{"n": 1} })
+	s := NewServer(&Config{Token: "t0ken", Username: "admin", Password: "p4ss"})
+
+	get := func(path string, set func(r *http.Request)) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		if set != nil {
+			set(r)
+		}
+		s.Handler().ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("/debug/stats", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, get("/debug/stats", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }).Code)
+	assert.Equal(t, http.StatusUnauthorized, get("/debug/stats", func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }).Code)
+
+	w := get("/debug/stats", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") })
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"test":{"n":1}}`, w.Body.String())
+
+	w = get("/debug/goroutines", func(r *http.Request) { r.SetBasicAuth("admin", "p4ss") })
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine")
+
+	// 未配置鉴权时直接访问
+	s = NewServer(&Config{})
+	assert.Equal(t, http.StatusOK, get("/debug/vars", nil).Code)
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/", nil).Code)
+	assert.Equal(t, http.StatusOK, get("/debug/config", nil).Code)
+}
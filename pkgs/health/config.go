@@ -0,0 +1,32 @@
+package health
+
+import (
+	"night-fury/pkgs/config"
+	"time"
+)
+
+// Config 健康检查配置
+type Config struct {
+	Timeout  time.Duration `mapstructure:"timeout" default:"3s"`   // 单个检查项的默认超时
+	CacheTTL time.Duration `mapstructure:"cache_ttl" default:"5s"` // 检查结果的缓存时间， 0 时不缓存
+
+	// 应用开始关闭后 readyz 返回 503 ，等待 DrainDelay 再继续关闭，让负载均衡有时间摘除实例
+	DrainDelay time.Duration `mapstructure:"drain_delay"`
+
+	Disk DiskConfig `mapstructure:"disk"`
+}
+
+// DiskConfig 磁盘剩余空间检查，日志等文件所在的目录
+type DiskConfig struct {
+	Path    string `mapstructure:"path" default:"."`
+	MinFree int64  `mapstructure:"min_free" default:"100"` // MB ， 0 时不检查
+}
+
+// LoadConfig 读取 health 下的配置
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	if err := config.Unmarshal("health", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,40 @@
+package health
+
+import (
+	"context"
+	"time"
+)
+
+var defaultRegistry = NewRegistry(&Config{Timeout: time.Second * 3, CacheTTL: time.Second * 5})
+
+// Init 设置默认注册表的配置，并注册磁盘检查
+func Init(conf *Config) error {
+	defaultRegistry.mu.Lock()
+	defaultRegistry.conf = *conf
+	defaultRegistry.mu.Unlock()
+
+	if conf.Disk.MinFree > 0 {
+		return Register("disk", DiskChecker(conf.Disk.Path, uint64(conf.Disk.MinFree)<<20))
+	}
+	return nil
+}
+
+func Register(name string, check CheckFunc, opts ...Option) error {
+	return defaultRegistry.Register(name, check, opts...)
+}
+
+func Check(ctx context.Context) *Report {
+	return defaultRegistry.Check(ctx)
+}
+
+func Ready(ctx context.Context) *Report {
+	return defaultRegistry.Ready(ctx)
+}
+
+func Drain() error {
+	return defaultRegistry.Drain()
+}
+
+func Draining() bool {
+	return defaultRegistry.Draining()
+}
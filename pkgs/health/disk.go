@@ -0,0 +1,25 @@
+//go:build !windows
+
+package health
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// DiskChecker path 所在磁盘的可用空间少于 minFree 字节时不可用
+func DiskChecker(path string, minFree uint64) CheckFunc {
+	return func(ctx context.Context) error {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(path, &st); err != nil {
+			return errors.Wrapf(err, "statfs %s", path)
+		}
+		free := st.Bavail * uint64(st.Bsize)
+		if free < minFree {
+			return errors.Errorf("disk free space of %s is %d MB, less than %d MB", path, free>>20, minFree>>20)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,10 @@
+package health
+
+import "context"
+
+// DiskChecker windows 下不检查
+func DiskChecker(path string, minFree uint64) CheckFunc {
+	return func(ctx context.Context) error {
+		return nil
+	}
+}
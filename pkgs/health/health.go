@@ -0,0 +1,220 @@
+package health
+
+// 健康检查
+//   /ping     存活检查，进程能处理请求即返回 ok
+//   /healthz  执行所有检查项，任意必需的检查失败时返回 503
+//   /readyz   同 /healthz ，另外在应用开始关闭后(Drain)返回 503 ，负载均衡据此摘除实例
+// 检查结果缓存 CacheTTL ，频繁的探测不会每次都访问依赖
+//   health.Register("db", db.HealthCheck)
+//   health.Register("disk", health.DiskChecker("/data", 500<<20), health.Optional())
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+var (
+	ErrCheckerExist = errors.New("health checker already exist")
+	ErrTimeout      = errors.New("health check timeout")
+	ErrDraining     = errors.New("server is shutting down")
+)
+
+// CheckFunc 检查依赖是否可用，需要在 ctx 取消时返回
+type CheckFunc func(ctx context.Context) error
+
+// Result 一个检查项的结果
+type Result struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Optional  bool      `json:"optional,omitempty"` // 失败时不影响整体状态
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report 所有检查项的结果
+type Report struct {
+	Status   string             `json:"status"`
+	Draining bool               `json:"draining,omitempty"`
+	Checks   map[string]*Result `json:"checks"`
+}
+
+// Up 整体是否可用
+func (r *Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+type Option func(c *checker)
+
+// WithTimeout 检查项的超时，默认为 Config.Timeout
+func WithTimeout(d time.Duration) Option {
+	return func(c *checker) {
+		c.timeout = d
+	}
+}
+
+// Optional 检查失败时只在结果中体现，不影响整体状态
+func Optional() Option {
+	return func(c *checker) {
+		c.optional = true
+	}
+}
+
+type checker struct {
+	name     string
+	check    CheckFunc
+	timeout  time.Duration
+	optional bool
+
+	mu       sync.Mutex // 同时只执行一次，其他请求等待并使用它的结果
+	result   *Result
+	expireAt time.Time
+}
+
+// Registry 检查项注册表，并发安全
+type Registry struct {
+	conf     Config
+	mu       sync.RWMutex
+	checkers []*checker
+	draining int32
+}
+
+func NewRegistry(conf *Config) *Registry {
+	return &Registry{conf: *conf}
+}
+
+// Register 注册检查项，需要在处理请求之前注册
+func (r *Registry) Register(name string, check CheckFunc, opts ...Option) error {
+	c := &checker{name: name, check: check}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, exist := range r.checkers {
+		if exist.name == name {
+			return errors.WithMessage(ErrCheckerExist, fmt.Sprintf("name : %s", name))
+		}
+	}
+	r.checkers = append(r.checkers, c)
+	return nil
+}
+
+// Check 并发执行所有检查项
+func (r *Registry) Check(ctx context.Context) *Report {
+	r.mu.RLock()
+	checkers, conf := r.checkers, r.conf
+	r.mu.RUnlock()
+
+	results := make([]*Result, len(checkers))
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func(i int, c *checker) {
+			defer wg.Done()
+			results[i] = c.run(ctx, &conf)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusUp, Checks: make(map[string]*Result, len(checkers))}
+	for i, c := range checkers {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusUp && !c.optional {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// Ready 在 Check 的基础上，Drain 之后总是不可用
+func (r *Registry) Ready(ctx context.Context) *Report {
+	report := r.Check(ctx)
+	if r.Draining() {
+		report.Status = StatusDown
+		report.Draining = true
+	}
+	return report
+}
+
+// Drain 标记为正在关闭并等待 DrainDelay ，作为 health 组件的 Stop hook ，在其他组件关闭之前执行
+func (r *Registry) Drain() error {
+	r.mu.RLock()
+	delay := r.conf.DrainDelay
+	r.mu.RUnlock()
+
+	if atomic.SwapInt32(&r.draining, 1) == 0 && delay > 0 {
+		time.Sleep(delay)
+	}
+	return nil
+}
+
+// Draining 是否已经开始关闭
+func (r *Registry) Draining() bool {
+	return atomic.LoadInt32(&r.draining) == 1
+}
+
+func (c *checker) run(ctx context.Context, conf *Config) *Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.result != nil && time.Now().Before(c.expireAt) {
+		return c.result
+	}
+
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = conf.Timeout
+	}
+	start := time.Now()
+	err := runWithTimeout(ctx, c.check, timeout)
+
+	res := &Result{Status: StatusUp, Optional: c.optional, Duration: time.Since(start).String(), CheckedAt: start}
+	if err != nil {
+		res.Status, res.Error = StatusDown, err.Error()
+	}
+	// 请求取消导致的失败不缓存
+	if ctx.Err() == nil {
+		c.result, c.expireAt = res, start.Add(conf.CacheTTL)
+	}
+	return res
+}
+
+// runWithTimeout 超时后不再等待 check 返回
+func runWithTimeout(ctx context.Context, check CheckFunc, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				done <- errors.Errorf("health check panic : %v", e)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrTimeout
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(&Config{Timeout: time.Millisecond * 50, CacheTTL: time.Minute})
+
+	var calls int32
+	assert.Nil(t, r.Register("db", func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}))
+	assert.Nil(t, r.Register("cache", func(ctx context.Context) error {
+		return errors.New("refused")
+	}, Optional()))
+	assert.ErrorIs(t, r.Register("db", nil), ErrCheckerExist)
+
+	report := r.Check(context.Background())
+	assert.True(t, report.Up())
+	assert.Equal(t, StatusDown, report.Checks["cache"].Status)
+	assert.Equal(t, "refused", report.Checks["cache"].Error)
+
+	// 结果被缓存
+	r.Check(context.Background())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// 不响应 ctx 的检查也会超时
+	assert.Nil(t, r.Register("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}))
+	start := time.Now()
+	report = r.Check(context.Background())
+	assert.False(t, report.Up())
+	assert.Equal(t, ErrTimeout.Error(), report.Checks["slow"].Error)
+	assert.Less(t, time.Since(start), time.Millisecond*500)
+
+	// 开始关闭后不再就绪
+	r = NewRegistry(&Config{})
+	assert.True(t, r.Ready(context.Background()).Up())
+	assert.Nil(t, r.Drain())
+	report = r.Ready(context.Background())
+	assert.False(t, report.Up())
+	assert.True(t, report.Draining)
+	assert.True(t, r.Check(context.Background()).Up())
+
+	assert.NotNil(t, DiskChecker(t.TempDir(), 1<<62)(context.Background()))
+	assert.Nil(t, DiskChecker(t.TempDir(), 1)(context.Background()))
+}
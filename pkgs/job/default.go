@@ -0,0 +1,89 @@
+package job
+
+import (
+	"context"
+	"night-fury/pkgs/lock"
+	"sync"
+)
+
+var (
+	defaultWorker    *Worker
+	defaultScheduler *Scheduler
+	mu               sync.RWMutex
+
+	// 通过 Handle/Schedule 注册的处理函数和定时任务，可以在 Init 之前（例如各个包的 init 中）注册，Init 时添加到新建的 Worker 和 Scheduler
+	handlers  = make(map[string]Handler)
+	schedules []*entry
+)
+
+// Init 初始化默认的 Worker 和 Scheduler ， locker 用于定时任务选举 leader
+func Init(conf *WorkerConfig, locker lock.Locker) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	defaultWorker = NewWorker(conf)
+	for jobType, h := range handlers {
+		defaultWorker.Handle(jobType, h)
+	}
+	defaultScheduler = NewScheduler(locker)
+	defaultScheduler.entries = append(defaultScheduler.entries, schedules...)
+}
+
+// Handle 注册任务处理函数，Init 前后都可以调用
+func Handle(jobType string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	handlers[jobType] = h
+	if defaultWorker != nil {
+		defaultWorker.Handle(jobType, h)
+	}
+}
+
+// Schedule 添加定时任务，需要在 Start 之前调用，Init 前后都可以调用
+func Schedule(name, spec, jobType string, payload interface{}, opts ...Option) error {
+	e, err := newEntry(name, spec, jobType, payload, opts...)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if schedules, err = addEntry(schedules, e); err != nil {
+		return err
+	}
+	if defaultScheduler != nil {
+		defaultScheduler.mu.Lock()
+		defaultScheduler.entries, err = addEntry(defaultScheduler.entries, e)
+		defaultScheduler.mu.Unlock()
+	}
+	return err
+}
+
+// Start 启动默认的 Worker 和 Scheduler
+func Start(ctx context.Context) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if defaultWorker != nil {
+		defaultWorker.Start(ctx)
+	}
+	if defaultScheduler != nil {
+		defaultScheduler.Start()
+	}
+}
+
+// Stop 停止定时任务并等待正在执行的任务完成，作为 job 组件的 Stop hook
+func Stop() error {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if defaultScheduler != nil {
+		defaultScheduler.Stop()
+	}
+	if defaultWorker != nil {
+		return defaultWorker.Stop()
+	}
+	return nil
+}
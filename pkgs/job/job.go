@@ -0,0 +1,197 @@
+package job
+
+// 后台任务
+//   job.Enqueue(ctx, "send_email", payload, job.Delay(time.Minute), job.Unique("email:"+uid))
+//
+//   conf, err := job.LoadWorkerConfig()
+//   w := job.NewWorker(conf)
+//   w.Handle("send_email", func(ctx context.Context, j *job.Job) error { ... })
+//   w.Start(ctx)
+//
+// 任务保存在 db 的 jobs 表中，在 db.WithTx 中入队时与业务数据一起提交
+// 多个实例通过 FOR UPDATE SKIP LOCKED 领取任务，失败时按指数退避重试，超过次数后标记为 failed
+// 定时任务见 Scheduler ，只在 leader 实例上入队
+
+import (
+	"context"
+	"night-fury/pkgs/db"
+	"night-fury/pkgs/utils"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+
+	DefaultQueue = "default"
+)
+
+var (
+	ErrJobExists = errors.New("unique job already exists")
+	ErrNotInit   = errors.New("job not init")
+)
+
+func init() {
+	db.SkipAudit(Job{}.TableName())
+}
+
+// Job 任务
+type Job struct {
+	ID          string     `gorm:"primarykey" json:"id"`
+	TenantID    string     `gorm:"type:varchar(64);index;uniqueIndex:idx_job_unique,priority:1" json:"tenantID"`
+	Queue       string     `gorm:"type:varchar(100);index:idx_jobs_fetch" json:"queue"`
+	Type        string     `gorm:"type:varchar(100);index" json:"type"`
+	Payload     string     `gorm:"type:text" json:"payload"`
+	Status      string     `gorm:"type:varchar(20);index:idx_jobs_fetch" json:"status"`
+	UniqueKey   *string    `gorm:"type:varchar(200);uniqueIndex:idx_job_unique,priority:2" json:"uniqueKey"` // 租户内未完成的任务中唯一，完成后置空
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"maxAttempts"`
+	LastError   string     `gorm:"type:text" json:"lastError"`
+	RunAt       time.Time  `gorm:"index:idx_jobs_fetch" json:"runAt"`
+	LockedBy    string     `gorm:"type:varchar(100)" json:"lockedBy"`
+	LockedUntil *time.Time `json:"lockedUntil"`
+	FinishedAt  *time.Time `json:"finishedAt"`
+	CreatedAt   time.Time  `gorm:"index" json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// Bind 解析 Payload
+func (j *Job) Bind(v interface{}) error {
+	return jsoniter.UnmarshalFromString(j.Payload, v)
+}
+
+// Jobs 任务的 repository
+var Jobs = db.NewRepository[Job](db.RepoConfig{
+	SortFields:  []string{"created_at", "run_at", "updated_at"},
+	DefaultSort: db.SortCond{Field: "created_at", Sort: "desc"},
+})
+
+// Option 入队选项
+type Option func(j *Job)
+
+// Queue 指定队列，默认为 default
+func Queue(name string) Option {
+	return func(j *Job) { j.Queue = name }
+}
+
+// Delay 延迟执行
+func Delay(d time.Duration) Option {
+	return func(j *Job) { j.RunAt = time.Now().Add(d) }
+}
+
+// At 在指定时间执行
+func At(t time.Time) Option {
+	return func(j *Job) { j.RunAt = t }
+}
+
+// MaxAttempts 最大执行次数，默认 5
+func MaxAttempts(n int) Option {
+	return func(j *Job) { j.MaxAttempts = n }
+}
+
+// Unique 相同 key 的任务未完成时，不能重复入队(返回 ErrJobExists)
+func Unique(key string) Option {
+	return func(j *Job) { j.UniqueKey = &key }
+}
+
+// Enqueue 入队，payload 以 json 保存，在 db.WithTx 中调用时与事务一起提交
+func Enqueue(ctx context.Context, jobType string, payload interface{}, opts ...Option) (*Job, error) {
+	p, err := jsoniter.MarshalToString(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal job payload")
+	}
+
+	j := &Job{
+		ID:          utils.GetID(),
+		Queue:       DefaultQueue,
+		Type:        jobType,
+		Payload:     p,
+		Status:      StatusPending,
+		MaxAttempts: 5,
+		RunAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	tx := db.Conn(ctx)
+	if j.UniqueKey != nil {
+		tx = tx.Clauses(clause.OnConflict{DoNothing: true})
+	}
+	res := tx.Create(j)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.WithMessage(ErrJobExists, *j.UniqueKey)
+	}
+	return j, nil
+}
+
+// Retry 重新执行失败的任务
+func Retry(ctx context.Context, id string) error {
+	res := db.Conn(ctx).Model(&Job{}).
+		Where("id = ? AND status = ?", id, StatusFailed).
+		Updates(map[string]interface{}{
+			"status":       StatusPending,
+			"attempts":     0,
+			"run_at":       time.Now(),
+			"finished_at":  nil,
+			"locked_by":    "",
+			"locked_until": nil,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return db.Nil
+	}
+	return nil
+}
+
+// Cancel 取消未开始的任务
+func Cancel(ctx context.Context, id string) error {
+	now := time.Now()
+	res := db.Conn(ctx).Model(&Job{}).
+		Where("id = ? AND status = ?", id, StatusPending).
+		Updates(map[string]interface{}{
+			"status":      StatusFailed,
+			"last_error":  "canceled",
+			"unique_key":  nil,
+			"finished_at": now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return db.Nil
+	}
+	return nil
+}
+
+// Stats 各状态的任务数量
+func Stats(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := db.Conn(ctx).Model(&Job{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	stats := map[string]int64{StatusPending: 0, StatusRunning: 0, StatusDone: 0, StatusFailed: 0}
+	for _, row := range rows {
+		stats[row.Status] = row.Count
+	}
+	return stats, nil
+}
@@ -0,0 +1,141 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"night-fury/pkgs/db"
+	"night-fury/pkgs/lock"
+	"night-fury/pkgs/tenant"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func initTestDB(t *testing.T) context.Context {
+	assert.Nil(t, db.Init(context.Background(), &db.Config{
+		Driver: db.DriverSQLite,
+		DSN:    fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+	}))
+	_, err := db.MigrateUp(context.Background(), 0)
+	assert.Nil(t, err)
+	return tenant.WithID(context.Background(), "tenant1")
+}
+
+func TestWorker(t *testing.T) {
+	ctx := initTestDB(t)
+
+	_, err := Enqueue(ctx, "echo", map[string]string{"v": "1"}, Unique("echo1"))
+	assert.Nil(t, err)
+	_, err = Enqueue(ctx, "echo", map[string]string{"v": "1"}, Unique("echo1"))
+	assert.ErrorIs(t, err, ErrJobExists)
+	_, err = Enqueue(ctx, "echo", map[string]string{"v": "2"}, Delay(time.Millisecond*100))
+	assert.Nil(t, err)
+	failing, err := Enqueue(ctx, "fail", nil, MaxAttempts(2))
+	assert.Nil(t, err)
+
+	var mu sync.Mutex
+	var got []string
+	w := NewWorker(&WorkerConfig{Concurrency: 2, PollInterval: time.Millisecond * 10, RetryBackoff: time.Millisecond * 10})
+	w.Handle("echo", func(ctx context.Context, j *Job) error {
+		tid, _ := tenant.FromContext(ctx)
+		assert.Equal(t, "tenant1", tid)
+		var p map[string]string
+		assert.Nil(t, j.Bind(&p))
+		mu.Lock()
+		got = append(got, p["v"])
+		mu.Unlock()
+		return nil
+	})
+	var fails int32
+	w.Handle("fail", func(ctx context.Context, j *Job) error {
+		atomic.AddInt32(&fails, 1)
+		return errors.New("boom")
+	})
+	w.Start(context.Background())
+
+	// 以 db 中的状态为准，处理函数返回后状态才会更新
+	assert.Eventually(t, func() bool {
+		var n int64
+		err := db.Conn(ctx).Model(&Job{}).Where("status IN ?", []string{StatusDone, StatusFailed}).Count(&n).Error
+		return err == nil && n == 3
+	}, time.Second*5, time.Millisecond*10)
+	assert.Nil(t, w.Stop())
+	assert.Equal(t, []string{"1", "2"}, got)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fails))
+
+	// 完成后可以再次入队相同 key 的任务
+	_, err = Enqueue(ctx, "echo", nil, Unique("echo1"))
+	assert.Nil(t, err)
+
+	j, err := Jobs.Get(ctx, failing.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusFailed, j.Status)
+	assert.Equal(t, "boom", j.LastError)
+	assert.Nil(t, Retry(ctx, failing.ID))
+	j, _ = Jobs.Get(ctx, failing.ID)
+	assert.Equal(t, StatusPending, j.Status)
+
+	// 其他租户不可见
+	_, err = Jobs.Get(tenant.WithID(context.Background(), "tenant2"), failing.ID)
+	assert.ErrorIs(t, err, db.Nil)
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := initTestDB(t)
+	locker := lock.NewMemoryLocker()
+
+	// 两个实例只有 leader 入队
+	s1 := NewScheduler(locker)
+	s2 := NewScheduler(locker)
+	for _, s := range []*Scheduler{s1, s2} {
+		assert.Nil(t, s.Add("tick", "* * * * * *", "tick", nil))
+		s.Start()
+	}
+	time.Sleep(time.Millisecond * 2500)
+	assert.Nil(t, s1.Stop())
+	assert.Nil(t, s2.Stop())
+
+	res, err := Jobs.List(tenant.WithSystem(ctx), db.ListQuery{Filters: []db.Filter{db.Eq("type", "tick")}})
+	assert.Nil(t, err)
+	assert.True(t, res.Total >= 2 && res.Total <= 3, "total %d", res.Total)
+	for _, j := range res.Items {
+		assert.Equal(t, "", j.TenantID)
+	}
+}
+
+func TestRegisterBeforeInit(t *testing.T) {
+	// 模拟在各个包的 init 中注册
+	h := func(ctx context.Context, j *Job) error { return nil }
+	Handle("early", h)
+	assert.Nil(t, Schedule("early", "@every 1m", "early", nil))
+	assert.NotNil(t, Schedule("early", "@every 1m", "early", nil))
+	assert.NotNil(t, Schedule("bad", "not a spec", "early", nil))
+
+	Init(&WorkerConfig{}, lock.NewMemoryLocker())
+	assert.NotNil(t, defaultWorker.handler("early"))
+	assert.Len(t, defaultScheduler.entries, 1)
+
+	// Init 之后注册的同样生效，重复 Init 不会丢失
+	Handle("late", h)
+	assert.Nil(t, Schedule("late", "@every 1m", "late", nil))
+	Init(&WorkerConfig{}, lock.NewMemoryLocker())
+	assert.NotNil(t, defaultWorker.handler("early"))
+	assert.NotNil(t, defaultWorker.handler("late"))
+	assert.Len(t, defaultScheduler.entries, 2)
+}
+
+func TestUniquePerTenant(t *testing.T) {
+	ctx1 := initTestDB(t)
+	ctx2 := tenant.WithID(context.Background(), "tenant2")
+
+	_, err := Enqueue(ctx1, "echo", nil, Unique("report"))
+	assert.Nil(t, err)
+	_, err = Enqueue(ctx2, "echo", nil, Unique("report"))
+	assert.Nil(t, err)
+	_, err = Enqueue(ctx2, "echo", nil, Unique("report"))
+	assert.ErrorIs(t, err, ErrJobExists)
+}
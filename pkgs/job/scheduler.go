@@ -0,0 +1,114 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"night-fury/pkgs/lock"
+	"night-fury/pkgs/log"
+	"night-fury/pkgs/tenant"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// schedulerLockKey 定时任务 leader 选举的 key
+const schedulerLockKey = "job:scheduler"
+
+// Scheduler 定时入队，只在 leader 实例上执行
+// 每次入队使用 cron:名称:计划时间 作为唯一 key ，切换 leader 时也不会重复入队
+type Scheduler struct {
+	elector *lock.LeaderElector
+
+	mu      sync.Mutex
+	entries []*entry
+}
+
+type entry struct {
+	name     string
+	schedule cron.Schedule
+	jobType  string
+	payload  interface{}
+	opts     []Option
+}
+
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// NewScheduler 创建定时任务，locker 用于选举 leader
+func NewScheduler(locker lock.Locker) *Scheduler {
+	return &Scheduler{
+		elector: lock.NewLeaderElector(locker, schedulerLockKey, time.Second*30),
+	}
+}
+
+func newEntry(name, spec, jobType string, payload interface{}, opts ...Option) (*entry, error) {
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse cron spec %s", spec)
+	}
+	return &entry{name: name, schedule: schedule, jobType: jobType, payload: payload, opts: opts}, nil
+}
+
+// addEntry 添加到 entries ，名称重复时报错
+func addEntry(entries []*entry, e *entry) ([]*entry, error) {
+	for _, old := range entries {
+		if old.name == e.name {
+			return entries, errors.Errorf("schedule %s already exists", e.name)
+		}
+	}
+	return append(entries, e), nil
+}
+
+// Add 添加定时任务，spec 为 cron 表达式(支持秒，例如 "*/10 * * * * *")或 @every 1m 等
+func (s *Scheduler) Add(name, spec, jobType string, payload interface{}, opts ...Option) error {
+	e, err := newEntry(name, spec, jobType, payload, opts...)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries, err = addEntry(s.entries, e)
+	return err
+}
+
+// Start 开始竞选，成为 leader 后按计划入队
+func (s *Scheduler) Start() {
+	s.elector.Start(func(ctx context.Context, token int64) {
+		c := cron.New(cron.WithParser(cronParser))
+
+		s.mu.Lock()
+		for _, e := range s.entries {
+			e := e
+			c.Schedule(e.schedule, cron.FuncJob(func() {
+				s.enqueue(ctx, e, time.Now())
+			}))
+		}
+		s.mu.Unlock()
+
+		c.Start()
+		<-ctx.Done()
+		<-c.Stop().Done()
+	})
+}
+
+func (s *Scheduler) enqueue(ctx context.Context, e *entry, now time.Time) {
+	if ctx.Err() != nil {
+		return
+	}
+	// cron 在整秒触发，截断到秒后不同实例计算出相同的唯一 key
+	at := now.Truncate(time.Second)
+	opts := append([]Option{Unique(fmt.Sprintf("cron:%s:%d", e.name, at.Unix()))}, e.opts...)
+
+	_, err := Enqueue(tenant.WithSystem(ctx), e.jobType, e.payload, opts...)
+	if err != nil && !errors.Is(err, ErrJobExists) {
+		log.Errorf(log.TagJob, "enqueue scheduled job %s error : %s", e.name, err)
+	}
+}
+
+// Stop 停止定时任务并交出 leader ，其他实例可以接管
+func (s *Scheduler) Stop() error {
+	return s.elector.Stop()
+}
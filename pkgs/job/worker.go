@@ -0,0 +1,337 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/db"
+	"night-fury/pkgs/log"
+	"night-fury/pkgs/tenant"
+	"night-fury/pkgs/utils"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Handler 任务处理函数，返回 error 时重试
+type Handler func(ctx context.Context, j *Job) error
+
+// WorkerConfig worker 配置
+type WorkerConfig struct {
+	Queues       []string      `mapstructure:"queues"`                       // 处理的队列，默认 default
+	Concurrency  int           `mapstructure:"concurrency" validate:"gte=0"` // 同时执行的任务数
+	PollInterval time.Duration `mapstructure:"poll_interval"`                // 没有任务时的轮询间隔
+	Timeout      time.Duration `mapstructure:"timeout"`                      // 单个任务的超时时间，超时后可以被其他 worker 重新领取
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`                // 重试间隔，按次数指数增长
+}
+
+// LoadWorkerConfig 读取 job 下的配置
+func LoadWorkerConfig() (*WorkerConfig, error) {
+	c := &WorkerConfig{}
+	if err := config.Unmarshal("job", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *WorkerConfig) setDefaults() {
+	if len(c.Queues) == 0 {
+		c.Queues = []string{DefaultQueue}
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 10
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = time.Minute * 10
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = time.Second * 10
+	}
+}
+
+// maxBackoff 重试间隔的上限
+const maxBackoff = time.Hour
+
+// Worker 领取并执行任务
+type Worker struct {
+	id   string
+	conf *WorkerConfig
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	slots  chan struct{} // 控制并发
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWorker 创建 worker ，需要注册 Handler 后调用 Start
+func NewWorker(conf *WorkerConfig) *Worker {
+	conf.setDefaults()
+
+	host, _ := os.Hostname()
+	return &Worker{
+		id:       fmt.Sprintf("%s:%s", host, utils.GetID()),
+		conf:     conf,
+		handlers: make(map[string]Handler),
+		slots:    make(chan struct{}, conf.Concurrency),
+	}
+}
+
+// Handle 注册任务类型的处理函数，worker 只领取已注册类型的任务
+func (w *Worker) Handle(jobType string, h Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.handlers[jobType] = h
+}
+
+func (w *Worker) types() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	types := make([]string, 0, len(w.handlers))
+	for t := range w.handlers {
+		types = append(types, t)
+	}
+	return types
+}
+
+func (w *Worker) handler(jobType string) Handler {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.handlers[jobType]
+}
+
+// Start 在后台领取并执行任务
+func (w *Worker) Start(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		return
+	}
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	w.wg.Add(1)
+	go w.loop(ctx)
+}
+
+// Stop 停止领取新任务，并等待正在执行的任务完成
+func (w *Worker) Stop() error {
+	w.mu.Lock()
+	cancel := w.cancel
+	w.cancel = nil
+	w.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		w.wg.Wait()
+	}
+	return nil
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	defer w.wg.Done()
+
+	for {
+		n, err := w.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Errorf(log.TagJob, "fetch jobs error : %s", err)
+		}
+
+		// 领取满了并发数时等待空闲，否则等待下次轮询
+		wait := w.conf.PollInterval
+		if n > 0 && len(w.slots) < cap(w.slots) {
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case w.slots <- struct{}{}: // 等待至少有一个空闲
+			<-w.slots
+		}
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// RunOnce 按空闲的并发数领取任务并在后台执行，返回领取的任务数
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	free := cap(w.slots) - len(w.slots)
+	types := w.types()
+	if free <= 0 || len(types) == 0 {
+		return 0, nil
+	}
+
+	jobs, err := w.claim(ctx, types, free)
+	if err != nil {
+		return 0, err
+	}
+	for _, j := range jobs {
+		w.slots <- struct{}{}
+		w.wg.Add(1)
+		go func(j *Job) {
+			defer func() {
+				<-w.slots
+				w.wg.Done()
+			}()
+			w.run(j)
+		}(j)
+	}
+	return len(jobs), nil
+}
+
+// workerContext worker 需要处理所有租户的任务，并且读写都在主库
+func workerContext(ctx context.Context) context.Context {
+	return tenant.WithSystem(db.WithPrimary(ctx))
+}
+
+// claim 领取到期的任务，以及超时未完成的任务
+func (w *Worker) claim(ctx context.Context, types []string, limit int) ([]*Job, error) {
+	var jobs []*Job
+	err := db.WithTx(workerContext(ctx), func(ctx context.Context) error {
+		now := time.Now()
+		tx := db.Conn(ctx)
+
+		q := tx.Where("queue IN ? AND type IN ?", w.conf.Queues, types).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)", StatusPending, now, StatusRunning, now).
+			Order("run_at").Limit(limit)
+		if db.Dialect() != db.DriverSQLite {
+			q = q.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := q.Find(&jobs).Error; err != nil {
+			return err
+		}
+
+		lockedUntil := now.Add(w.conf.Timeout)
+		for _, j := range jobs {
+			j.Status = StatusRunning
+			j.Attempts++
+			j.LockedBy = w.id
+			j.LockedUntil = &lockedUntil
+			err := tx.Model(j).Updates(map[string]interface{}{
+				"status":       j.Status,
+				"attempts":     j.Attempts,
+				"locked_by":    j.LockedBy,
+				"locked_until": j.LockedUntil,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// run 执行任务，ctx 带有任务的租户，没有租户的任务(例如定时任务)以系统身份执行
+func (w *Worker) run(j *Job) {
+	ctx := context.Background()
+	if j.TenantID != "" {
+		ctx = tenant.WithID(ctx, j.TenantID)
+	} else {
+		ctx = tenant.WithSystem(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.conf.Timeout)
+	defer cancel()
+
+	var err error
+	h := w.handler(j.Type)
+	if h == nil {
+		err = fmt.Errorf("no handler for job type %s", j.Type)
+	} else if panicErr := utils.SafeRun(nil, func() {
+		err = h(ctx, j)
+	}); panicErr != nil {
+		err = panicErr
+	}
+
+	if err := w.finish(j, err); err != nil {
+		log.Errorf(log.TagJob, "update job %s error : %s", j.ID, err)
+	}
+}
+
+const (
+	finishTimeout    = time.Second * 30 // 更新任务结果的总时间，与任务的超时无关
+	finishBackoff    = time.Millisecond * 100
+	finishMaxBackoff = time.Second * 5
+)
+
+// finish 更新任务的结果，失败时按指数间隔重试，避免任务一直处于 running 直到 locked_until 后被重复执行
+// 任务已被其他 worker 重新领取时不更新
+func (w *Worker) finish(j *Job, runErr error) error {
+	ctx, cancel := context.WithTimeout(workerContext(context.Background()), finishTimeout)
+	defer cancel()
+
+	updates := w.result(j, runErr)
+	wait := finishBackoff
+	for {
+		err := db.Conn(ctx).Model(&Job{}).
+			Where("id = ? AND locked_by = ? AND status = ?", j.ID, w.id, StatusRunning).
+			Updates(updates).Error
+		if err == nil {
+			return nil
+		}
+		log.Warnf(log.TagJob, "update job %s error, retry in %s : %s", j.ID, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > finishMaxBackoff {
+			wait = finishMaxBackoff
+		}
+	}
+}
+
+// result 任务执行结果对应的更新
+func (w *Worker) result(j *Job, runErr error) map[string]interface{} {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_by":    "",
+		"locked_until": nil,
+	}
+
+	switch {
+	case runErr == nil:
+		updates["status"] = StatusDone
+		updates["unique_key"] = nil
+		updates["finished_at"] = now
+	case j.Attempts >= j.MaxAttempts:
+		log.Errorf(log.TagJob, "job %s %s failed after %d attempts : %s", j.ID, j.Type, j.Attempts, runErr)
+		updates["status"] = StatusFailed
+		updates["unique_key"] = nil
+		updates["finished_at"] = now
+		updates["last_error"] = runErr.Error()
+	default:
+		log.Warnf(log.TagJob, "job %s %s error, attempt %d : %s", j.ID, j.Type, j.Attempts, runErr)
+		updates["status"] = StatusPending
+		updates["run_at"] = now.Add(backoff(w.conf.RetryBackoff, j.Attempts))
+		updates["last_error"] = runErr.Error()
+	}
+	return updates
+}
+
+// backoff 指数增长的重试间隔
+func backoff(base time.Duration, attempts int) time.Duration {
+	d := base
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
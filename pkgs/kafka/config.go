@@ -0,0 +1,52 @@
+package kafka
+
+import (
+	"night-fury/pkgs/config"
+	"time"
+)
+
+// Config kafka 配置
+type Config struct {
+	Brokers  []string `mapstructure:"brokers"`
+	ClientID string   `mapstructure:"client_id"`
+	GroupID  string   `mapstructure:"group_id"` // consumer group
+
+	// 发送失败时的重试次数和间隔， 0 时不重试
+	Retries      int           `mapstructure:"retries" default:"3" validate:"gte=0"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+
+	// 消费失败时的重试次数，超过后交给 Consumer.OnFailed 处理， 0 时不重试
+	ConsumeRetries int `mapstructure:"consume_retries" default:"3" validate:"gte=0"`
+
+	// 异步发送的缓冲区大小
+	AsyncBuffer int `mapstructure:"async_buffer" validate:"gte=0"`
+}
+
+// LoadConfig 读取 kafka 下的配置
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	if err := config.Unmarshal("kafka", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Enabled 是否配置了 kafka
+func (c *Config) Enabled() bool {
+	return len(c.Brokers) > 0
+}
+
+func (c *Config) setDefaults() {
+	if c.ClientID == "" {
+		c.ClientID = "night-fury"
+	}
+	if c.GroupID == "" {
+		c.GroupID = "night-fury"
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = time.Millisecond * 100
+	}
+	if c.AsyncBuffer <= 0 {
+		c.AsyncBuffer = 1000
+	}
+}
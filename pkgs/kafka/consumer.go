@@ -0,0 +1,211 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"night-fury/pkgs/log"
+	"night-fury/pkgs/tracing"
+	"night-fury/pkgs/utils"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrHandlerExist    = errors.New("kafka handler already exist")
+	ErrConsumerRunning = errors.New("kafka consumer is running")
+)
+
+// Consumer 以 consumer group 消费消息，每个 topic 一个 goroutine ，同一个 partition 内按顺序处理
+type Consumer struct {
+	transport Transport
+	groupID   string
+	retries   int
+	backoff   time.Duration
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	onFailed func(ctx context.Context, msg *Message, err error) error
+	running  bool
+	cancel   context.CancelFunc
+	readers  []Reader
+	wg       sync.WaitGroup
+}
+
+// NewConsumer 创建 Consumer ，需要注册 Handler 后调用 Start
+func NewConsumer(t Transport, conf *Config) *Consumer {
+	conf.setDefaults()
+
+	return &Consumer{
+		transport: t,
+		groupID:   conf.GroupID,
+		retries:   conf.ConsumeRetries,
+		backoff:   conf.RetryBackoff,
+		handlers:  make(map[string]Handler),
+	}
+}
+
+// Handle 注册 topic 的处理函数，需要在 Start 之前调用
+func (c *Consumer) Handle(topic string, h Handler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.running {
+		return ErrConsumerRunning
+	}
+	if _, ok := c.handlers[topic]; ok {
+		return errors.WithMessage(ErrHandlerExist, fmt.Sprintf("topic : %s", topic))
+	}
+	c.handlers[topic] = h
+	return nil
+}
+
+// OnFailed 重试多次仍失败时的处理，返回 nil 时提交 offset 跳过该消息(例如写入死信队列)
+// 未设置或返回 error 时会一直重试，保证消息不丢失
+func (c *Consumer) OnFailed(f func(ctx context.Context, msg *Message, err error) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onFailed = f
+}
+
+// Start 开始消费，ctx 会传递给 Handler
+func (c *Consumer) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.running {
+		return ErrConsumerRunning
+	}
+	c.running = true
+
+	runCtx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	for topic, h := range c.handlers {
+		r := c.transport.NewReader(c.groupID, topic)
+		c.readers = append(c.readers, r)
+
+		c.wg.Add(1)
+		go c.consume(ctx, runCtx, r, topic, h)
+	}
+	log.Infof(log.TagKafka, "kafka consumer started, group : %s, topics : %d", c.groupID, len(c.handlers))
+	return nil
+}
+
+// consume 持续拉取消息，ctx 用于处理消息，runCtx 在 Stop 时取消，停止拉取新消息
+func (c *Consumer) consume(ctx, runCtx context.Context, r Reader, topic string, h Handler) {
+	defer c.wg.Done()
+
+	for {
+		msg, err := r.FetchMessage(runCtx)
+		if err != nil {
+			if runCtx.Err() != nil {
+				return
+			}
+			log.Errorf(log.TagKafka, "fetch message of %s error : %s", topic, err)
+			if !sleep(runCtx, c.backoff) {
+				return
+			}
+			continue
+		}
+
+		msgCtx, span := tracing.Start(tracing.Extract(ctx, msg.Headers), "kafka.consume",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingSystem("kafka"),
+				semconv.MessagingSourceName(topic),
+				semconv.MessagingKafkaSourcePartition(msg.Partition),
+				semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+				semconv.MessagingKafkaConsumerGroup(c.groupID),
+			),
+		)
+		err = c.process(msgCtx, runCtx, h, &msg)
+		tracing.End(span, err)
+		if err != nil {
+			// 停止时未处理成功的消息不提交，重启后会重新消费
+			return
+		}
+
+		commitCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		err = r.CommitMessages(commitCtx, msg)
+		cancel()
+		if err != nil {
+			log.Errorf(log.TagKafka, "commit message of %s error : %s", topic, err)
+		}
+	}
+}
+
+// process 处理消息，失败时重试，只有在停止时才返回 error
+func (c *Consumer) process(ctx, runCtx context.Context, h Handler, msg *Message) error {
+	for round := 1; ; round++ {
+		var err error
+		for i := 0; i <= c.retries; i++ {
+			if i > 0 && !sleep(runCtx, c.backoff*time.Duration(i)) {
+				return runCtx.Err()
+			}
+			if panicErr := utils.SafeRun(nil, func() {
+				err = h(ctx, msg)
+			}); panicErr != nil {
+				err = panicErr
+			}
+			if err == nil {
+				return nil
+			}
+			log.Warnf(log.TagKafka, "handle message %s/%d/%d error, retry %d : %s", msg.Topic, msg.Partition, msg.Offset, i, err)
+		}
+
+		c.mu.Lock()
+		onFailed := c.onFailed
+		c.mu.Unlock()
+		if onFailed != nil {
+			if fErr := onFailed(ctx, msg, err); fErr == nil {
+				return nil
+			}
+		}
+
+		log.Errorf(log.TagKafka, "handle message %s/%d/%d failed after %d rounds : %s", msg.Topic, msg.Partition, msg.Offset, round, err)
+		if !sleep(runCtx, c.backoff*time.Duration(c.retries+1)) {
+			return runCtx.Err()
+		}
+	}
+}
+
+// Stop 停止拉取新消息，等待正在处理的消息完成后关闭
+func (c *Consumer) Stop() error {
+	c.mu.Lock()
+	if !c.running {
+		c.mu.Unlock()
+		return nil
+	}
+	c.running = false
+	c.cancel()
+	readers := c.readers
+	c.readers = nil
+	c.mu.Unlock()
+
+	c.wg.Wait()
+
+	var err error
+	for _, r := range readers {
+		if rErr := r.Close(); rErr != nil && err == nil {
+			err = rErr
+		}
+	}
+	return err
+}
+
+// sleep ctx 取消时返回 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -1 +1,140 @@
 package kafka
+
+// kafka 生产者和消费者
+// Producer 支持同步/异步发送，相同 key 的消息发送到同一个 partition ，发送失败时重试
+// Consumer 以 consumer group 消费，按 topic 分发到注册的 Handler ，处理成功后才提交 offset (at-least-once)
+// 底层通过 Transport 访问 kafka ，测试时可以使用 MemoryBroker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HeaderTenantID 消息所属租户的 header
+const HeaderTenantID = "x-tenant-id"
+
+var (
+	ErrNotInit   = errors.New("kafka not init")
+	ErrNoBrokers = errors.New("kafka brokers not configured")
+	ErrClosed    = errors.New("kafka client closed")
+
+	defaultTransport Transport
+	defaultProducer  *Producer
+	defaultConsumer  *Consumer
+	mu               sync.RWMutex
+)
+
+// Message kafka 消息
+type Message struct {
+	Topic     string
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Partition int
+	Offset    int64
+	Time      time.Time
+}
+
+// Handler 消息处理函数，返回 error 时会重试，处理成功后才提交 offset
+type Handler func(ctx context.Context, msg *Message) error
+
+// Pinger 检查是否能连接到 kafka
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Writer 发送消息
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Reader 以 consumer group 读取一个 topic 的消息
+type Reader interface {
+	FetchMessage(ctx context.Context) (Message, error)
+	CommitMessages(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Transport 创建 Writer 和 Reader
+type Transport interface {
+	NewWriter() Writer
+	NewReader(groupID, topic string) Reader
+}
+
+// Init 初始化默认的 Producer 和 Consumer
+func Init(conf *Config) error {
+	if len(conf.Brokers) == 0 {
+		return ErrNoBrokers
+	}
+	conf.setDefaults()
+
+	return InitWithTransport(NewTransport(conf), conf)
+}
+
+// InitWithTransport 使用指定的 Transport 初始化，测试时可以传入 MemoryBroker
+func InitWithTransport(t Transport, conf *Config) error {
+	conf.setDefaults()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	defaultTransport = t
+	defaultProducer = NewProducer(t, conf)
+	defaultConsumer = NewConsumer(t, conf)
+	return nil
+}
+
+// HealthCheck 检查 kafka 是否可用， Transport 没有实现 Pinger 时总是可用
+func HealthCheck(ctx context.Context) error {
+	mu.RLock()
+	t := defaultTransport
+	mu.RUnlock()
+
+	if t == nil {
+		return ErrNotInit
+	}
+	if p, ok := t.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// GetProducer 获取默认的 Producer ，未初始化时返回 nil
+func GetProducer() *Producer {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return defaultProducer
+}
+
+// GetConsumer 获取默认的 Consumer ，未初始化时返回 nil
+func GetConsumer() *Consumer {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return defaultConsumer
+}
+
+// Close 先停止消费，再关闭 Producer ，作为 kafka 组件的 Stop hook
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var err error
+	if defaultConsumer != nil {
+		err = defaultConsumer.Stop()
+		defaultConsumer = nil
+	}
+	if defaultProducer != nil {
+		if pErr := defaultProducer.Close(); pErr != nil && err == nil {
+			err = pErr
+		}
+		defaultProducer = nil
+	}
+	defaultTransport = nil
+	return err
+}
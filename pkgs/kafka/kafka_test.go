@@ -0,0 +1,179 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/tracing"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config.Set("kafka.brokers", []string{"127.0.0.1:9092"})
+	config.Set("kafka.retries", 0)
+
+	// 显式配置为 0 时不重试，没有配置时使用默认值
+	conf, err := LoadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, conf.Retries)
+	assert.Equal(t, 3, conf.ConsumeRetries)
+	conf.setDefaults()
+	assert.Equal(t, 0, conf.Retries)
+
+	b := NewMemoryBroker(1)
+	b.FailWrites = 1
+	assert.NotNil(t, NewProducer(b, conf).Send(context.Background(), Message{Topic: "no_retry"}))
+	assert.Empty(t, b.Messages("no_retry"))
+}
+
+func TestProducer(t *testing.T) {
+	b := NewMemoryBroker(4)
+	p := NewProducer(b, &Config{Retries: 3, RetryBackoff: time.Millisecond})
+	ctx := context.Background()
+
+	// 相同 key 发送到同一个 partition
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, p.Send(ctx, Message{Topic: "order", Key: []byte("order1"), Value: []byte(fmt.Sprint(i))}))
+	}
+	msgs := b.Messages("order")
+	assert.Len(t, msgs, 10)
+	for i, m := range msgs {
+		assert.Equal(t, msgs[0].Partition, m.Partition)
+		assert.Equal(t, fmt.Sprint(i), string(m.Value))
+	}
+
+	// 失败时重试
+	b.FailWrites = 2
+	assert.Nil(t, p.SendJSON(ctx, "retry", "k", map[string]int{"a": 1}))
+	assert.Len(t, b.Messages("retry"), 1)
+
+	// 异步发送，关闭时发送完缓冲区中的消息
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		p.SendAsync(Message{Topic: "async", Value: []byte("v")}, func(err error) {
+			assert.Nil(t, err)
+			wg.Done()
+		})
+	}
+	assert.Nil(t, p.Close())
+	wg.Wait()
+	assert.Len(t, b.Messages("async"), 20)
+	assert.ErrorIs(t, p.Send(ctx, Message{Topic: "async"}), ErrClosed)
+}
+
+func TestConsumer(t *testing.T) {
+	b := NewMemoryBroker(2)
+	conf := &Config{GroupID: "g1", RetryBackoff: time.Millisecond, ConsumeRetries: 1}
+	p := NewProducer(b, conf)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, p.Send(ctx, Message{Topic: "event", Key: []byte(fmt.Sprint(i)), Value: []byte(fmt.Sprint(i))}))
+	}
+
+	var mu sync.Mutex
+	received := map[string]int{}
+	c := NewConsumer(b, conf)
+	assert.Nil(t, c.Handle("event", func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		received[string(msg.Value)]++
+		// 第一次处理 "3" 时失败，重试后成功
+		if string(msg.Value) == "3" && received["3"] == 1 {
+			return errors.New("fail")
+		}
+		return nil
+	}))
+	assert.ErrorIs(t, c.Handle("event", nil), ErrHandlerExist)
+	assert.Nil(t, c.Start(ctx))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 5
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, 2, received["3"])
+	assert.Nil(t, c.Stop())
+
+	// 一直失败的消息不会提交，重启后重新消费
+	assert.Nil(t, p.Send(ctx, Message{Topic: "event", Value: []byte("bad")}))
+	c2 := NewConsumer(b, conf)
+	var bad int
+	assert.Nil(t, c2.Handle("event", func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if string(msg.Value) == "bad" {
+			bad++
+			return errors.New("fail")
+		}
+		received[string(msg.Value)]++
+		return nil
+	}))
+	assert.Nil(t, c2.Start(ctx))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return bad >= 2
+	}, time.Second, time.Millisecond*10)
+	assert.Nil(t, c2.Stop())
+	assert.Equal(t, 2, received["3"]) // 已提交的消息不会重复消费
+
+	// 设置 OnFailed 后跳过失败的消息
+	c3 := NewConsumer(b, conf)
+	var dead []string
+	c3.OnFailed(func(ctx context.Context, msg *Message, err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		dead = append(dead, string(msg.Value))
+		return nil
+	})
+	assert.Nil(t, c3.Handle("event", func(ctx context.Context, msg *Message) error {
+		return errors.New("fail")
+	}))
+	assert.Nil(t, c3.Start(ctx))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(dead) == 1
+	}, time.Second, time.Millisecond*10)
+	assert.Nil(t, c3.Stop())
+	assert.Equal(t, []string{"bad"}, dead)
+}
+
+func TestTracePropagation(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tracing.InitWithExporter(exp, &tracing.Config{ServiceName: "test", SampleRatio: 1})
+	t.Cleanup(func() { tracing.Close() })
+
+	b := NewMemoryBroker(1)
+	conf := &Config{GroupID: "g1", RetryBackoff: time.Millisecond}
+	ctx, span := tracing.Start(context.Background(), "request")
+	headers := map[string]string{"k": "v"}
+	assert.Nil(t, NewProducer(b, conf).Send(ctx, Message{Topic: "traced", Value: []byte("v"), Headers: headers}))
+	span.End()
+	// 不修改调用方的 header
+	assert.Equal(t, map[string]string{"k": "v"}, headers)
+
+	received := make(chan trace.SpanContext, 1)
+	c := NewConsumer(b, conf)
+	assert.Nil(t, c.Handle("traced", func(ctx context.Context, msg *Message) error {
+		received <- trace.SpanContextFromContext(ctx)
+		return nil
+	}))
+	assert.Nil(t, c.Start(context.Background()))
+	defer c.Stop()
+
+	select {
+	case sc := <-received:
+		assert.Equal(t, span.SpanContext().TraceID(), sc.TraceID())
+	case <-time.After(time.Second):
+		t.Fatal("message not consumed")
+	}
+}
@@ -0,0 +1,185 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrMemoryWrite = errors.New("memory broker write error")
+
+// MemoryBroker 内存中的 kafka ，用于测试
+// 支持 partition 、 consumer group 的 offset 提交，未提交的消息在新的 Reader 中会重新消费
+type MemoryBroker struct {
+	partitions int
+
+	mu      sync.Mutex
+	topics  map[string][][]Message        // topic -> partition -> messages
+	offsets map[string]map[string][]int64 // group -> topic -> partition -> 已提交的 offset
+	notify  chan struct{}                 // 有新消息时关闭并替换
+
+	// FailWrites 大于 0 时，接下来的 n 次写入返回错误，用于测试重试
+	FailWrites int32
+}
+
+// NewMemoryBroker 创建内存 kafka ， partitions 为每个 topic 的 partition 数量
+func NewMemoryBroker(partitions int) *MemoryBroker {
+	if partitions <= 0 {
+		partitions = 1
+	}
+	return &MemoryBroker{
+		partitions: partitions,
+		topics:     make(map[string][][]Message),
+		offsets:    make(map[string]map[string][]int64),
+		notify:     make(chan struct{}),
+	}
+}
+
+// Messages 获取 topic 中的所有消息，按 partition 排列
+func (b *MemoryBroker) Messages(topic string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var list []Message
+	for _, p := range b.topics[topic] {
+		list = append(list, p...)
+	}
+	return list
+}
+
+func (b *MemoryBroker) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (b *MemoryBroker) NewWriter() Writer {
+	return &memoryWriter{b: b}
+}
+
+func (b *MemoryBroker) NewReader(groupID, topic string) Reader {
+	return &memoryReader{b: b, group: groupID, topic: topic}
+}
+
+func (b *MemoryBroker) partitionOf(key []byte, seq int) int {
+	if len(key) == 0 {
+		return seq % b.partitions
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(b.partitions))
+}
+
+func (b *MemoryBroker) ensureTopic(topic string) [][]Message {
+	ps, ok := b.topics[topic]
+	if !ok {
+		ps = make([][]Message, b.partitions)
+		b.topics[topic] = ps
+	}
+	return ps
+}
+
+func (b *MemoryBroker) committed(group, topic string) []int64 {
+	g, ok := b.offsets[group]
+	if !ok {
+		g = make(map[string][]int64)
+		b.offsets[group] = g
+	}
+	if _, ok := g[topic]; !ok {
+		g[topic] = make([]int64, b.partitions)
+	}
+	return g[topic]
+}
+
+type memoryWriter struct {
+	b   *MemoryBroker
+	seq int
+}
+
+func (w *memoryWriter) WriteMessages(_ context.Context, msgs ...Message) error {
+	if atomic.LoadInt32(&w.b.FailWrites) > 0 && atomic.AddInt32(&w.b.FailWrites, -1) >= 0 {
+		return ErrMemoryWrite
+	}
+
+	b := w.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, m := range msgs {
+		ps := b.ensureTopic(m.Topic)
+		p := b.partitionOf(m.Key, w.seq)
+		w.seq++
+
+		m.Partition = p
+		m.Offset = int64(len(ps[p]))
+		if m.Time.IsZero() {
+			m.Time = time.Now()
+		}
+		ps[p] = append(ps[p], m)
+	}
+	close(b.notify)
+	b.notify = make(chan struct{})
+	return nil
+}
+
+func (w *memoryWriter) Close() error {
+	return nil
+}
+
+type memoryReader struct {
+	b     *MemoryBroker
+	group string
+	topic string
+
+	fetched []int64 // 每个 partition 下一个要拉取的 offset
+	next    int     // 轮询 partition
+}
+
+func (r *memoryReader) FetchMessage(ctx context.Context) (Message, error) {
+	b := r.b
+	for {
+		b.mu.Lock()
+		ps := b.ensureTopic(r.topic)
+		if r.fetched == nil {
+			r.fetched = append([]int64{}, b.committed(r.group, r.topic)...)
+		}
+		for i := 0; i < b.partitions; i++ {
+			p := (r.next + i) % b.partitions
+			if r.fetched[p] < int64(len(ps[p])) {
+				m := ps[p][r.fetched[p]]
+				r.fetched[p]++
+				r.next = p + 1
+				b.mu.Unlock()
+				return m, nil
+			}
+		}
+		notify := b.notify
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+func (r *memoryReader) CommitMessages(_ context.Context, msgs ...Message) error {
+	b := r.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	offsets := b.committed(r.group, r.topic)
+	for _, m := range msgs {
+		if m.Offset+1 > offsets[m.Partition] {
+			offsets[m.Partition] = m.Offset + 1
+		}
+	}
+	return nil
+}
+
+func (r *memoryReader) Close() error {
+	return nil
+}
@@ -0,0 +1,179 @@
+package kafka
+
+import (
+	"context"
+	"night-fury/pkgs/log"
+	"night-fury/pkgs/tracing"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Producer 消息生产者，并发安全
+type Producer struct {
+	writer       Writer
+	retries      int
+	retryBackoff time.Duration
+
+	asyncChan chan *asyncMsg
+	wg        sync.WaitGroup
+	mu        sync.RWMutex // 保证关闭后不再写入 asyncChan
+	closing   bool
+	closed    chan struct{}
+}
+
+type asyncMsg struct {
+	msg      Message
+	callback func(error)
+}
+
+// NewProducer 创建 Producer ，异步发送的消息由后台 goroutine 发送
+func NewProducer(t Transport, conf *Config) *Producer {
+	conf.setDefaults()
+
+	p := &Producer{
+		writer:       t.NewWriter(),
+		retries:      conf.Retries,
+		retryBackoff: conf.RetryBackoff,
+		asyncChan:    make(chan *asyncMsg, conf.AsyncBuffer),
+		closed:       make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go p.runAsync()
+	return p
+}
+
+// Send 同步发送，相同 key 的消息会发送到同一个 partition
+func (p *Producer) Send(ctx context.Context, msgs ...Message) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closing {
+		return ErrClosed
+	}
+	return p.write(ctx, msgs...)
+}
+
+// SendJSON 同步发送 json 格式的消息
+func (p *Producer) SendJSON(ctx context.Context, topic, key string, v interface{}) error {
+	value, err := jsoniter.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "marshal kafka message")
+	}
+	return p.Send(ctx, Message{Topic: topic, Key: []byte(key), Value: value})
+}
+
+// SendAsync 异步发送，callback 可以为 nil ，缓冲区满时阻塞
+func (p *Producer) SendAsync(msg Message, callback func(error)) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closing {
+		if callback != nil {
+			callback(ErrClosed)
+		}
+		return
+	}
+	p.asyncChan <- &asyncMsg{msg: msg, callback: callback}
+}
+
+func (p *Producer) write(ctx context.Context, msgs ...Message) (err error) {
+	ctx, span := tracing.Start(ctx, "kafka.produce",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("kafka"),
+			attribute.Int("messaging.batch.message_count", len(msgs)),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if len(msgs) > 0 {
+		span.SetAttributes(semconv.MessagingDestinationName(msgs[0].Topic))
+	}
+	msgs = withTraceHeaders(ctx, msgs)
+
+	for i := 0; i <= p.retries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Wrap(ctx.Err(), err.Error())
+			case <-time.After(p.retryBackoff * time.Duration(i)):
+			}
+		}
+		if err = p.writer.WriteMessages(ctx, msgs...); err == nil {
+			return nil
+		}
+		log.Warnf(log.TagKafka, "write kafka message error, retry %d : %s", i, err)
+	}
+	return errors.Wrap(err, "write kafka message")
+}
+
+func (p *Producer) runAsync() {
+	defer p.wg.Done()
+
+	send := func(m *asyncMsg) {
+		err := p.write(context.Background(), m.msg)
+		if err != nil {
+			log.Errorf(log.TagKafka, "async send to %s error : %s", m.msg.Topic, err)
+		}
+		if m.callback != nil {
+			m.callback(err)
+		}
+	}
+
+	for {
+		select {
+		case m := <-p.asyncChan:
+			send(m)
+		case <-p.closed:
+			// 发送缓冲区中剩余的消息
+			for {
+				select {
+				case m := <-p.asyncChan:
+					send(m)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close 等待异步消息发送完毕后关闭
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	if p.closing {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closing = true
+	close(p.closed)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	return p.writer.Close()
+}
+
+// withTraceHeaders 复制消息并在 header 中写入当前 span ，消费者据此关联调用链
+func withTraceHeaders(ctx context.Context, msgs []Message) []Message {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return msgs
+	}
+	res := make([]Message, len(msgs))
+	for i, m := range msgs {
+		headers := make(map[string]string, len(m.Headers)+2)
+		for k, v := range m.Headers {
+			headers[k] = v
+		}
+		tracing.Inject(ctx, headers)
+		m.Headers = headers
+		res[i] = m
+	}
+	return res
+}
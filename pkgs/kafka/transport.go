@@ -0,0 +1,117 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// NewTransport 基于 kafka-go 的 Transport
+func NewTransport(conf *Config) Transport {
+	conf.setDefaults()
+	return &kafkaTransport{conf: conf}
+}
+
+type kafkaTransport struct {
+	conf *Config
+}
+
+func (t *kafkaTransport) NewWriter() Writer {
+	return &kafkaWriter{w: &kafkago.Writer{
+		Addr:         kafkago.TCP(t.conf.Brokers...),
+		Balancer:     &kafkago.Hash{}, // 相同 key 发送到同一个 partition
+		RequiredAcks: kafkago.RequireAll,
+		BatchTimeout: time.Millisecond * 10,
+		MaxAttempts:  1, // 由 Producer 重试
+		Transport:    &kafkago.Transport{ClientID: t.conf.ClientID},
+	}}
+}
+
+func (t *kafkaTransport) NewReader(groupID, topic string) Reader {
+	return &kafkaReader{r: kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:        t.conf.Brokers,
+		GroupID:        groupID,
+		Topic:          topic,
+		Dialer:         &kafkago.Dialer{ClientID: t.conf.ClientID, Timeout: time.Second * 10, DualStack: true},
+		StartOffset:    kafkago.FirstOffset,
+		CommitInterval: 0, // 同步提交
+	})}
+}
+
+// Ping 任意一个 broker 能返回集群信息即可用
+func (t *kafkaTransport) Ping(ctx context.Context) error {
+	dialer := &kafkago.Dialer{ClientID: t.conf.ClientID, Timeout: time.Second * 10, DualStack: true}
+	var err error
+	for _, broker := range t.conf.Brokers {
+		var conn *kafkago.Conn
+		if conn, err = dialer.DialContext(ctx, "tcp", broker); err != nil {
+			continue
+		}
+		_, err = conn.Brokers()
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	return errors.Wrap(err, "ping kafka")
+}
+
+type kafkaWriter struct {
+	w *kafkago.Writer
+}
+
+func (w *kafkaWriter) WriteMessages(ctx context.Context, msgs ...Message) error {
+	list := make([]kafkago.Message, 0, len(msgs))
+	for _, m := range msgs {
+		km := kafkago.Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Time: m.Time}
+		for k, v := range m.Headers {
+			km.Headers = append(km.Headers, kafkago.Header{Key: k, Value: []byte(v)})
+		}
+		list = append(list, km)
+	}
+	return w.w.WriteMessages(ctx, list...)
+}
+
+func (w *kafkaWriter) Close() error {
+	return w.w.Close()
+}
+
+type kafkaReader struct {
+	r *kafkago.Reader
+}
+
+func (r *kafkaReader) FetchMessage(ctx context.Context) (Message, error) {
+	km, err := r.r.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	m := Message{
+		Topic:     km.Topic,
+		Key:       km.Key,
+		Value:     km.Value,
+		Partition: km.Partition,
+		Offset:    km.Offset,
+		Time:      km.Time,
+	}
+	if len(km.Headers) > 0 {
+		m.Headers = make(map[string]string, len(km.Headers))
+		for _, h := range km.Headers {
+			m.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return m, nil
+}
+
+func (r *kafkaReader) CommitMessages(ctx context.Context, msgs ...Message) error {
+	list := make([]kafkago.Message, 0, len(msgs))
+	for _, m := range msgs {
+		list = append(list, kafkago.Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset})
+	}
+	return r.r.CommitMessages(ctx, list...)
+}
+
+func (r *kafkaReader) Close() error {
+	return r.r.Close()
+}
@@ -0,0 +1,121 @@
+package lock
+
+import (
+	"context"
+	"night-fury/pkgs/log"
+	"sync"
+	"time"
+)
+
+// LeaderElector 多个实例中只有获取到锁的实例执行任务
+// 失去锁时取消任务的 ctx ，停止时释放锁，其他实例可以立即接管
+type LeaderElector struct {
+	locker Locker
+	key    string
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	isLeader bool
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewLeaderElector 创建选举，同一组实例使用相同的 key
+func NewLeaderElector(l Locker, key string, ttl time.Duration) *LeaderElector {
+	return &LeaderElector{locker: l, key: key, ttl: ttl}
+}
+
+// IsLeader 当前实例是否为 leader
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.isLeader
+}
+
+func (e *LeaderElector) setLeader(v bool) {
+	e.mu.Lock()
+	e.isLeader = v
+	e.mu.Unlock()
+}
+
+// Start 在后台竞选，成为 leader 后执行 f(ctx, token) ， ctx 在失去 leader 或停止时取消
+// f 返回后释放锁并重新竞选
+func (e *LeaderElector) Start(f func(ctx context.Context, token int64)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.done = make(chan struct{})
+
+	go e.run(ctx, f)
+}
+
+func (e *LeaderElector) run(ctx context.Context, f func(ctx context.Context, token int64)) {
+	defer close(e.done)
+
+	for {
+		m, err := Lock(ctx, e.locker, e.key, e.ttl)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf(log.TagLock, "elect leader %s error : %s", e.key, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval(e.ttl)):
+			}
+			continue
+		}
+
+		log.Infof(log.TagLock, "became leader of %s, token : %d", e.key, m.Token())
+		e.setLeader(true)
+		e.lead(ctx, m, f)
+		e.setLeader(false)
+
+		releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		if err := m.Unlock(releaseCtx); err != nil {
+			log.Warnf(log.TagLock, "release leader %s error : %s", e.key, err)
+		}
+		cancel()
+		log.Infof(log.TagLock, "resigned leader of %s", e.key)
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// lead 执行 f ，锁丢失或停止时取消 f 的 ctx
+func (e *LeaderElector) lead(ctx context.Context, m *Mutex, f func(ctx context.Context, token int64)) {
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-m.Lost():
+			cancel()
+		case <-leadCtx.Done():
+		}
+	}()
+	f(leadCtx, m.Token())
+}
+
+// Stop 停止竞选，是 leader 时等待 f 返回后释放锁
+func (e *LeaderElector) Stop() error {
+	e.mu.Lock()
+	cancel, done := e.cancel, e.done
+	e.cancel = nil
+	e.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package lock
+
+// 分布式锁和 leader 选举
+// Locker 由具体的存储实现：redis.NewLocker() (SET NX + lua) 、 db.NewAdvisoryLocker() (postgres advisory lock)
+//   m, err := lock.Lock(ctx, lock.GetDefault(), "job:cleanup", time.Second*30)
+//   if err != nil { ... }
+//   defer m.Unlock(context.Background())
+//   // 获取锁后自动续期，续期失败时 m.Lost() 关闭，需要停止操作
+//   // 写外部存储时带上 m.Token() ，存储端拒绝比已见过的 token 更小的写入 (fencing token)
+
+import (
+	"context"
+	"night-fury/pkgs/log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNotAcquired = errors.New("lock not acquired")
+	ErrLockLost    = errors.New("lock lost")
+	ErrNotInit     = errors.New("locker not init")
+
+	defaultLocker Locker
+	mu            sync.RWMutex
+)
+
+// Locker 分布式锁的实现
+type Locker interface {
+	// TryLock 尝试获取锁，已被占用时返回 ErrNotAcquired
+	TryLock(ctx context.Context, key string, ttl time.Duration) (Lease, error)
+}
+
+// Lease 获取到的锁
+type Lease interface {
+	// Token 单调递增的 fencing token ，每次获取锁时增加
+	Token() int64
+	// Refresh 续期，锁已经不属于自己时返回 ErrLockLost
+	Refresh(ctx context.Context, ttl time.Duration) error
+	// Release 释放锁
+	Release(ctx context.Context) error
+}
+
+// SetDefault 设置默认的 Locker
+func SetDefault(l Locker) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	defaultLocker = l
+}
+
+// GetDefault 获取默认的 Locker ，未设置时返回 nil
+func GetDefault() Locker {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return defaultLocker
+}
+
+// Mutex 自动续期的锁
+type Mutex struct {
+	lease Lease
+	ttl   time.Duration
+
+	lost     chan struct{}
+	lostOnce sync.Once
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// TryLock 尝试获取锁，获取后每 ttl/3 续期一次
+func TryLock(ctx context.Context, l Locker, key string, ttl time.Duration) (*Mutex, error) {
+	if l == nil {
+		return nil, ErrNotInit
+	}
+	lease, err := l.TryLock(ctx, key, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mutex{
+		lease: lease,
+		ttl:   ttl,
+		lost:  make(chan struct{}),
+		stop:  make(chan struct{}),
+	}
+	m.wg.Add(1)
+	go m.keepAlive(key)
+	return m, nil
+}
+
+// Lock 获取锁，被占用时每隔 ttl/3 重试，直到获取成功或 ctx 取消
+func Lock(ctx context.Context, l Locker, key string, ttl time.Duration) (*Mutex, error) {
+	interval := retryInterval(ttl)
+	for {
+		m, err := TryLock(ctx, l, key, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return m, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), err.Error())
+		case <-time.After(interval):
+		}
+	}
+}
+
+func retryInterval(ttl time.Duration) time.Duration {
+	d := ttl / 3
+	if d <= 0 {
+		d = time.Millisecond * 100
+	}
+	return d
+}
+
+func (m *Mutex) keepAlive(key string) {
+	defer m.wg.Done()
+
+	t := time.NewTicker(retryInterval(m.ttl))
+	defer t.Stop()
+	lastOK := time.Now()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-t.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), retryInterval(m.ttl))
+		err := m.lease.Refresh(ctx, m.ttl)
+		cancel()
+		if err == nil {
+			lastOK = time.Now()
+			continue
+		}
+		// 续期请求失败但锁还未过期时，下次继续尝试
+		if !errors.Is(err, ErrLockLost) && time.Since(lastOK) < m.ttl {
+			log.Warnf(log.TagLock, "refresh lock %s error : %s", key, err)
+			continue
+		}
+		log.Errorf(log.TagLock, "lock %s lost : %s", key, err)
+		m.lostOnce.Do(func() { close(m.lost) })
+		return
+	}
+}
+
+// Token fencing token
+func (m *Mutex) Token() int64 {
+	return m.lease.Token()
+}
+
+// Lost 锁丢失时关闭
+func (m *Mutex) Lost() <-chan struct{} {
+	return m.lost
+}
+
+// Unlock 停止续期并释放锁
+func (m *Mutex) Unlock(ctx context.Context) error {
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.wg.Wait()
+
+	select {
+	case <-m.lost:
+		return ErrLockLost
+	default:
+	}
+	return m.lease.Release(ctx)
+}
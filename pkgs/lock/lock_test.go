@@ -0,0 +1,70 @@
+package lock
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutex(t *testing.T) {
+	l := NewMemoryLocker()
+	ctx := context.Background()
+
+	m1, err := TryLock(ctx, l, "k", time.Millisecond*60)
+	assert.Nil(t, err)
+	_, err = TryLock(ctx, l, "k", time.Millisecond*60)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+
+	// 自动续期，超过 ttl 后仍然持有
+	time.Sleep(time.Millisecond * 100)
+	_, err = TryLock(ctx, l, "k", time.Millisecond*60)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+
+	// 等待释放后获取，token 递增
+	go func() {
+		time.Sleep(time.Millisecond * 30)
+		assert.Nil(t, m1.Unlock(ctx))
+	}()
+	m2, err := Lock(ctx, l, "k", time.Millisecond*60)
+	assert.Nil(t, err)
+	assert.Greater(t, m2.Token(), m1.Token())
+
+	// 锁丢失
+	l.Expire("k")
+	select {
+	case <-m2.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lock lost not notified")
+	}
+	assert.ErrorIs(t, m2.Unlock(ctx), ErrLockLost)
+}
+
+func TestLeaderElector(t *testing.T) {
+	l := NewMemoryLocker()
+	var leaders int32
+	var runs int32
+	run := func(ctx context.Context, token int64) {
+		assert.Equal(t, int32(1), atomic.AddInt32(&leaders, 1))
+		atomic.AddInt32(&runs, 1)
+		<-ctx.Done()
+		atomic.AddInt32(&leaders, -1)
+	}
+
+	e1 := NewLeaderElector(l, "leader", time.Millisecond*60)
+	e2 := NewLeaderElector(l, "leader", time.Millisecond*60)
+	e1.Start(run)
+	assert.Eventually(t, e1.IsLeader, time.Second, time.Millisecond*5)
+	e2.Start(run)
+	time.Sleep(time.Millisecond * 50)
+	assert.False(t, e2.IsLeader())
+
+	// 停止时交出 leader
+	assert.Nil(t, e1.Stop())
+	assert.False(t, e1.IsLeader())
+	assert.Eventually(t, e2.IsLeader, time.Second, time.Millisecond*5)
+	assert.Nil(t, e2.Stop())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&runs))
+}
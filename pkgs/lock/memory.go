@@ -0,0 +1,75 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLocker 进程内的 Locker ，用于测试和单实例部署
+type MemoryLocker struct {
+	mu     sync.Mutex
+	locks  map[string]*memoryLease
+	fences map[string]int64
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{
+		locks:  make(map[string]*memoryLease),
+		fences: make(map[string]int64),
+	}
+}
+
+func (l *MemoryLocker) TryLock(_ context.Context, key string, ttl time.Duration) (Lease, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if cur, ok := l.locks[key]; ok && time.Now().Before(cur.expireAt) {
+		return nil, ErrNotAcquired
+	}
+	l.fences[key]++
+	lease := &memoryLease{l: l, key: key, token: l.fences[key], expireAt: time.Now().Add(ttl)}
+	l.locks[key] = lease
+	return lease, nil
+}
+
+// Expire 使锁立即过期，用于测试锁丢失
+func (l *MemoryLocker) Expire(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.locks, key)
+}
+
+type memoryLease struct {
+	l        *MemoryLocker
+	key      string
+	token    int64
+	expireAt time.Time
+}
+
+func (m *memoryLease) Token() int64 {
+	return m.token
+}
+
+func (m *memoryLease) Refresh(_ context.Context, ttl time.Duration) error {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	if m.l.locks[m.key] != m || time.Now().After(m.expireAt) {
+		return ErrLockLost
+	}
+	m.expireAt = time.Now().Add(ttl)
+	return nil
+}
+
+func (m *memoryLease) Release(_ context.Context) error {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	if m.l.locks[m.key] != m {
+		return ErrLockLost
+	}
+	delete(m.l.locks, m.key)
+	return nil
+}
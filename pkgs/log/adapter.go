@@ -0,0 +1,139 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// 第三方库日志的适配器：
+//   logrus  标准 logger 的日志通过 hook 转发，init 时自动安装
+//   gin     使用 Writer 作为 gin.DefaultWriter 、 gin.DefaultErrorWriter
+//   gorm    使用 GormLogger 作为 gorm.Config.Logger
+//   nacos   *Logger 满足 nacos logger.Logger 接口，直接 SetLogger(log.WithTag(...))
+
+// gorm 慢查询阈值
+const slowSQLThreshold = time.Millisecond * 200
+
+func installAdapters() {
+	l := logrus.StandardLogger()
+	l.SetOutput(io.Discard)
+	l.SetLevel(logrus.TraceLevel)
+	l.AddHook(logrusHook{})
+}
+
+// logrusHook 把 logrus 标准 logger 的日志转发到 std
+type logrusHook struct{}
+
+func (logrusHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (logrusHook) Fire(entry *logrus.Entry) error {
+	level := LevelDebug
+	switch entry.Level {
+	case logrus.InfoLevel:
+		level = LevelInfo
+	case logrus.WarnLevel:
+		level = LevelWarn
+	case logrus.ErrorLevel:
+		level = LevelError
+	case logrus.PanicLevel, logrus.FatalLevel:
+		// logrus 自己会 panic 或退出，这里只记录
+		level = LevelError
+	}
+
+	l := std.WithTag(TagLogrus)
+	if l.Enabled(level) {
+		l.write(level, Params(entry.Data), entry.Message, "")
+	}
+	return nil
+}
+
+type writer struct {
+	l     *Logger
+	level Level
+}
+
+// Writer 把每行写入的内容作为一条 level 级别的日志，用于只支持 io.Writer 的库，如 gin
+func Writer(tag string, level Level) io.Writer {
+	return &writer{l: std.WithTag(tag), level: level}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.l.Enabled(w.level) {
+		for _, line := range bytes.Split(bytes.TrimRight(p, "\r\n"), []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				w.l.write(w.level, nil, string(line), "")
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// gormLogger 的 sql 为 debug 级别，慢查询为 warn 级别，错误为 error 级别，带上 context 中的 request id
+type gormLogger struct {
+	l     *Logger
+	level gormlogger.LogLevel
+}
+
+// GormLogger gorm 日志适配器，输出的级别由 std 的级别控制
+func GormLogger(tag string) gormlogger.Interface {
+	return &gormLogger{l: std.WithTag(tag), level: gormlogger.Info}
+}
+
+func (g *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &gormLogger{l: g.l, level: level}
+}
+
+func (g *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if g.level >= gormlogger.Info {
+		g.l.WithContext(ctx).Infof(msg, data...)
+	}
+}
+
+func (g *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if g.level >= gormlogger.Warn {
+		g.l.WithContext(ctx).Warnf(msg, data...)
+	}
+}
+
+func (g *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if g.level >= gormlogger.Error {
+		g.l.WithContext(ctx).Errorf(msg, data...)
+	}
+}
+
+func (g *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if g.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	level := LevelDebug
+	msg := "sql"
+	switch {
+	case err != nil && g.level >= gormlogger.Error && !errors.Is(err, gormlogger.ErrRecordNotFound):
+		level, msg = LevelError, "sql error"
+	case elapsed > slowSQLThreshold && g.level >= gormlogger.Warn:
+		level, msg = LevelWarn, "slow sql"
+	case g.level < gormlogger.Info:
+		return
+	}
+	if !g.l.Enabled(level) {
+		return
+	}
+
+	sql, rows := fc()
+	fields := Params{"sql": sql, "rows": rows, "elapsed": elapsed.String()}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	g.l.WithContext(ctx).write(level, fields, msg, utils.FileWithLineNum())
+}
@@ -0,0 +1,155 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 队列满时的处理策略，error 及以上级别的日志总是等待，不会被丢弃
+const (
+	DropNewest = "newest" // 丢弃新的日志
+	DropOldest = "oldest" // 丢弃队列中最早的日志
+	DropNone   = "block"  // 等待队列有空位
+)
+
+var ErrHandlerClosed = errors.New("log: handler closed")
+
+// AsyncHandler 把日志放入有界队列，由单独的 goroutine 写入 h ，避免写日志阻塞业务
+type AsyncHandler struct {
+	h       Handler
+	policy  string
+	queue   chan *Entry
+	flushCh chan chan struct{}
+	done    chan struct{}
+	dropped uint64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncHandler size 为队列长度， policy 为 DropNewest 、 DropOldest 或 DropNone
+func NewAsyncHandler(h Handler, size int, policy string) *AsyncHandler {
+	if size <= 0 {
+		size = 1
+	}
+	a := &AsyncHandler{
+		h:       h,
+		policy:  policy,
+		queue:   make(chan *Entry, size),
+		flushCh: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *AsyncHandler) Handle(e *Entry) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return ErrHandlerClosed
+	}
+
+	if e.Level >= LevelError || a.policy == DropNone {
+		a.queue <- e
+		return nil
+	}
+
+	for {
+		select {
+		case a.queue <- e:
+			return nil
+		default:
+		}
+
+		if a.policy != DropOldest {
+			atomic.AddUint64(&a.dropped, 1)
+			return nil
+		}
+		// 丢弃最早的一条后重试，队列可能同时被消费，所以不一定真的丢弃
+		select {
+		case <-a.queue:
+			atomic.AddUint64(&a.dropped, 1)
+		default:
+		}
+	}
+}
+
+// Dropped 队列满时丢弃的日志数
+func (a *AsyncHandler) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Flush 等待队列中已有的日志写入完成，超时返回错误
+func (a *AsyncHandler) Flush(timeout time.Duration) error {
+	a.mu.RLock()
+	closed := a.closed
+	a.mu.RUnlock()
+	if closed {
+		return nil
+	}
+
+	ch := make(chan struct{})
+	select {
+	case a.flushCh <- ch:
+	case <-time.After(timeout):
+		return errors.New("log: flush timeout")
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("log: flush timeout")
+	}
+}
+
+// Close 写完队列中的日志后关闭 h
+func (a *AsyncHandler) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+
+	<-a.done
+	return a.h.Close()
+}
+
+func (a *AsyncHandler) run() {
+	defer close(a.done)
+	for {
+		select {
+		case e, ok := <-a.queue:
+			if !ok {
+				return
+			}
+			a.handle(e)
+		case ch := <-a.flushCh:
+			a.drain()
+			close(ch)
+		}
+	}
+}
+
+// drain 写入 flush 时队列中已有的日志
+func (a *AsyncHandler) drain() {
+	for n := len(a.queue); n > 0; n-- {
+		e, ok := <-a.queue
+		if !ok {
+			return
+		}
+		a.handle(e)
+	}
+}
+
+func (a *AsyncHandler) handle(e *Entry) {
+	if err := a.h.Handle(e); err != nil {
+		writeError(err)
+	}
+}
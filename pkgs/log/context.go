@@ -0,0 +1,58 @@
+package log
+
+// request id 通过 context 在 http 、 db 、 ws 之间传递，用于关联同一个请求产生的日志
+//   ctx = log.WithRequestID(ctx, id)
+//   log.WithContext(ctx).WithTag(log.TagDB).Infof(...)   日志中带上 request_id 、 tenant_id 和 trace_id
+
+import (
+	"context"
+	"night-fury/pkgs/tenant"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader 传递 request id 的 http header
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithRequestID 在 context 中设置 request id
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID 获取 context 中的 request id ，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithContext 全局 Logger 带上 context 中的 request id 、租户和 trace
+func WithContext(ctx context.Context) *Logger {
+	return std.WithContext(ctx)
+}
+
+// WithContext 带上 context 中的 request id 、租户和 trace ，没有时返回自身
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return l
+	}
+	fields := Params{}
+	if id := RequestID(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	if id, ok := tenant.FromContext(ctx); ok {
+		fields["tenant_id"] = id
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+		fields["span_id"] = sc.SpanID().String()
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields)
+}
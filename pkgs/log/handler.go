@@ -0,0 +1,198 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/util/gconv"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// Handler 日志输出，同一个 Handler 可能被并发调用
+type Handler interface {
+	Handle(e *Entry) error
+	Close() error
+}
+
+// Formatter 把日志格式化为一行，不含换行符
+type Formatter interface {
+	Format(e *Entry) ([]byte, error)
+}
+
+// JSONFormatter 格式化为 json ，字段和 level 、 msg 等同级，冲突时字段加 fields. 前缀
+type JSONFormatter struct{}
+
+func (f *JSONFormatter) Format(e *Entry) ([]byte, error) {
+	data := make(map[string]interface{}, len(e.Fields)+5)
+	for k, v := range e.Fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		switch k {
+		case "time", "level", "msg", "mod":
+			k = "fields." + k
+		}
+		data[k] = v
+	}
+	data["time"] = e.Time.Format(time.RFC3339Nano)
+	data["level"] = e.Level.String()
+	data["msg"] = e.Message
+	if e.Tag != "" {
+		data["mod"] = e.Tag
+	}
+	if _, ok := data["debug"]; !ok && e.Caller != "" {
+		data["debug"] = e.Caller
+	}
+
+	b, err := jsoniter.Marshal(data)
+	return b, errors.Wrap(err, "log: marshal entry")
+}
+
+// TextFormatter 带颜色的 text 格式，用于本地开发
+type TextFormatter struct {
+	DisableColors bool
+}
+
+var levelColors = []string{"36", "36", "33", "33;41", "33;41", "33;41"}
+
+func (f *TextFormatter) Format(e *Entry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(e.Time.Format("15:04:05.000 "))
+
+	name := fmt.Sprintf("%-5s", strings.ToUpper(e.Level.String()))
+	if f.DisableColors {
+		buf.WriteString(name)
+	} else {
+		fmt.Fprintf(buf, "\033[%sm%s\033[0m", levelColors[e.Level], name)
+	}
+	fmt.Fprintf(buf, " %q   ", e.Message)
+
+	if e.Tag != "" {
+		f.writeField(buf, "mod", e.Tag)
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if e.Fields[k] != nil {
+			f.writeField(buf, k, gconv.String(e.Fields[k]))
+		}
+	}
+	if e.Caller != "" {
+		f.writeField(buf, "debug", e.Caller)
+	}
+	return bytes.TrimRight(buf.Bytes(), " "), nil
+}
+
+func (f *TextFormatter) writeField(buf *bytes.Buffer, k, v string) {
+	if f.DisableColors {
+		fmt.Fprintf(buf, "%s=%s  ", k, v)
+		return
+	}
+	fmt.Fprintf(buf, "\033[36m%s\033[0m=%s  ", k, v)
+}
+
+// WriterHandler 格式化后写入 io.Writer ，每条日志一行
+type WriterHandler struct {
+	mu        sync.Mutex
+	w         io.Writer
+	formatter Formatter
+}
+
+func NewWriterHandler(w io.Writer, f Formatter) *WriterHandler {
+	return &WriterHandler{w: w, formatter: f}
+}
+
+func (h *WriterHandler) Handle(e *Entry) error {
+	b, err := h.formatter.Format(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.w.Write(b)
+	return err
+}
+
+// Close 关闭 writer ，标准输出和标准错误不会被关闭
+func (h *WriterHandler) Close() error {
+	if c, ok := h.w.(io.Closer); ok && h.w != os.Stdout && h.w != os.Stderr {
+		return c.Close()
+	}
+	return nil
+}
+
+// NetworkHandler 通过 tcp/udp 发送日志，如发送到 logstash
+// 连接断开后在下一条日志时重连，发送失败的日志会被丢弃
+type NetworkHandler struct {
+	mu        sync.Mutex
+	network   string
+	addr      string
+	timeout   time.Duration
+	conn      net.Conn
+	formatter Formatter
+}
+
+func NewNetworkHandler(network, addr string, f Formatter) *NetworkHandler {
+	return &NetworkHandler{network: network, addr: addr, timeout: time.Second * 3, formatter: f}
+}
+
+func (h *NetworkHandler) Handle(e *Entry) error {
+	b, err := h.formatter.Format(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn == nil {
+		if h.conn, err = net.DialTimeout(h.network, h.addr, h.timeout); err != nil {
+			h.conn = nil
+			return errors.Wrapf(err, "log: dial %s %s", h.network, h.addr)
+		}
+	}
+	h.conn.SetWriteDeadline(time.Now().Add(h.timeout))
+	if _, err = h.conn.Write(b); err != nil {
+		h.conn.Close()
+		h.conn = nil
+		return errors.Wrapf(err, "log: write to %s", h.addr)
+	}
+	return nil
+}
+
+func (h *NetworkHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
+
+func closeHandlers(handlers []Handler) error {
+	var errs []string
+	for _, h := range handlers {
+		if err := h.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("log: close handlers : %v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package log
+
+// 按模块(tag)单独设置级别和采样：
+//   log:
+//     level: info
+//     levels:               模块级别，覆盖 level
+//       mod_db: debug
+//     sampling:             高频模块的采样，error 及以上级别不采样
+//       mod_ws_server: {first: 100, thereafter: 100, tick: 1s}
+// 修改配置后立即生效，也可以通过 SetTagLevel 在运行时修改，配置变化时会被配置覆盖
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sampling 每个 Tick 内同一模块同一级别的前 First 条全部输出，之后每 Thereafter 条输出一条
+type Sampling struct {
+	First      int           `mapstructure:"first"`
+	Thereafter int           `mapstructure:"thereafter"` // 0 时丢弃超过 First 的日志
+	Tick       time.Duration `mapstructure:"tick"`       // 默认 1s
+}
+
+type sampleCounter struct {
+	resetAt int64
+	n       uint64
+}
+
+type sampler struct {
+	conf     Sampling
+	counters [LevelError]sampleCounter
+	dropped  uint64
+}
+
+func newSampler(conf Sampling) *sampler {
+	if conf.Tick <= 0 {
+		conf.Tick = time.Second
+	}
+	return &sampler{conf: conf}
+}
+
+// allow 计数不加锁，并发时同一个 tick 内的数量可能略有偏差
+func (s *sampler) allow(level Level) bool {
+	if level >= LevelError || level < LevelDebug {
+		return true
+	}
+
+	c := &s.counters[level]
+	now := time.Now().UnixNano()
+	var n uint64
+	if now > atomic.LoadInt64(&c.resetAt) {
+		atomic.StoreInt64(&c.resetAt, now+int64(s.conf.Tick))
+		atomic.StoreUint64(&c.n, 1)
+		n = 1
+	} else {
+		n = atomic.AddUint64(&c.n, 1)
+	}
+
+	first := uint64(s.conf.First)
+	if n <= first || (s.conf.Thereafter > 0 && (n-first)%uint64(s.conf.Thereafter) == 0) {
+		return true
+	}
+	atomic.AddUint64(&s.dropped, 1)
+	return false
+}
+
+// tagOptions 模块的级别和采样，写时复制
+type tagOptions struct {
+	mu       sync.Mutex
+	levels   atomic.Value // map[string]Level
+	samplers atomic.Value // map[string]*sampler
+}
+
+func newTagOptions() *tagOptions {
+	o := &tagOptions{}
+	o.levels.Store(map[string]Level{})
+	o.samplers.Store(map[string]*sampler{})
+	return o
+}
+
+func (o *tagOptions) level(tag string) (Level, bool) {
+	level, ok := o.levels.Load().(map[string]Level)[tag]
+	return level, ok
+}
+
+func (o *tagOptions) sampler(tag string) *sampler {
+	return o.samplers.Load().(map[string]*sampler)[tag]
+}
+
+func (o *tagOptions) allLevels() map[string]Level {
+	src := o.levels.Load().(map[string]Level)
+	m := make(map[string]Level, len(src))
+	for k, v := range src {
+		m[k] = v
+	}
+	return m
+}
+
+func (o *tagOptions) setLevels(update func(m map[string]Level)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.allLevels()
+	update(m)
+	o.levels.Store(m)
+}
+
+func (o *tagOptions) setSamplers(confs map[string]Sampling) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := make(map[string]*sampler, len(confs))
+	for tag, conf := range confs {
+		m[tag] = newSampler(conf)
+	}
+	o.samplers.Store(m)
+}
+
+// SetTagLevel 设置模块的级别，覆盖全局级别
+func (l *Logger) SetTagLevel(tag string, level Level) {
+	l.core.tags.setLevels(func(m map[string]Level) { m[tag] = level })
+}
+
+// ResetTagLevel 模块恢复使用全局级别
+func (l *Logger) ResetTagLevel(tag string) {
+	l.core.tags.setLevels(func(m map[string]Level) { delete(m, tag) })
+}
+
+// SetTagLevels 替换所有模块的级别
+func (l *Logger) SetTagLevels(levels map[string]Level) {
+	l.core.tags.setLevels(func(m map[string]Level) {
+		for k := range m {
+			delete(m, k)
+		}
+		for k, v := range levels {
+			m[k] = v
+		}
+	})
+}
+
+// TagLevels 单独设置了级别的模块
+func (l *Logger) TagLevels() map[string]Level {
+	return l.core.tags.allLevels()
+}
+
+// SetSampling 替换所有模块的采样配置
+func (l *Logger) SetSampling(confs map[string]Sampling) {
+	l.core.tags.setSamplers(confs)
+}
+
+// SampledOut 模块因采样丢弃的日志数
+func (l *Logger) SampledOut(tag string) uint64 {
+	if s := l.core.tags.sampler(tag); s != nil {
+		return atomic.LoadUint64(&s.dropped)
+	}
+	return 0
+}
+
+func SetTagLevel(tag string, level Level) {
+	std.SetTagLevel(tag, level)
+}
+
+func ResetTagLevel(tag string) {
+	std.ResetTagLevel(tag)
+}
+
+func TagLevels() map[string]Level {
+	return std.TagLevels()
+}
+
+// applyTagConfig 按配置设置模块级别和采样
+func applyTagConfig(conf *Config) error {
+	levels := make(map[string]Level, len(conf.Levels))
+	for tag, s := range conf.Levels {
+		level, err := ParseLevel(s)
+		if err != nil {
+			return err
+		}
+		levels[tag] = level
+	}
+	std.SetTagLevels(levels)
+	std.SetSampling(conf.Sampling)
+	return nil
+}
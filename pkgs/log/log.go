@@ -1,145 +1,250 @@
 package log
 
+// 统一的结构化日志，所有日志都经过 std 输出到配置的 handler ：
+//   log.Infof(log.TagDB, "db connected")           按模块输出
+//   log.WithTag(log.TagDB).With(log.Params{...})    带字段的子 Logger
+// logrus 、 gin 、 gorm 、 nacos 的日志通过 adapter.go 中的适配器转到 std
+//
+// 配置项(均在 log 下，环境变量如 LOG_LEVEL)：
+//   level    日志级别，默认 info ，修改后立即生效
+//   format   json 或 text ，为空时 ENV=local 使用 text ，其他使用 json
+//   file     同时写入的文件，按大小和时间切割，见 FileConfig ，file.path 为空时不写
+//   network  发送日志的网络类型 tcp/udp ，默认 tcp
+//   addr     同时发送到的地址，如 logstash ，为空时不发送
+//   async    异步写入，默认开启，见 AsyncConfig
+//   levels   模块级别， sampling 模块采样，见 level.go
+// 配置变化时级别和采样立即生效，输出需要重新 Init
+
 import (
 	"fmt"
+	"night-fury/pkgs/config"
 	"os"
-	"runtime"
+	"sync"
+	"time"
 
 	"github.com/gogf/gf/util/gconv"
 	jsoniter "github.com/json-iterator/go"
-	"github.com/sirupsen/logrus"
-	"gitlab.lanhuapp.com/gopkgs/config"
 )
 
-var logger *logrus.Logger
-
 type Params map[string]interface{}
 
+// Config 日志配置
+type Config struct {
+	Level   string      `mapstructure:"level" default:"info" validate:"oneof=debug info warn warning error panic fatal"`
+	Format  string      `mapstructure:"format" validate:"omitempty,oneof=json text"`
+	File    FileConfig  `mapstructure:"file"`
+	Network string      `mapstructure:"network" default:"tcp" validate:"oneof=tcp udp"`
+	Addr    string      `mapstructure:"addr"`
+	Async   AsyncConfig `mapstructure:"async"`
+
+	Levels   map[string]string   `mapstructure:"levels"`
+	Sampling map[string]Sampling `mapstructure:"sampling"`
+}
+
+// AsyncConfig 异步写入，每个 handler 一个有界队列，队列满时按 DropPolicy 处理
+type AsyncConfig struct {
+	Enabled    bool   `mapstructure:"enabled" default:"true"`
+	QueueSize  int    `mapstructure:"queue_size" default:"4096" validate:"gte=1"`
+	DropPolicy string `mapstructure:"drop_policy" default:"newest" validate:"oneof=newest oldest block"`
+}
+
+// 退出时等待异步日志写入的时间
+const flushTimeout = time.Second * 5
+
+var (
+	std    = NewLogger(LevelInfo, NewWriterHandler(os.Stdout, &JSONFormatter{}))
+	initMu sync.Mutex
+)
+
 func init() {
-	logger = logrus.StandardLogger()
-	setLoggerLevel()
-	switch env := os.Getenv("ENV"); env {
-	case "local":
-		logger.SetFormatter(&logrus.TextFormatter{})
-	case "development":
-		fallthrough
-	case "dev":
-		fallthrough
-	case "pre-production":
-		fallthrough
-	case "production":
-		fallthrough
-	default:
-		logger.SetFormatter(&logrus.JSONFormatter{
-			DisableTimestamp: true,
-		})
+	conf, err := LoadConfig()
+	if err == nil {
+		err = Init(conf)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init logger error : %s\n", err)
 	}
-	logger.Infoln("Logger initialization successful")
+	installAdapters()
+	std.WithTag(TagInit).log(-1, LevelInfo, nil, "Logger initialization successful")
+
+	// 修改配置中的级别和采样后立即生效
+	config.OnChange("log", reloadLevels)
 }
 
-func setLoggerLevel() {
-	if logger != nil {
-		config.SetDefault("log_level", "info")
-		logLevel := config.GetString("log_level")
+func LoadConfig() (*Config, error) {
+	conf := &Config{}
+	if err := config.Unmarshal("log", conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// Init 按配置设置级别和 handler ，原来的 handler 会被关闭
+func Init(conf *Config) error {
+	initMu.Lock()
+	defer initMu.Unlock()
+
+	level, err := ParseLevel(conf.Level)
+	if err != nil {
+		return err
+	}
+
+	var f Formatter = &JSONFormatter{}
+	if conf.Format == "text" || (conf.Format == "" && config.Profile() == "local") {
+		f = &TextFormatter{}
+	}
 
-		level, err := logrus.ParseLevel(logLevel)
+	handlers := []Handler{NewWriterHandler(os.Stdout, f)}
+	if conf.File.Path != "" {
+		// 文件和网络使用 json ，便于采集
+		w, err := NewRotateWriter(&conf.File)
 		if err != nil {
-			panic("init logger module failed: " + err.Error())
+			return err
+		}
+		handlers = append(handlers, NewWriterHandler(w, &JSONFormatter{}))
+	}
+	if conf.Addr != "" {
+		handlers = append(handlers, NewNetworkHandler(conf.Network, conf.Addr, &JSONFormatter{}))
+	}
+	if conf.Async.Enabled {
+		for i, h := range handlers {
+			handlers[i] = NewAsyncHandler(h, conf.Async.QueueSize, conf.Async.DropPolicy)
 		}
-		logger.SetLevel(level)
 	}
+
+	if err := applyTagConfig(conf); err != nil {
+		return err
+	}
+	std.SetLevel(level)
+	return closeHandlers(std.SetHandlers(handlers...))
 }
 
-func withCommonFields(t string, f ...Params) *logrus.Entry {
-	var fields Params
-	if len(f) > 0 {
-		fields = f[0]
+func reloadLevels() {
+	conf, err := LoadConfig()
+	if err != nil {
+		Errorf(TagInit, "reload log config failed: %s", err)
+		return
 	}
-	if len(f) == 0 {
-		fields = make(Params)
+	level, err := ParseLevel(conf.Level)
+	if err == nil {
+		err = applyTagConfig(conf)
 	}
-	pc, file, line, ok := runtime.Caller(2)
-	if !ok {
-		logger.WithField("mod", "log_module").Errorln("get caller failed.")
+	if err != nil {
+		Errorf(TagInit, "reload log level failed: %s", err)
+		return
 	}
-	caller := runtime.FuncForPC(pc)
+	std.SetLevel(level)
+	Infof(TagInit, "log level changed to %s, module levels : %v", level, conf.Levels)
+}
 
-	// common field
-	fields["mod"] = t
-	if _, ok := fields["debug"]; !ok {
-		fields["debug"] = fmt.Sprintf("log from [%s#%d], function is [%s]", file, line, caller.Name())
-	}
+// Default 全局 Logger
+func Default() *Logger {
+	return std
+}
 
-	return logger.WithFields(logrus.Fields(fields))
+// WithTag 全局 Logger 指定模块的子 Logger
+func WithTag(tag string) *Logger {
+	return std.WithTag(tag)
+}
+
+func SetLevel(level Level) {
+	std.SetLevel(level)
+}
+
+func GetLevel() Level {
+	return std.GetLevel()
+}
+
+// Count 全局 Logger level 级别输出的日志数，用于监控
+func Count(level Level) uint64 {
+	return std.Count(level)
+}
+
+// Flush 等待异步日志写入完成，进程退出前调用
+func Flush() error {
+	return std.Flush(flushTimeout)
+}
+
+// Close 写完异步日志后关闭全局 Logger 的 handler ，退出前调用
+func Close() error {
+	return std.Close()
+}
+
+func output(tag string, level Level, fields Params, msg string) {
+	// 跳过 output 和调用它的包级函数
+	std.WithTag(tag).log(2, level, fields, msg)
 }
 
 func Debugln(tag string, args ...interface{}) {
-	withCommonFields(tag).Debugln(args...)
+	output(tag, LevelDebug, nil, fmt.Sprint(args...))
 }
 func Debugf(tag string, format string, args ...interface{}) {
-	withCommonFields(tag).Debugf(format, args...)
+	output(tag, LevelDebug, nil, fmt.Sprintf(format, args...))
 }
 func DebuglnWithField(tag string, fields Params, args ...interface{}) {
-	withCommonFields(tag, fields).Debugln(args...)
+	output(tag, LevelDebug, fields, fmt.Sprint(args...))
 }
 func DebugfWithField(tag string, fields Params, format string, args ...interface{}) {
-	withCommonFields(tag, fields).Debugf(format, args...)
+	output(tag, LevelDebug, fields, fmt.Sprintf(format, args...))
 }
 
 func Infoln(tag string, args ...interface{}) {
-	withCommonFields(tag).Infoln(args...)
+	output(tag, LevelInfo, nil, fmt.Sprint(args...))
 }
 func Infof(tag, format string, args ...interface{}) {
-	withCommonFields(tag).Infof(format, args...)
+	output(tag, LevelInfo, nil, fmt.Sprintf(format, args...))
 }
 func InfolnWithField(tag string, fields Params, args ...interface{}) {
-	withCommonFields(tag, fields).Infoln(args...)
+	output(tag, LevelInfo, fields, fmt.Sprint(args...))
 }
 func InfofWithField(tag string, fields Params, format string, args ...interface{}) {
-	withCommonFields(tag, fields).Infof(format, args...)
+	output(tag, LevelInfo, fields, fmt.Sprintf(format, args...))
 }
 
 func Warnln(tag string, args ...interface{}) {
-	withCommonFields(tag).Warnln(args...)
+	output(tag, LevelWarn, nil, fmt.Sprint(args...))
 }
 func Warnf(tag, format string, args ...interface{}) {
-	withCommonFields(tag).Warnf(format, args...)
+	output(tag, LevelWarn, nil, fmt.Sprintf(format, args...))
 }
 func WarnlnWithField(tag string, fields Params, args ...interface{}) {
-	withCommonFields(tag, fields).Warnln(args...)
+	output(tag, LevelWarn, fields, fmt.Sprint(args...))
 }
 func WarnfWithField(tag string, fields Params, format string, args ...interface{}) {
-	withCommonFields(tag, fields).Warnf(format, args...)
+	output(tag, LevelWarn, fields, fmt.Sprintf(format, args...))
 }
 
 func Errorln(tag string, args ...interface{}) {
-	withCommonFields(tag).Errorln(args...)
+	output(tag, LevelError, nil, fmt.Sprint(args...))
 }
 func Errorf(tag, format string, args ...interface{}) {
-	withCommonFields(tag).Errorf(format, args...)
+	output(tag, LevelError, nil, fmt.Sprintf(format, args...))
 }
 func ErrorlnWithField(tag string, fields Params, args ...interface{}) {
-	withCommonFields(tag, fields).Errorln(args...)
+	output(tag, LevelError, fields, fmt.Sprint(args...))
 }
 func ErrorfWithField(tag string, fields Params, format string, args ...interface{}) {
-	withCommonFields(tag, fields).Errorf(format, args...)
+	output(tag, LevelError, fields, fmt.Sprintf(format, args...))
+}
+
+func Panicln(tag string, args ...interface{}) {
+	output(tag, LevelPanic, nil, fmt.Sprint(args...))
+}
+func Panicf(tag, format string, args ...interface{}) {
+	output(tag, LevelPanic, nil, fmt.Sprintf(format, args...))
 }
 
 func Fatalln(tag string, args ...interface{}) {
-	withCommonFields(tag).Fatalln(args...)
-	os.Exit(-1)
+	output(tag, LevelFatal, nil, fmt.Sprint(args...))
 }
 func Fatalf(tag, format string, args ...interface{}) {
-	withCommonFields(tag).Fatalf(format, args...)
-	os.Exit(-1)
+	output(tag, LevelFatal, nil, fmt.Sprintf(format, args...))
 }
 func FatallnWithField(tag string, fields Params, args ...interface{}) {
-	withCommonFields(tag, fields).Errorln(args...)
-	os.Exit(-1)
+	output(tag, LevelFatal, fields, fmt.Sprint(args...))
 }
 func FatalfWithField(tag string, fields Params, format string, args ...interface{}) {
-	withCommonFields(tag, fields).Errorf(format, args...)
-	os.Exit(-1)
+	output(tag, LevelFatal, fields, fmt.Sprintf(format, args...))
 }
 
 // ParseParams 将struct/map格式转成 params
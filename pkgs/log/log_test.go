@@ -0,0 +1,289 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"night-fury/pkgs/tenant"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// useBuffer 把 std 的输出换成 buffer ，测试结束后恢复
+func useBuffer(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	old := std.SetHandlers(NewWriterHandler(buf, &JSONFormatter{}))
+	level := std.GetLevel()
+	t.Cleanup(func() {
+		std.SetHandlers(old...)
+		std.SetLevel(level)
+	})
+	return buf
+}
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		m := map[string]interface{}{}
+		assert.Nil(t, jsoniter.UnmarshalFromString(line, &m))
+		res = append(res, m)
+	}
+	return res
+}
+
+func TestLogger(t *testing.T) {
+	buf := useBuffer(t)
+	SetLevel(LevelInfo)
+
+	Debugf(TagDB, "hidden")
+	InfofWithField(TagDB, Params{"user": "u1", "msg": "conflict"}, "hello %s", "world")
+	WithTag(TagJob).With(Params{"job": "j1"}).Warn("retry")
+
+	res := lines(t, buf)
+	assert.Len(t, res, 2)
+	assert.Equal(t, "info", res[0]["level"])
+	assert.Equal(t, "hello world", res[0]["msg"])
+	assert.Equal(t, TagDB, res[0]["mod"])
+	assert.Equal(t, "u1", res[0]["user"])
+	assert.Equal(t, "conflict", res[0]["fields.msg"])
+	assert.Contains(t, res[0]["debug"], "log_test.go")
+
+	assert.Equal(t, "warn", res[1]["level"])
+	assert.Equal(t, TagJob, res[1]["mod"])
+	assert.Equal(t, "j1", res[1]["job"])
+	assert.Contains(t, res[1]["debug"], "log_test.go")
+
+	l, err := ParseLevel("WARNING")
+	assert.Nil(t, err)
+	assert.Equal(t, LevelWarn, l)
+	_, err = ParseLevel("verbose")
+	assert.NotNil(t, err)
+}
+
+func TestAdapters(t *testing.T) {
+	buf := useBuffer(t)
+	SetLevel(LevelDebug)
+
+	logrus.WithField("k", "v").Warn("from logrus")
+	Writer(TagServer, LevelError).Write([]byte("line1\nline2\n"))
+
+	g := GormLogger(TagDB)
+	g.Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+	g.Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT 2", 0 }, gormlogger.ErrRecordNotFound)
+	g.LogMode(gormlogger.Silent).Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT 3", 0 }, nil)
+
+	res := lines(t, buf)
+	assert.Len(t, res, 5)
+	assert.Equal(t, "from logrus", res[0]["msg"])
+	assert.Equal(t, TagLogrus, res[0]["mod"])
+	assert.Equal(t, "v", res[0]["k"])
+	assert.Equal(t, "line2", res[2]["msg"])
+	assert.Equal(t, "error", res[2]["level"])
+	assert.Equal(t, "SELECT 1", res[3]["sql"])
+	assert.Equal(t, "debug", res[3]["level"])
+	// record not found 不算错误
+	assert.Equal(t, "debug", res[4]["level"])
+}
+
+func TestHandlers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "logs", "app.log")
+	w, err := NewRotateWriter(&FileConfig{Path: file})
+	assert.Nil(t, err)
+	fh := NewWriterHandler(w, &JSONFormatter{})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	l := NewLogger(LevelInfo, fh, NewNetworkHandler("tcp", ln.Addr().String(), &JSONFormatter{}))
+	l.WithTag(TagServer).Infof("to %s", "handlers")
+	assert.Nil(t, l.Close())
+
+	data, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"msg":"to handlers"`)
+	select {
+	case line := <-received:
+		assert.Contains(t, line, `"msg":"to handlers"`)
+	case <-time.After(time.Second * 3):
+		t.Fatal("network handler not received")
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	buf := useBuffer(t)
+
+	ctx := WithRequestID(tenant.WithID(context.Background(), "tenant1"), "req1")
+	assert.Equal(t, "req1", RequestID(ctx))
+	WithContext(ctx).WithTag(TagDB).Info("with context")
+	GormLogger(TagDB).Error(ctx, "gorm %s", "error")
+	WithContext(context.Background()).Info("without context")
+
+	res := lines(t, buf)
+	assert.Len(t, res, 3)
+	assert.Equal(t, "req1", res[0]["request_id"])
+	assert.Equal(t, "tenant1", res[0]["tenant_id"])
+	assert.Equal(t, "req1", res[1]["request_id"])
+	assert.Nil(t, res[2]["request_id"])
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}})
+	WithContext(trace.ContextWithSpanContext(ctx, sc)).Info("with span")
+	res = lines(t, buf)
+	assert.Equal(t, sc.TraceID().String(), res[3]["trace_id"])
+	assert.Equal(t, sc.SpanID().String(), res[3]["span_id"])
+}
+
+// blockHandler 在 release 关闭前阻塞，用于填满异步队列
+type blockHandler struct {
+	release chan struct{}
+	mu      sync.Mutex
+	msgs    []string
+}
+
+func (h *blockHandler) Handle(e *Entry) error {
+	<-h.release
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.msgs = append(h.msgs, e.Message)
+	return nil
+}
+
+func (h *blockHandler) Close() error { return nil }
+
+func TestAsyncHandler(t *testing.T) {
+	for policy, expected := range map[string][]string{
+		DropNewest: {"m0", "m1", "m2", "err"},
+		DropOldest: {"m0", "m2", "m3", "err"},
+	} {
+		h := &blockHandler{release: make(chan struct{})}
+		a := NewAsyncHandler(h, 2, policy)
+		l := NewLogger(LevelDebug, a)
+
+		// 第一条被取出后阻塞在 handler 中，队列中再放两条
+		l.Info("m0")
+		time.Sleep(time.Millisecond * 50)
+		for i := 1; i < 4; i++ {
+			l.Infof("m%d", i)
+		}
+		assert.Equal(t, uint64(1), a.Dropped(), policy)
+
+		// error 级别不会被丢弃，等待队列有空位
+		go l.Error("err")
+		time.Sleep(time.Millisecond * 50)
+		close(h.release)
+
+		assert.Nil(t, a.Flush(time.Second))
+		assert.Nil(t, l.Close())
+		assert.Equal(t, expected, h.msgs, policy)
+		assert.Equal(t, ErrHandlerClosed, a.Handle(&Entry{}))
+	}
+}
+
+func TestRotateWriter(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log")
+	w, err := NewRotateWriter(&FileConfig{Path: file, MaxBackups: 2, Compress: true})
+	assert.Nil(t, err)
+	w.maxBytes = 10
+
+	for i := 0; i < 5; i++ {
+		_, err = w.Write([]byte("0123456789"))
+		assert.Nil(t, err)
+		// 历史文件名精确到毫秒
+		time.Sleep(time.Millisecond * 2)
+	}
+	assert.Nil(t, w.Close())
+
+	// 切割 4 次，只保留最新的 2 个压缩文件
+	backups, err := w.backups()
+	assert.Nil(t, err)
+	assert.Len(t, backups, 2)
+	for _, b := range backups {
+		assert.True(t, strings.HasSuffix(b.path, ".log.gz"), b.path)
+	}
+	data, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	// 按时间切割
+	w, err = NewRotateWriter(&FileConfig{Path: file, Interval: time.Hour})
+	assert.Nil(t, err)
+	w.nextRotate = time.Now().Add(-time.Second)
+	w.Write([]byte("x"))
+	assert.Nil(t, w.Close())
+	backups, _ = w.backups()
+	assert.Len(t, backups, 3)
+}
+
+func TestTagLevels(t *testing.T) {
+	buf := useBuffer(t)
+	SetLevel(LevelWarn)
+	SetTagLevel(TagDB, LevelDebug)
+	t.Cleanup(func() { std.SetTagLevels(nil) })
+
+	Debugf(TagDB, "db debug")
+	Infof(TagJob, "job info")
+	GormLogger(TagDB).Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+
+	ResetTagLevel(TagDB)
+	Infof(TagDB, "db info")
+
+	res := lines(t, buf)
+	assert.Len(t, res, 2)
+	assert.Equal(t, "db debug", res[0]["msg"])
+	assert.Equal(t, "SELECT 1", res[1]["sql"])
+	assert.Empty(t, TagLevels())
+
+	// 配置中的模块级别
+	assert.Nil(t, applyTagConfig(&Config{Levels: map[string]string{TagJob: "debug"}}))
+	assert.Equal(t, map[string]Level{TagJob: LevelDebug}, TagLevels())
+	assert.NotNil(t, applyTagConfig(&Config{Levels: map[string]string{TagJob: "verbose"}}))
+}
+
+func TestSampling(t *testing.T) {
+	buf := useBuffer(t)
+	SetLevel(LevelDebug)
+	std.SetSampling(map[string]Sampling{TagWS: {First: 2, Thereafter: 3, Tick: time.Minute}})
+	t.Cleanup(func() { std.SetSampling(nil) })
+
+	for i := 1; i <= 8; i++ {
+		Infof(TagWS, "ws %d", i)
+		Infof(TagDB, "db %d", i)
+	}
+	// error 不采样
+	Errorf(TagWS, "ws error")
+
+	var ws []interface{}
+	for _, r := range lines(t, buf) {
+		if r["mod"] == TagWS {
+			ws = append(ws, r["msg"])
+		}
+	}
+	assert.Equal(t, []interface{}{"ws 1", "ws 2", "ws 5", "ws 8", "ws error"}, ws)
+	assert.Equal(t, uint64(4), std.SampledOut(TagWS))
+	assert.Len(t, lines(t, buf), 13)
+}
@@ -0,0 +1,259 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Level 日志级别
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelPanic
+	LevelFatal
+)
+
+var levelNames = []string{"debug", "info", "warn", "error", "panic", "fatal"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelFatal {
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel 解析 debug 、 info 、 warn(warning) 、 error 、 panic 、 fatal
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "warning" {
+		return LevelWarn, nil
+	}
+	for i, name := range levelNames {
+		if s == name {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, errors.Errorf("log: unknown level %q", s)
+}
+
+// Entry 一条日志
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Tag     string // 模块，如 TagDB
+	Message string
+	Fields  Params
+	Caller  string // 调用位置，没有时为空
+}
+
+// Logger 结构化日志，With 、 WithTag 返回带字段的子 Logger ，共享级别和 handler
+type Logger struct {
+	core   *core
+	tag    string
+	fields Params
+}
+
+type core struct {
+	level    int32
+	tags     *tagOptions
+	counts   [LevelFatal + 1]uint64 // 各级别输出的日志数
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+// NewLogger 创建日志，没有 handler 时丢弃所有日志
+func NewLogger(level Level, handlers ...Handler) *Logger {
+	return &Logger{core: &core{level: int32(level), tags: newTagOptions(), handlers: handlers}}
+}
+
+func (l *Logger) SetLevel(level Level) {
+	atomic.StoreInt32(&l.core.level, int32(level))
+}
+
+func (l *Logger) GetLevel() Level {
+	return Level(atomic.LoadInt32(&l.core.level))
+}
+
+// Enabled level 级别的日志是否会输出，用于避免构造不需要的日志内容
+// 模块单独设置了级别时使用模块的级别
+func (l *Logger) Enabled(level Level) bool {
+	if l.tag != "" {
+		if tagLevel, ok := l.core.tags.level(l.tag); ok {
+			return level >= tagLevel
+		}
+	}
+	return level >= l.GetLevel()
+}
+
+// SetHandlers 替换 handler ，返回原来的 handler ，由调用方关闭
+func (l *Logger) SetHandlers(handlers ...Handler) []Handler {
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+	old := l.core.handlers
+	l.core.handlers = handlers
+	return old
+}
+
+func (l *Logger) AddHandler(h Handler) {
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+	l.core.handlers = append(l.core.handlers, h)
+}
+
+// Flush 等待异步 handler 中的日志写入完成
+func (l *Logger) Flush(timeout time.Duration) error {
+	l.core.mu.RLock()
+	defer l.core.mu.RUnlock()
+	for _, h := range l.core.handlers {
+		if a, ok := h.(*AsyncHandler); ok {
+			if err := a.Flush(timeout); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close 关闭所有 handler
+func (l *Logger) Close() error {
+	return closeHandlers(l.SetHandlers())
+}
+
+// WithTag 返回指定模块的子 Logger
+func (l *Logger) WithTag(tag string) *Logger {
+	return &Logger{core: l.core, tag: tag, fields: l.fields}
+}
+
+// With 返回带字段的子 Logger ，字段会合并到每条日志中
+func (l *Logger) With(fields Params) *Logger {
+	merged := make(Params, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{core: l.core, tag: l.tag, fields: merged}
+}
+
+func (l *Logger) WithField(key string, value interface{}) *Logger {
+	return l.With(Params{key: value})
+}
+
+// 以下方法同时满足 nacos 等第三方库的 Logger 接口
+
+func (l *Logger) Debug(args ...interface{}) { l.log(1, LevelDebug, nil, fmt.Sprint(args...)) }
+func (l *Logger) Info(args ...interface{})  { l.log(1, LevelInfo, nil, fmt.Sprint(args...)) }
+func (l *Logger) Warn(args ...interface{})  { l.log(1, LevelWarn, nil, fmt.Sprint(args...)) }
+func (l *Logger) Error(args ...interface{}) { l.log(1, LevelError, nil, fmt.Sprint(args...)) }
+func (l *Logger) Panic(args ...interface{}) { l.log(1, LevelPanic, nil, fmt.Sprint(args...)) }
+func (l *Logger) Fatal(args ...interface{}) { l.log(1, LevelFatal, nil, fmt.Sprint(args...)) }
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(1, LevelDebug, nil, fmt.Sprintf(format, args...))
+}
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(1, LevelInfo, nil, fmt.Sprintf(format, args...))
+}
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.log(1, LevelWarn, nil, fmt.Sprintf(format, args...))
+}
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(1, LevelError, nil, fmt.Sprintf(format, args...))
+}
+func (l *Logger) Panicf(format string, args ...interface{}) {
+	l.log(1, LevelPanic, nil, fmt.Sprintf(format, args...))
+}
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.log(1, LevelFatal, nil, fmt.Sprintf(format, args...))
+}
+
+// log 输出日志， skip 为调用方相对 log 的栈深度，小于 0 时不记录调用位置
+// panic 级别输出后 panic ， fatal 级别输出后关闭 handler 并退出进程
+func (l *Logger) log(skip int, level Level, fields Params, msg string) {
+	if l.Enabled(level) {
+		caller := ""
+		if skip >= 0 {
+			caller = callerOf(skip + 1)
+		}
+		l.write(level, fields, msg, caller)
+	}
+
+	switch level {
+	case LevelPanic:
+		l.Flush(flushTimeout)
+		panic(msg)
+	case LevelFatal:
+		l.Close()
+		os.Exit(1)
+	}
+}
+
+// Count level 级别输出的日志数，不包括采样丢弃的
+func (l *Logger) Count(level Level) uint64 {
+	if level < LevelDebug || level > LevelFatal {
+		return 0
+	}
+	return atomic.LoadUint64(&l.core.counts[level])
+}
+
+func (l *Logger) write(level Level, fields Params, msg, caller string) {
+	if s := l.core.tags.sampler(l.tag); s != nil && !s.allow(level) {
+		return
+	}
+	if level >= LevelDebug && level <= LevelFatal {
+		atomic.AddUint64(&l.core.counts[level], 1)
+	}
+
+	e := &Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Tag:     l.tag,
+		Message: msg,
+		Fields:  make(Params, len(l.fields)+len(fields)),
+		Caller:  caller,
+	}
+	for k, v := range l.fields {
+		e.Fields[k] = v
+	}
+	for k, v := range fields {
+		e.Fields[k] = v
+	}
+	redact(e)
+
+	l.core.mu.RLock()
+	defer l.core.mu.RUnlock()
+	for _, h := range l.core.handlers {
+		if err := h.Handle(e); err != nil {
+			writeError(err)
+		}
+	}
+}
+
+// writeError handler 出错时不能再写日志，直接输出到 stderr
+func writeError(err error) {
+	fmt.Fprintf(os.Stderr, "log: handle entry error : %s\n", err)
+}
+
+func callerOf(skip int) string {
+	pc, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+	name := ""
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		name = fn.Name()
+	}
+	return fmt.Sprintf("log from [%s#%d], function is [%s]", file, line, name)
+}
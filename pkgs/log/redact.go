@@ -0,0 +1,15 @@
+package log
+
+import (
+	"night-fury/pkgs/config"
+)
+
+// redact 把日志中出现的密码等敏感配置替换为 ******
+func redact(e *Entry) {
+	e.Message = config.Redact(e.Message)
+	for k, v := range e.Fields {
+		if s, ok := v.(string); ok {
+			e.Fields[k] = config.Redact(s)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 历史文件名中的时间格式，如 app-20210720T150405.000.log
+const backupTimeFormat = "20060102T150405.000"
+
+// FileConfig 日志文件，按大小或时间切割
+type FileConfig struct {
+	Path       string        `mapstructure:"path"`                    // 为空时不写文件
+	MaxSize    int           `mapstructure:"max_size" default:"100"`  // 单个文件最大 MB ， 0 时不按大小切割
+	Interval   time.Duration `mapstructure:"interval" default:"24h"`  // 按时间切割的间隔， 0 时不按时间切割
+	MaxBackups int           `mapstructure:"max_backups" default:"7"` // 保留的历史文件数， 0 时不限制
+	MaxAge     time.Duration `mapstructure:"max_age" default:"168h"`  // 历史文件保留时间， 0 时不限制
+	Compress   bool          `mapstructure:"compress" default:"true"` // 历史文件使用 gzip 压缩
+}
+
+// RotateWriter 按大小和时间切割的文件，切割后在后台压缩和清理历史文件
+type RotateWriter struct {
+	mu         sync.Mutex
+	conf       FileConfig
+	maxBytes   int64
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	wg         sync.WaitGroup // 压缩和清理
+	postMu     sync.Mutex     // 多次切割时依次压缩和清理
+}
+
+func NewRotateWriter(conf *FileConfig) (*RotateWriter, error) {
+	w := &RotateWriter{conf: *conf, maxBytes: int64(conf.MaxSize) * 1024 * 1024}
+	if err := os.MkdirAll(filepath.Dir(conf.Path), 0755); err != nil {
+		return nil, errors.Wrap(err, "log: create log dir")
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, ErrHandlerClosed
+	}
+	if w.shouldRotate(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate 立即切割
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate()
+}
+
+// Close 关闭文件，并等待后台的压缩和清理完成
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	return err
+}
+
+func (w *RotateWriter) shouldRotate(n int) bool {
+	if w.size == 0 {
+		// 空文件不切割
+		w.setNextRotate()
+		return false
+	}
+	if w.maxBytes > 0 && w.size+int64(n) > w.maxBytes {
+		return true
+	}
+	return w.conf.Interval > 0 && !time.Now().Before(w.nextRotate)
+}
+
+func (w *RotateWriter) setNextRotate() {
+	if w.conf.Interval > 0 {
+		// 按间隔对齐，如 24h 时在每天 0 点(UTC)切割
+		w.nextRotate = time.Now().Truncate(w.conf.Interval).Add(w.conf.Interval)
+	}
+}
+
+func (w *RotateWriter) open() error {
+	file, err := os.OpenFile(w.conf.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "log: open log file")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "log: stat log file")
+	}
+
+	w.file, w.size = file, info.Size()
+	w.setNextRotate()
+	return nil
+}
+
+func (w *RotateWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return errors.Wrap(err, "log: close log file")
+		}
+		w.file = nil
+	}
+
+	backup := w.backupName(time.Now())
+	if err := os.Rename(w.conf.Path, backup); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "log: rename log file")
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.postMu.Lock()
+		defer w.postMu.Unlock()
+
+		if w.conf.Compress {
+			if err := compressFile(backup); err != nil {
+				writeError(err)
+			}
+		}
+		if err := w.cleanup(); err != nil {
+			writeError(err)
+		}
+	}()
+	return nil
+}
+
+func (w *RotateWriter) backupName(t time.Time) string {
+	dir, base := filepath.Split(w.conf.Path)
+	ext := filepath.Ext(base)
+	return filepath.Join(dir, strings.TrimSuffix(base, ext)+"-"+t.Format(backupTimeFormat)+ext)
+}
+
+type backupFile struct {
+	path string
+	time time.Time
+}
+
+// backups 历史文件，按时间从新到旧排列
+func (w *RotateWriter) backups() ([]backupFile, error) {
+	dir, base := filepath.Split(w.conf.Path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, errors.Wrap(err, "log: read log dir")
+	}
+
+	var files []backupFile
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".gz")
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext), time.Local)
+		if err != nil {
+			continue
+		}
+		files = append(files, backupFile{path: filepath.Join(dir, e.Name()), time: t})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].time.After(files[j].time) })
+	return files, nil
+}
+
+// cleanup 删除超过数量或时间的历史文件
+func (w *RotateWriter) cleanup() error {
+	if w.conf.MaxBackups <= 0 && w.conf.MaxAge <= 0 {
+		return nil
+	}
+	files, err := w.backups()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-w.conf.MaxAge)
+	for i, f := range files {
+		expired := w.conf.MaxAge > 0 && f.time.Before(cutoff)
+		if (w.conf.MaxBackups > 0 && i >= w.conf.MaxBackups) || expired {
+			if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+				return errors.Wrap(err, "log: remove old log file")
+			}
+		}
+	}
+	return nil
+}
+
+// compressFile 压缩为 .gz 后删除原文件
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "log: open log file for compress")
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Wrap(err, "log: create compressed file")
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "log: compress log file")
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return errors.Wrap(err, "log: rename compressed file")
+	}
+	return errors.Wrap(os.Remove(path), "log: remove compressed log file")
+}
@@ -7,6 +7,14 @@ var (
 	TagWSClient = "mod_ws_client"
 	TagWS       = "mod_ws"
 	TagDB       = "mod_db"
+	TagKafka    = "mod_kafka"
+	TagRedis    = "mod_redis"
+	TagLock     = "mod_lock"
+	TagJob      = "mod_job"
+	TagNacos    = "mod_nacos"
+	TagLogrus   = "mod_logrus"
+	TagTracing  = "mod_tracing"
+	TagMetrics  = "mod_metrics"
 
 	TagActionJoin = "act_join"
 )
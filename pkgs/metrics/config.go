@@ -0,0 +1,18 @@
+package metrics
+
+import "night-fury/pkgs/config"
+
+// Config prometheus 指标配置
+type Config struct {
+	Enabled bool   `mapstructure:"enabled" default:"true"`
+	Path    string `mapstructure:"path" default:"/metrics"`
+}
+
+// LoadConfig 读取 metrics 下的配置
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	if err := config.Unmarshal("metrics", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,131 @@
+package metrics
+
+// prometheus 指标，所有指标以 night_fury_ 为前缀
+//   内置： http 请求、 ws 连接和消息、 db 连接池、日志数量、 go runtime 和进程
+//   业务指标通过 NewCounterVec 等创建，创建时自动注册，名称重复时 panic ，需要在包级别变量或 init 中创建
+//     var orderCount = metrics.NewCounterVec("order", "created_total", "created orders", "type")
+//     orderCount.WithLabelValues("vip").Inc()
+//   已有的 prometheus.Collector 使用 Register 注册
+
+import (
+	"net/http"
+	"night-fury/pkgs/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const Namespace = "night_fury"
+
+var (
+	registry = prometheus.NewRegistry()
+	conf     = &Config{Enabled: true, Path: "/metrics"}
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		logCollector{desc: prometheus.NewDesc(Namespace+"_log_entries_total", "log entries by level", []string{"level"}, nil)},
+	)
+}
+
+// Init 设置配置，需要在创建 http server 之前调用
+func Init(c *Config) {
+	conf = c
+}
+
+// GetConfig 当前配置
+func GetConfig() *Config {
+	return conf
+}
+
+// Handler 输出所有指标的 http handler
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		ErrorLog: promLogger{},
+		Registry: registry,
+	})
+}
+
+// Registry 注册了所有指标的 registry ，测试时可以用 testutil 读取指标
+func Registry() *prometheus.Registry {
+	return registry
+}
+
+// Register 注册 collector
+func Register(c prometheus.Collector) error {
+	return registry.Register(c)
+}
+
+// MustRegister 注册 collector ，失败时 panic
+func MustRegister(cs ...prometheus.Collector) {
+	registry.MustRegister(cs...)
+}
+
+// NewCounterVec 创建并注册 counter ，名称为 night_fury_<subsystem>_<name>
+func NewCounterVec(subsystem, name, help string, labels ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: subsystem, Name: name, Help: help,
+	}, labels)
+	registry.MustRegister(c)
+	return c
+}
+
+// NewGaugeVec 创建并注册 gauge
+func NewGaugeVec(subsystem, name, help string, labels ...string) *prometheus.GaugeVec {
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace, Subsystem: subsystem, Name: name, Help: help,
+	}, labels)
+	registry.MustRegister(g)
+	return g
+}
+
+// NewHistogramVec 创建并注册 histogram ，buckets 为空时使用 prometheus.DefBuckets
+func NewHistogramVec(subsystem, name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace, Subsystem: subsystem, Name: name, Help: help, Buckets: buckets,
+	}, labels)
+	registry.MustRegister(h)
+	return h
+}
+
+// NewGaugeFunc 创建并注册采集时调用 f 的 gauge ，用于已有的统计数据，如连接数
+func NewGaugeFunc(subsystem, name, help string, f func() float64) prometheus.GaugeFunc {
+	g := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: Namespace, Subsystem: subsystem, Name: name, Help: help,
+	}, f)
+	registry.MustRegister(g)
+	return g
+}
+
+// NewCounterFunc 创建并注册采集时调用 f 的 counter ，f 的返回值只能增加
+func NewCounterFunc(subsystem, name, help string, f func() float64) prometheus.CounterFunc {
+	c := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: subsystem, Name: name, Help: help,
+	}, f)
+	registry.MustRegister(c)
+	return c
+}
+
+// logCollector 采集时读取各级别的日志数， log 包不依赖 prometheus
+type logCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c logCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c logCollector) Collect(ch chan<- prometheus.Metric) {
+	for level := log.LevelDebug; level <= log.LevelFatal; level++ {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(log.Count(level)), level.String())
+	}
+}
+
+type promLogger struct{}
+
+func (promLogger) Println(v ...interface{}) {
+	log.Errorln(log.TagMetrics, v...)
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"night-fury/pkgs/log"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	c := NewCounterVec("test", "orders_total", "test orders", "type")
+	c.WithLabelValues("vip").Add(2)
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.WithLabelValues("vip")))
+	NewGaugeFunc("test", "queue", "test queue", func() float64 { return 3 })
+
+	// 名称重复时 panic
+	assert.Panics(t, func() { NewCounterVec("test", "orders_total", "test orders", "type") })
+
+	before := log.Count(log.LevelWarn)
+	log.Warnf(log.TagMetrics, "count me")
+	assert.Equal(t, before+1, log.Count(log.LevelWarn))
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, body, `night_fury_test_orders_total{type="vip"} 2`)
+	assert.Contains(t, body, `night_fury_test_queue 3`)
+	assert.Contains(t, body, `night_fury_log_entries_total{level="warn"}`)
+	assert.Contains(t, body, `go_goroutines`)
+}
@@ -0,0 +1,112 @@
+package nacos
+
+import (
+	"net"
+	"night-fury/pkgs/config"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"github.com/pkg/errors"
+)
+
+// Config nacos 配置
+type Config struct {
+	Addrs       []string      `mapstructure:"addrs"` // host:port ，为空时不启用 nacos
+	ContextPath string        `mapstructure:"context_path"`
+	Namespace   string        `mapstructure:"namespace"`
+	Username    string        `mapstructure:"username"`
+	Password    config.Secret `mapstructure:"password"`
+
+	Timeout  time.Duration `mapstructure:"timeout"`
+	CacheDir string        `mapstructure:"cache_dir"`
+	LogDir   string        `mapstructure:"log_dir"`
+	LogLevel string        `mapstructure:"log_level" validate:"omitempty,oneof=debug info warn error"`
+
+	// 远程配置，DataID 为空时不从 nacos 读取配置
+	DataID string `mapstructure:"data_id"`
+	Group  string `mapstructure:"group"`
+	Format string `mapstructure:"format" validate:"omitempty,oneof=yaml yml toml json properties"`
+
+	// 服务注册，Service 为空时不注册
+	Service string `mapstructure:"service"`
+	Cluster string `mapstructure:"cluster"`
+	// 注册的 ip ，为空时使用本机第一个非回环的 ipv4 地址
+	IP string `mapstructure:"ip" validate:"omitempty,ip"`
+}
+
+// LoadConfig 读取 nacos 下的配置
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	if err := config.Unmarshal("nacos", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Enabled 是否配置了 nacos
+func (c *Config) Enabled() bool {
+	return len(c.Addrs) > 0
+}
+
+func (c *Config) setDefaults() {
+	if c.Timeout <= 0 {
+		c.Timeout = time.Second * 5
+	}
+	if c.CacheDir == "" {
+		c.CacheDir = filepath.Join(os.TempDir(), "nacos", "cache")
+	}
+	if c.LogDir == "" {
+		c.LogDir = filepath.Join(os.TempDir(), "nacos", "log")
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = "warn"
+	}
+	if c.Group == "" {
+		c.Group = constant.DEFAULT_GROUP
+	}
+	if c.Format == "" {
+		c.Format = "yaml"
+	}
+}
+
+// clientParam 转换为 sdk 的参数
+func (c *Config) clientParam() (vo.NacosClientParam, error) {
+	var servers []constant.ServerConfig
+	for _, addr := range c.Addrs {
+		host, port, err := net.SplitHostPort(strings.TrimPrefix(addr, "http://"))
+		if err != nil {
+			return vo.NacosClientParam{}, errors.Wrapf(err, "invalid nacos addr %s", addr)
+		}
+		p, err := strconv.ParseUint(port, 10, 64)
+		if err != nil {
+			return vo.NacosClientParam{}, errors.Wrapf(err, "invalid nacos addr %s", addr)
+		}
+		servers = append(servers, constant.ServerConfig{
+			Scheme:      "http",
+			IpAddr:      host,
+			Port:        p,
+			ContextPath: c.ContextPath,
+		})
+	}
+
+	return vo.NacosClientParam{
+		ClientConfig: &constant.ClientConfig{
+			TimeoutMs:           uint64(c.Timeout / time.Millisecond),
+			NamespaceId:         c.Namespace,
+			Username:            c.Username,
+			Password:            c.Password.Value(),
+			CacheDir:            c.CacheDir,
+			LogDir:              c.LogDir,
+			LogLevel:            c.LogLevel,
+			NotLoadCacheAtStart: true,
+			// 实例全部下线时也需要通知订阅方
+			UpdateCacheWhenEmpty: true,
+		},
+		ServerConfigs: servers,
+	}, nil
+}
@@ -0,0 +1,252 @@
+package nacos
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/model"
+)
+
+// 长轮询没有变化时的等待时间，真实的 nacos 为 30s
+const fakeHoldTimeout = time.Second
+
+// FakeServer 模拟 nacos 的 open api ，只实现 sdk 用到的配置和服务注册接口，用于测试
+type FakeServer struct {
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	configs   map[string]string                    // namespace/group/dataId -> content
+	services  map[string]map[string]model.Instance // namespace/group@@service -> ip:port -> instance
+	changed   chan struct{}                        // 配置变化时关闭，唤醒长轮询
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFakeServer 启动 FakeServer ，通过 Addr 获取地址
+func NewFakeServer() *FakeServer {
+	f := &FakeServer{
+		configs:  map[string]string{},
+		services: map[string]map[string]model.Instance{},
+		changed:  make(chan struct{}),
+		closing:  make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nacos/v1/auth/users/login", f.login)
+	mux.HandleFunc("/nacos"+constant.CONFIG_PATH, f.config)
+	mux.HandleFunc("/nacos"+constant.CONFIG_LISTEN_PATH, f.listen)
+	mux.HandleFunc("/nacos"+constant.SERVICE_PATH, f.instance)
+	mux.HandleFunc("/nacos"+constant.SERVICE_PATH+"/beat", f.beat)
+	mux.HandleFunc("/nacos"+constant.SERVICE_SUBSCRIBE_PATH, f.list)
+	f.srv = httptest.NewServer(mux)
+	return f
+}
+
+// Addr host:port
+func (f *FakeServer) Addr() string {
+	return strings.TrimPrefix(f.srv.URL, "http://")
+}
+
+// Close 关闭 FakeServer
+func (f *FakeServer) Close() {
+	f.closeOnce.Do(func() {
+		close(f.closing)
+		f.srv.Close()
+	})
+}
+
+// SetConfig 修改配置，会唤醒监听该配置的长轮询
+func (f *FakeServer) SetConfig(group, dataID, content string) {
+	f.setConfig("", group, dataID, content)
+}
+
+// Instances 获取服务在 group 下注册的实例
+func (f *FakeServer) Instances(group, service string) []*Instance {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var list []*Instance
+	for _, h := range f.services["/"+group+constant.SERVICE_INFO_SPLITER+service] {
+		list = append(list, toInstance(service, h.Ip, h.Port, h.Weight, h.Metadata))
+	}
+	return list
+}
+
+func (f *FakeServer) setConfig(namespace, group, dataID, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := namespace + "/" + group + "/" + dataID
+	if content == "" {
+		delete(f.configs, key)
+	} else {
+		f.configs[key] = content
+	}
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *FakeServer) login(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		constant.KEY_ACCESS_TOKEN: "fake-token",
+		constant.KEY_TOKEN_TTL:    18000,
+	})
+}
+
+func (f *FakeServer) config(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	namespace, group, dataID := r.Form.Get("tenant"), r.Form.Get("group"), r.Form.Get("dataId")
+
+	switch r.Method {
+	case http.MethodGet:
+		f.mu.Lock()
+		content, ok := f.configs[namespace+"/"+group+"/"+dataID]
+		f.mu.Unlock()
+		if !ok {
+			http.Error(w, "config data not exist", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(content))
+	case http.MethodPost:
+		f.setConfig(namespace, group, dataID, r.Form.Get("content"))
+		w.Write([]byte("true"))
+	case http.MethodDelete:
+		f.setConfig(namespace, group, dataID, "")
+		w.Write([]byte("true"))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// listen 长轮询，返回 md5 与客户端不一致的配置
+func (f *FakeServer) listen(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	listening := r.Form.Get(constant.KEY_LISTEN_CONFIGS)
+	noHangup := r.Header.Get("Long-Pulling-Timeout-No-Hangup") == "true"
+
+	timer := time.NewTimer(fakeHoldTimeout)
+	defer timer.Stop()
+	for {
+		f.mu.Lock()
+		changed, wait := f.changedConfigs(listening), f.changed
+		f.mu.Unlock()
+
+		if changed != "" || noHangup {
+			w.Write([]byte(changed))
+			return
+		}
+		select {
+		case <-wait:
+		case <-timer.C:
+			return
+		case <-f.closing:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (f *FakeServer) changedConfigs(listening string) string {
+	var changed strings.Builder
+	for _, item := range strings.Split(listening, constant.SPLIT_CONFIG) {
+		attrs := strings.Split(item, constant.SPLIT_CONFIG_INNER)
+		if len(attrs) < 3 {
+			continue
+		}
+		dataID, group, clientMd5, namespace := attrs[0], attrs[1], attrs[2], ""
+		if len(attrs) > 3 {
+			namespace = attrs[3]
+		}
+
+		content, ok := f.configs[namespace+"/"+group+"/"+dataID]
+		serverMd5 := ""
+		if ok {
+			sum := md5.Sum([]byte(content))
+			serverMd5 = hex.EncodeToString(sum[:])
+		}
+		if serverMd5 != clientMd5 {
+			// sdk 按 url 编码后的分隔符解析
+			changed.WriteString(dataID + "%02" + group + "%02" + namespace + "%01")
+		}
+	}
+	return changed.String()
+}
+
+func (f *FakeServer) instance(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	key := r.Form.Get("namespaceId") + "/" + r.Form.Get("serviceName")
+	addr := r.Form.Get("ip") + ":" + r.Form.Get("port")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPost:
+		port, _ := strconv.ParseUint(r.Form.Get("port"), 10, 64)
+		weight, _ := strconv.ParseFloat(r.Form.Get("weight"), 64)
+		var metadata map[string]string
+		json.Unmarshal([]byte(r.Form.Get("metadata")), &metadata)
+
+		if f.services[key] == nil {
+			f.services[key] = map[string]model.Instance{}
+		}
+		f.services[key][addr] = model.Instance{
+			InstanceId:  addr,
+			Ip:          r.Form.Get("ip"),
+			Port:        port,
+			Weight:      weight,
+			Metadata:    metadata,
+			ClusterName: r.Form.Get("clusterName"),
+			ServiceName: r.Form.Get("serviceName"),
+			Enable:      r.Form.Get("enable") == "true",
+			Healthy:     r.Form.Get("healthy") == "true",
+			Ephemeral:   r.Form.Get("ephemeral") == "true",
+		}
+		w.Write([]byte("ok"))
+	case http.MethodDelete:
+		delete(f.services[key], addr)
+		w.Write([]byte("ok"))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *FakeServer) beat(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{"clientBeatInterval": 5000})
+}
+
+func (f *FakeServer) list(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	service, clusters := r.Form.Get("serviceName"), r.Form.Get("clusters")
+
+	f.mu.Lock()
+	hosts := []model.Instance{}
+	for _, h := range f.services[r.Form.Get("namespaceId")+"/"+service] {
+		if clusters == "" || strings.Contains(","+clusters+",", ","+h.ClusterName+",") {
+			hosts = append(hosts, h)
+		}
+	}
+	f.mu.Unlock()
+
+	writeJSON(w, model.Service{
+		Name:        service,
+		Clusters:    clusters,
+		CacheMillis: 100,
+		Hosts:       hosts,
+		LastRefTime: uint64(time.Now().UnixNano() / 1e6),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
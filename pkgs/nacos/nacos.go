@@ -0,0 +1,113 @@
+package nacos
+
+// nacos 远程配置和服务注册
+//   conf, err := nacos.LoadConfig()
+//   nacos.Init(conf)
+//   config.AddSource(nacos.NewConfigSource(nacos.GetConfigClient(), conf.DataID, conf.Group, conf.Format))
+//   nacos.GetRegistry().Register(&nacos.Instance{Service: "night-fury", IP: ip, Port: 8080})
+//
+// 测试时可以使用 FakeServer
+
+import (
+	"night-fury/pkgs/log"
+	"sync"
+
+	"github.com/nacos-group/nacos-sdk-go/clients"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/common/logger"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNotInit = errors.New("nacos not init")
+	ErrNoAddrs = errors.New("nacos addrs not configured")
+
+	configClient config_client.IConfigClient
+	registry     *Registry
+	mu           sync.RWMutex
+)
+
+// Init 创建配置和服务注册的 client
+func Init(conf *Config) error {
+	if !conf.Enabled() {
+		return ErrNoAddrs
+	}
+	conf.setDefaults()
+
+	param, err := conf.clientParam()
+	if err != nil {
+		return err
+	}
+
+	cc, err := clients.NewConfigClient(param)
+	if err != nil {
+		return errors.Wrap(err, "create nacos config client")
+	}
+	nc, err := clients.NewNamingClient(param)
+	if err != nil {
+		return errors.Wrap(err, "create nacos naming client")
+	}
+	useLogger()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	configClient = cc
+	registry = NewRegistry(nc, conf.Group, conf.Cluster)
+	registry.ip = conf.IP
+	return nil
+}
+
+// GetConfigClient 获取配置 client ，未初始化时返回 nil
+func GetConfigClient() config_client.IConfigClient {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return configClient
+}
+
+// GetRegistry 获取服务注册，未初始化时返回 nil
+func GetRegistry() *Registry {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return registry
+}
+
+// Close 注销本进程注册的所有实例，作为 nacos 组件的 Stop hook
+func Close() error {
+	mu.Lock()
+	r := registry
+	configClient, registry = nil, nil
+	mu.Unlock()
+
+	if r == nil {
+		return nil
+	}
+	return r.DeregisterAll()
+}
+
+// NewNamingClient 创建服务发现的 client ，用于只需要发现服务的进程，如 ws_client
+func NewNamingClient(conf *Config) (naming_client.INamingClient, error) {
+	if !conf.Enabled() {
+		return nil, ErrNoAddrs
+	}
+	conf.setDefaults()
+
+	param, err := conf.clientParam()
+	if err != nil {
+		return nil, err
+	}
+	nc, err := clients.NewNamingClient(param)
+	if err != nil {
+		return nil, errors.Wrap(err, "create nacos naming client")
+	}
+	useLogger()
+	return nc, nil
+}
+
+// useLogger sdk 创建 client 时会重新初始化自己的 logger ，需要在创建之后替换
+func useLogger() {
+	logger.SetLogger(log.WithTag(log.TagNacos))
+}
@@ -0,0 +1,110 @@
+package nacos
+
+import (
+	"errors"
+	"night-fury/pkgs/config"
+	"testing"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"github.com/stretchr/testify/assert"
+)
+
+func initTestNacos(t *testing.T) *FakeServer {
+	f := NewFakeServer()
+	t.Cleanup(f.Close)
+
+	assert.Nil(t, Init(&Config{Addrs: []string{f.Addr()}, IP: "127.0.0.1"}))
+	return f
+}
+
+func TestConfigSource(t *testing.T) {
+	f := initTestNacos(t)
+	f.SetConfig("DEFAULT_GROUP", "night-fury.yaml", "nacos_test:\n  name: a\n")
+
+	s := NewConfigSource(GetConfigClient(), "night-fury.yaml", "DEFAULT_GROUP", "yaml")
+	assert.Nil(t, config.AddSource(s))
+	assert.Equal(t, "a", config.GetString("nacos_test.name"))
+
+	changed := make(chan string, 10)
+	config.OnChange("nacos_test.name", func() { changed <- config.GetString("nacos_test.name") })
+
+	f.SetConfig("DEFAULT_GROUP", "night-fury.yaml", "nacos_test:\n  name: b\n")
+	select {
+	case name := <-changed:
+		assert.Equal(t, "b", name)
+	case <-time.After(time.Second * 10):
+		t.Fatal("config change not received")
+	}
+
+	// 配置不存在时返回错误
+	assert.NotNil(t, config.AddSource(NewConfigSource(GetConfigClient(), "missing.yaml", "DEFAULT_GROUP", "yaml")))
+}
+
+func TestRegistry(t *testing.T) {
+	f := initTestNacos(t)
+	r := GetRegistry()
+
+	_, err := r.Pick("ws")
+	assert.ErrorIs(t, err, ErrNoInstance)
+
+	assert.Nil(t, r.Register(&Instance{Service: "ws", Port: 8080, Metadata: map[string]string{"ws_path": "/ws"}}))
+	assert.Len(t, f.Instances("DEFAULT_GROUP", "ws"), 1)
+
+	updates := make(chan []*Instance, 10)
+	assert.Nil(t, r.Subscribe("ws", func(list []*Instance) { updates <- list }))
+	waitInstances := func(n int) {
+		deadline := time.After(time.Second * 10)
+		for {
+			select {
+			case list := <-updates:
+				if len(list) == n {
+					return
+				}
+			case <-deadline:
+				t.Fatalf("instances not changed to %d", n)
+			}
+		}
+	}
+	waitInstances(1)
+
+	inst, err := r.Pick("ws")
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:8080", inst.Addr())
+	assert.Equal(t, "/ws", inst.Metadata["ws_path"])
+
+	assert.Nil(t, r.Register(&Instance{Service: "ws", Port: 8081}))
+	waitInstances(2)
+
+	// 退出时注销本进程注册的实例
+	assert.Nil(t, Close())
+	assert.Len(t, f.Instances("DEFAULT_GROUP", "ws"), 0)
+	waitInstances(0)
+}
+
+// flakyClient 前 fails 次注销失败
+type flakyClient struct {
+	naming_client.INamingClient
+	fails int
+}
+
+func (c *flakyClient) DeregisterInstance(param vo.DeregisterInstanceParam) (bool, error) {
+	if c.fails > 0 {
+		c.fails--
+		return false, errors.New("nacos unavailable")
+	}
+	return true, nil
+}
+
+func TestDeregisterFailed(t *testing.T) {
+	r := NewRegistry(&flakyClient{fails: 1}, "DEFAULT_GROUP", "")
+	inst := &Instance{Service: "ws", IP: "127.0.0.1", Port: 8080}
+	r.registered[inst.key()] = inst
+
+	// 失败时保留，重试成功后才删除
+	assert.NotNil(t, r.DeregisterAll())
+	assert.Len(t, r.registered, 1)
+	assert.Nil(t, r.DeregisterAll())
+	assert.Len(t, r.registered, 0)
+}
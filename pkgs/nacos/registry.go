@@ -0,0 +1,224 @@
+package nacos
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"github.com/pkg/errors"
+)
+
+var ErrNoInstance = errors.New("nacos: no healthy instance")
+
+// ws server 注册到 nacos 的实例 metadata ，ws_client 据此拼接连接地址
+const (
+	MetaWSScheme = "ws_scheme" // ws 或 wss
+	MetaWSPath   = "ws_path"
+)
+
+// Instance 服务实例
+type Instance struct {
+	Service  string
+	IP       string // 为空时使用 nacos.ip 配置或本机地址
+	Port     uint64
+	Weight   float64 // 默认 1
+	Metadata map[string]string
+}
+
+// Addr ip:port
+func (i *Instance) Addr() string {
+	return net.JoinHostPort(i.IP, fmt.Sprint(i.Port))
+}
+
+func (i *Instance) key() string {
+	return i.Service + "/" + i.Addr()
+}
+
+// Registry 服务注册和发现，注册的实例为临时实例，由 sdk 定时发送心跳
+type Registry struct {
+	client  naming_client.INamingClient
+	group   string
+	cluster string
+	ip      string
+
+	mu         sync.Mutex
+	registered map[string]*Instance
+}
+
+// NewRegistry 创建服务注册，group 为空时使用 DEFAULT_GROUP
+func NewRegistry(client naming_client.INamingClient, group, cluster string) *Registry {
+	return &Registry{
+		client:     client,
+		group:      group,
+		cluster:    cluster,
+		registered: map[string]*Instance{},
+	}
+}
+
+// Register 注册实例
+func (r *Registry) Register(inst *Instance) error {
+	if inst.IP == "" {
+		inst.IP = r.ip
+	}
+	if inst.IP == "" {
+		ip, err := localIP()
+		if err != nil {
+			return err
+		}
+		inst.IP = ip
+	}
+	if inst.Weight <= 0 {
+		inst.Weight = 1
+	}
+
+	ok, err := r.client.RegisterInstance(vo.RegisterInstanceParam{
+		Ip:          inst.IP,
+		Port:        inst.Port,
+		Weight:      inst.Weight,
+		Enable:      true,
+		Healthy:     true,
+		Metadata:    inst.Metadata,
+		ClusterName: r.cluster,
+		ServiceName: inst.Service,
+		GroupName:   r.group,
+		Ephemeral:   true,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "register %s", inst.key())
+	}
+	if !ok {
+		return errors.Errorf("register %s failed", inst.key())
+	}
+
+	r.mu.Lock()
+	r.registered[inst.key()] = inst
+	r.mu.Unlock()
+	return nil
+}
+
+// Deregister 注销实例，失败时保留注册记录，之后 DeregisterAll 会重试
+func (r *Registry) Deregister(inst *Instance) error {
+	ok, err := r.client.DeregisterInstance(vo.DeregisterInstanceParam{
+		Ip:          inst.IP,
+		Port:        inst.Port,
+		Cluster:     r.cluster,
+		ServiceName: inst.Service,
+		GroupName:   r.group,
+		Ephemeral:   true,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "deregister %s", inst.key())
+	}
+	if !ok {
+		return errors.Errorf("deregister %s failed", inst.key())
+	}
+
+	r.mu.Lock()
+	delete(r.registered, inst.key())
+	r.mu.Unlock()
+	return nil
+}
+
+// DeregisterAll 注销通过本 Registry 注册的所有实例
+func (r *Registry) DeregisterAll() error {
+	r.mu.Lock()
+	list := make([]*Instance, 0, len(r.registered))
+	for _, inst := range r.registered {
+		list = append(list, inst)
+	}
+	r.mu.Unlock()
+
+	var err error
+	for _, inst := range list {
+		if dErr := r.Deregister(inst); dErr != nil && err == nil {
+			err = dErr
+		}
+	}
+	return err
+}
+
+// Instances 获取服务的健康实例
+func (r *Registry) Instances(service string) ([]*Instance, error) {
+	hosts, err := r.client.SelectAllInstances(vo.SelectAllInstancesParam{
+		ServiceName: service,
+		GroupName:   r.group,
+		Clusters:    r.clusters(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "select instances of %s", service)
+	}
+
+	var list []*Instance
+	for _, h := range hosts {
+		if h.Healthy && h.Enable && h.Weight > 0 {
+			list = append(list, toInstance(service, h.Ip, h.Port, h.Weight, h.Metadata))
+		}
+	}
+	return list, nil
+}
+
+// Pick 按权重随机选择一个健康实例
+func (r *Registry) Pick(service string) (*Instance, error) {
+	h, err := r.client.SelectOneHealthyInstance(vo.SelectOneHealthInstanceParam{
+		ServiceName: service,
+		GroupName:   r.group,
+		Clusters:    r.clusters(),
+	})
+	if err != nil || h == nil {
+		return nil, errors.Wrapf(ErrNoInstance, "pick %s: %v", service, err)
+	}
+	return toInstance(service, h.Ip, h.Port, h.Weight, h.Metadata), nil
+}
+
+// Subscribe 订阅服务的实例变化，实例全部下线时 f 的参数为空
+func (r *Registry) Subscribe(service string, f func([]*Instance)) error {
+	err := r.client.Subscribe(&vo.SubscribeParam{
+		ServiceName: service,
+		GroupName:   r.group,
+		Clusters:    r.clusters(),
+		SubscribeCallback: func(services []model.SubscribeService, err error) {
+			var list []*Instance
+			for _, s := range services {
+				if s.Enable && s.Weight > 0 {
+					list = append(list, toInstance(service, s.Ip, s.Port, s.Weight, s.Metadata))
+				}
+			}
+			f(list)
+		},
+	})
+	return errors.Wrapf(err, "subscribe %s", service)
+}
+
+func (r *Registry) clusters() []string {
+	if r.cluster == "" {
+		return nil
+	}
+	return []string{r.cluster}
+}
+
+func toInstance(service, ip string, port uint64, weight float64, metadata map[string]string) *Instance {
+	return &Instance{
+		Service:  service,
+		IP:       ip,
+		Port:     port,
+		Weight:   weight,
+		Metadata: metadata,
+	}
+}
+
+// localIP 本机第一个非回环的 ipv4 地址
+func localIP() (string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", errors.Wrap(err, "get local ip")
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP.String(), nil
+		}
+	}
+	return "", errors.New("no local ipv4 address")
+}
@@ -0,0 +1,83 @@
+package nacos
+
+import (
+	"sync"
+
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"github.com/pkg/errors"
+)
+
+// ConfigSource 从 nacos 读取配置，实现 config.Source
+type ConfigSource struct {
+	client config_client.IConfigClient
+	dataID string
+	group  string
+	format string
+
+	mu      sync.RWMutex
+	content *string // 监听到的最新配置，为空时从 nacos 读取
+}
+
+// NewConfigSource 创建配置源，format 为 yaml / toml / json
+func NewConfigSource(client config_client.IConfigClient, dataID, group, format string) *ConfigSource {
+	return &ConfigSource{
+		client: client,
+		dataID: dataID,
+		group:  group,
+		format: format,
+	}
+}
+
+func (s *ConfigSource) Name() string {
+	return "nacos:" + s.group + "/" + s.dataID
+}
+
+func (s *ConfigSource) Format() string {
+	return s.format
+}
+
+func (s *ConfigSource) Read() ([]byte, error) {
+	s.mu.RLock()
+	content := s.content
+	s.mu.RUnlock()
+	if content != nil {
+		return []byte(*content), nil
+	}
+
+	data, err := s.client.GetConfig(vo.ConfigParam{DataId: s.dataID, Group: s.group})
+	if err != nil {
+		return nil, errors.Wrapf(err, "get nacos config %s", s.Name())
+	}
+	s.setContent(data)
+	return []byte(data), nil
+}
+
+// Watch 通过 nacos 的长轮询监听配置变化
+func (s *ConfigSource) Watch(onChange func()) error {
+	return s.client.ListenConfig(vo.ConfigParam{
+		DataId: s.dataID,
+		Group:  s.group,
+		OnChange: func(namespace, group, dataId, data string) {
+			s.mu.RLock()
+			same := s.content != nil && *s.content == data
+			s.mu.RUnlock()
+			if same {
+				return
+			}
+			s.setContent(data)
+			onChange()
+		},
+	})
+}
+
+// Close 停止监听
+func (s *ConfigSource) Close() error {
+	return s.client.CancelListenConfig(vo.ConfigParam{DataId: s.dataID, Group: s.group})
+}
+
+func (s *ConfigSource) setContent(data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.content = &data
+}
@@ -0,0 +1,105 @@
+package outbox
+
+// 事务 outbox ，保证业务数据和事件同时提交
+//   err := db.WithTx(ctx, func(ctx context.Context) error {
+//       if err := db.Users.Create(ctx, u); err != nil {
+//           return err
+//       }
+//       e, err := outbox.NewEvent("user_events", u.ID, u) // 相同 key 的事件按顺序发送
+//       if err != nil {
+//           return err
+//       }
+//       return outbox.Add(ctx, e)
+//   })
+// Relay 在后台把待发送的事件通过 Publisher 发送出去，失败时重试，超过次数后进入死信状态
+
+import (
+	"context"
+	"night-fury/pkgs/db"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusDead    = "dead"
+)
+
+var ErrNotInTx = errors.New("outbox event must be added in transaction")
+
+func init() {
+	// outbox 表的变更不需要审计
+	db.SkipAudit(Event{}.TableName())
+}
+
+// Event 待发送的事件
+type Event struct {
+	ID            int64      `gorm:"primarykey;autoIncrement" json:"id"`
+	TenantID      string     `gorm:"type:varchar(64);index" json:"tenantID"`
+	Topic         string     `gorm:"type:varchar(200)" json:"topic"`
+	AggregateKey  string     `gorm:"type:varchar(200);index:idx_outbox_key" json:"aggregateKey"` // 相同 key 的事件按顺序发送，为空时不保证顺序
+	Payload       []byte     `json:"payload"`
+	Headers       string     `gorm:"type:text" json:"headers"` // json 格式的 map[string]string
+	Status        string     `gorm:"type:varchar(20);index:idx_outbox_status;index:idx_outbox_key" json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `gorm:"type:text" json:"lastError"`
+	NextAttemptAt time.Time  `gorm:"index:idx_outbox_status" json:"nextAttemptAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	SentAt        *time.Time `gorm:"index" json:"sentAt"`
+}
+
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// HeaderMap 解析 Headers
+func (e *Event) HeaderMap() map[string]string {
+	if e.Headers == "" {
+		return nil
+	}
+	m := map[string]string{}
+	_ = jsoniter.UnmarshalFromString(e.Headers, &m)
+	return m
+}
+
+// NewEvent 创建 json 格式的事件
+func NewEvent(topic, key string, v interface{}) (*Event, error) {
+	payload, err := jsoniter.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal outbox event")
+	}
+	return &Event{Topic: topic, AggregateKey: key, Payload: payload}, nil
+}
+
+// WithHeaders 设置事件的 header
+func (e *Event) WithHeaders(headers map[string]string) *Event {
+	e.Headers, _ = jsoniter.MarshalToString(headers)
+	return e
+}
+
+// Add 写入事件，需要在 db.WithTx 中调用，与业务数据在同一个事务中提交
+func Add(ctx context.Context, events ...*Event) error {
+	if !db.InTx(ctx) {
+		return ErrNotInTx
+	}
+	return AddTx(db.Conn(ctx), events...)
+}
+
+// AddTx 在 db.InitTransaction 获取的事务中写入事件，tx 需要带有租户信息的 context (tx.WithContext(ctx))
+func AddTx(tx *gorm.DB, events ...*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, e := range events {
+		e.ID = 0
+		e.Status = StatusPending
+		e.Attempts = 0
+		e.NextAttemptAt = now
+	}
+	return tx.Create(&events).Error
+}
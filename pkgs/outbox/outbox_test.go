@@ -0,0 +1,105 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"night-fury/pkgs/db"
+	"night-fury/pkgs/tenant"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelay(t *testing.T) {
+	assert.Nil(t, db.Init(context.Background(), &db.Config{
+		Driver: db.DriverSQLite,
+		DSN:    fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+	}))
+	_, err := db.MigrateUp(context.Background(), 0)
+	assert.Nil(t, err)
+	ctx := tenant.WithID(context.Background(), "tenant1")
+
+	// 不在事务中时拒绝写入
+	e, _ := NewEvent("user", "u1", map[string]int{"v": 0})
+	assert.ErrorIs(t, Add(ctx, e), ErrNotInTx)
+
+	// 事务回滚时事件也回滚
+	assert.NotNil(t, db.WithTx(ctx, func(ctx context.Context) error {
+		e, _ := NewEvent("user", "u1", map[string]int{"v": -1})
+		assert.Nil(t, Add(ctx, e))
+		return errors.New("rollback")
+	}))
+
+	assert.Nil(t, db.WithTx(ctx, func(ctx context.Context) error {
+		for i := 1; i <= 3; i++ {
+			for _, key := range []string{"u1", "u2"} {
+				e, _ := NewEvent("user", key, map[string]int{"v": i})
+				if err := Add(ctx, e); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+
+	var published []string
+	failU1 := 1
+	relay := NewRelay(PublisherFunc(func(ctx context.Context, e *Event) error {
+		if e.AggregateKey == "u1" && failU1 > 0 {
+			failU1--
+			return errors.New("broker down")
+		}
+		assert.Equal(t, "tenant1", e.TenantID)
+		published = append(published, fmt.Sprintf("%s:%s:%s", e.Topic, e.AggregateKey, e.Payload))
+		return nil
+	}), &RelayConfig{MaxAttempts: 2, RetryBackoff: time.Millisecond * 20, DeadLetterTopic: "dead"})
+
+	// u1 第一个事件失败，后续的 u1 事件等待，u2 不受影响
+	n, err := relay.RunOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, []string{`user:u2:{"v":1}`, `user:u2:{"v":2}`, `user:u2:{"v":3}`}, published)
+
+	// 未到重试时间
+	n, err = relay.RunOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	time.Sleep(time.Millisecond * 30)
+	published = nil
+	_, err = relay.RunOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`user:u1:{"v":1}`, `user:u1:{"v":2}`, `user:u1:{"v":3}`}, published)
+
+	// 一直失败时进入死信
+	assert.Nil(t, db.WithTx(ctx, func(ctx context.Context) error {
+		e, _ := NewEvent("user", "u3", nil)
+		return Add(ctx, e)
+	}))
+	failU1 = 0
+	relay.publisher = PublisherFunc(func(ctx context.Context, e *Event) error {
+		if e.Topic == "dead" {
+			published = append(published, "dead:"+e.AggregateKey)
+			return nil
+		}
+		return errors.New("broker down")
+	})
+	published = nil
+	_, _ = relay.RunOnce(context.Background())
+	time.Sleep(time.Millisecond * 30)
+	_, _ = relay.RunOnce(context.Background())
+	assert.Equal(t, []string{"dead:u3"}, published)
+
+	var dead []Event
+	assert.Nil(t, db.Conn(ctx).Where("status = ?", StatusDead).Find(&dead).Error)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, 2, dead[0].Attempts)
+
+	// 清理已发送的事件
+	relay.conf.Retention = -time.Second
+	deleted, err := relay.Cleanup(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), deleted)
+}
@@ -0,0 +1,43 @@
+package outbox
+
+import (
+	"context"
+	"night-fury/pkgs/kafka"
+)
+
+// Publisher 发送事件，返回 nil 表示发送成功
+type Publisher interface {
+	Publish(ctx context.Context, e *Event) error
+}
+
+// PublisherFunc 函数形式的 Publisher
+type PublisherFunc func(ctx context.Context, e *Event) error
+
+func (f PublisherFunc) Publish(ctx context.Context, e *Event) error {
+	return f(ctx, e)
+}
+
+// KafkaPublisher 发送到 kafka ， AggregateKey 作为消息的 key
+type KafkaPublisher struct {
+	producer *kafka.Producer
+}
+
+func NewKafkaPublisher(p *kafka.Producer) *KafkaPublisher {
+	return &KafkaPublisher{producer: p}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, e *Event) error {
+	headers := e.HeaderMap()
+	if e.TenantID != "" {
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers[kafka.HeaderTenantID] = e.TenantID
+	}
+	return p.producer.Send(ctx, kafka.Message{
+		Topic:   e.Topic,
+		Key:     []byte(e.AggregateKey),
+		Value:   e.Payload,
+		Headers: headers,
+	})
+}
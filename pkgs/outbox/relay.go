@@ -0,0 +1,291 @@
+package outbox
+
+import (
+	"context"
+	"night-fury/pkgs/config"
+	"night-fury/pkgs/db"
+	"night-fury/pkgs/log"
+	"night-fury/pkgs/tenant"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RelayConfig relay 配置
+type RelayConfig struct {
+	BatchSize    int           `mapstructure:"batch_size" validate:"gte=0"`   // 每次处理的事件数量
+	Interval     time.Duration `mapstructure:"interval"`                      // 没有事件时的轮询间隔
+	MaxAttempts  int           `mapstructure:"max_attempts" validate:"gte=0"` // 超过后进入死信状态
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`                 // 重试间隔，按次数指数增长
+
+	// DeadLetterTopic 进入死信状态时额外发送到该 topic ，为空时只标记状态
+	DeadLetterTopic string `mapstructure:"dead_letter_topic"`
+
+	// 已发送事件的保留时间，死信事件不会被清理
+	Retention       time.Duration `mapstructure:"retention"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+}
+
+// LoadRelayConfig 读取 outbox 下的配置
+func LoadRelayConfig() (*RelayConfig, error) {
+	c := &RelayConfig{}
+	if err := config.Unmarshal("outbox", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *RelayConfig) setDefaults() {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 10
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = time.Second
+	}
+	if c.Retention <= 0 {
+		c.Retention = time.Hour * 24 * 7
+	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = time.Hour
+	}
+}
+
+// maxBackoff 重试间隔的上限
+const maxBackoff = time.Minute * 10
+
+// Relay 发送 outbox 中的事件
+// 多个实例同时运行时，通过 FOR UPDATE SKIP LOCKED 分配事件，相同 key 的事件只会由持有最早事件的实例按顺序发送
+type Relay struct {
+	publisher Publisher
+	conf      *RelayConfig
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRelay 创建 relay
+func NewRelay(p Publisher, conf *RelayConfig) *Relay {
+	conf.setDefaults()
+	return &Relay{publisher: p, conf: conf}
+}
+
+// Start 在后台发送事件和清理已发送的事件
+func (r *Relay) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cancel != nil {
+		return
+	}
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	r.wg.Add(2)
+	go r.loop(ctx, r.conf.Interval, func(ctx context.Context) bool {
+		n, err := r.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Errorf(log.TagDB, "outbox relay error : %s", err)
+		}
+		// 一批处理满时立即处理下一批
+		return err == nil && n >= r.conf.BatchSize
+	})
+	go r.loop(ctx, r.conf.CleanupInterval, func(ctx context.Context) bool {
+		if _, err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+			log.Errorf(log.TagDB, "outbox cleanup error : %s", err)
+		}
+		return false
+	})
+}
+
+// loop 周期执行 f ， f 返回 true 时立即再次执行
+func (r *Relay) loop(ctx context.Context, interval time.Duration, f func(ctx context.Context) bool) {
+	defer r.wg.Done()
+
+	t := time.NewTimer(0)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if f(ctx) {
+			t.Reset(0)
+		} else {
+			t.Reset(interval)
+		}
+	}
+}
+
+// Stop 停止 relay 并等待正在发送的事件完成
+func (r *Relay) Stop() error {
+	r.mu.Lock()
+	cancel := r.cancel
+	r.cancel = nil
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		r.wg.Wait()
+	}
+	return nil
+}
+
+// RunOnce 处理一批到期的事件，返回处理的事件数量
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	var n int
+	err := db.WithTx(relayContext(ctx), func(ctx context.Context) error {
+		tx := db.Conn(ctx)
+
+		q := tx.Where("status = ? AND next_attempt_at <= ?", StatusPending, time.Now()).
+			Order("id").Limit(r.conf.BatchSize)
+		if db.Dialect() != db.DriverSQLite {
+			q = q.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		var events []*Event
+		if err := q.Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		heads, err := headIDs(tx, events)
+		if err != nil {
+			return err
+		}
+
+		blocked := map[string]bool{}
+		seen := map[string]bool{}
+		for _, e := range events {
+			if e.AggregateKey != "" {
+				// 同一个 key 只有最早的待发送事件可以发送，前面的事件失败或被其他实例处理时等待
+				if blocked[e.AggregateKey] || (!seen[e.AggregateKey] && heads[e.AggregateKey] != e.ID) {
+					blocked[e.AggregateKey] = true
+					continue
+				}
+				seen[e.AggregateKey] = true
+			}
+
+			ok, err := r.publish(ctx, tx, e)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				blocked[e.AggregateKey] = true
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// relayContext relay 需要处理所有租户的事件，并且读写都在主库
+func relayContext(ctx context.Context) context.Context {
+	return tenant.WithSystem(db.WithPrimary(ctx))
+}
+
+// headIDs 每个 key 最早的待发送事件 id ，包括未到重试时间的事件
+func headIDs(tx *gorm.DB, events []*Event) (map[string]int64, error) {
+	var keys []string
+	for _, e := range events {
+		if e.AggregateKey != "" {
+			keys = append(keys, e.AggregateKey)
+		}
+	}
+	heads := map[string]int64{}
+	if len(keys) == 0 {
+		return heads, nil
+	}
+
+	var rows []struct {
+		AggregateKey string
+		ID           int64
+	}
+	err := tx.Model(&Event{}).Select("aggregate_key, MIN(id) AS id").
+		Where("status = ? AND aggregate_key IN ?", StatusPending, keys).
+		Group("aggregate_key").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		heads[row.AggregateKey] = row.ID
+	}
+	return heads, nil
+}
+
+// publish 发送事件并更新状态，返回事件是否已经处理完(发送成功或进入死信)
+func (r *Relay) publish(ctx context.Context, tx *gorm.DB, e *Event) (bool, error) {
+	now := time.Now()
+	pubErr := r.publisher.Publish(ctx, e)
+	if pubErr == nil {
+		err := tx.Model(e).Updates(map[string]interface{}{
+			"status":   StatusSent,
+			"sent_at":  now,
+			"attempts": e.Attempts + 1,
+		}).Error
+		return true, err
+	}
+
+	e.Attempts++
+	updates := map[string]interface{}{
+		"attempts":   e.Attempts,
+		"last_error": pubErr.Error(),
+	}
+	done := e.Attempts >= r.conf.MaxAttempts
+	if done {
+		log.Errorf(log.TagDB, "outbox event %d to %s dead after %d attempts : %s", e.ID, e.Topic, e.Attempts, pubErr)
+		updates["status"] = StatusDead
+		if r.conf.DeadLetterTopic != "" {
+			dead := *e
+			dead.Topic = r.conf.DeadLetterTopic
+			if err := r.publisher.Publish(ctx, &dead); err != nil {
+				log.Errorf(log.TagDB, "outbox event %d publish to dead letter topic error : %s", e.ID, err)
+			}
+		}
+	} else {
+		log.Warnf(log.TagDB, "outbox event %d to %s publish error, attempt %d : %s", e.ID, e.Topic, e.Attempts, pubErr)
+		updates["next_attempt_at"] = now.Add(backoff(r.conf.RetryBackoff, e.Attempts))
+	}
+	return done, tx.Model(e).Updates(updates).Error
+}
+
+// backoff 指数增长的重试间隔
+func backoff(base time.Duration, attempts int) time.Duration {
+	d := base
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// Cleanup 删除超过保留时间的已发送事件
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	res := db.Conn(relayContext(ctx)).
+		Where("status = ? AND sent_at < ?", StatusSent, time.Now().Add(-r.conf.Retention)).
+		Delete(&Event{})
+	return res.RowsAffected, res.Error
+}
+
+// Retry 将死信事件重新设置为待发送
+func Retry(ctx context.Context, ids ...int64) error {
+	return db.Conn(ctx).Model(&Event{}).
+		Where("status = ? AND id IN ?", StatusDead, ids).
+		Updates(map[string]interface{}{
+			"status":          StatusPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		}).Error
+}
@@ -0,0 +1,248 @@
+package redis
+
+// 带类型的缓存
+//   var userCache = redis.NewCache[db.User](redis.CacheConfig{
+//       Name: "user", TTL: time.Minute * 10,
+//       IsNotFound: func(err error) bool { return errors.Is(err, db.Nil) }, // db.Nil 会被缓存为空值
+//   })
+//
+//   u, err := userCache.GetOrLoad(ctx, id, func(ctx context.Context) (*db.User, error) {
+//       return db.Users.Get(ctx, id)
+//   }, "users")
+//
+//   redis.InvalidateTags(ctx, "users") // 数据变更后按 tag 清除缓存
+//
+// key 会带上 context 中的租户 id ，不同租户的缓存互相隔离
+// 同一个 key 并发回源时只会加载一次 (singleflight)
+
+import (
+	"context"
+	"math/rand"
+	"night-fury/pkgs/log"
+	"night-fury/pkgs/tenant"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	valueFlag    = '1' // 缓存的值
+	negativeFlag = '0' // 缓存的空值
+)
+
+var (
+	ErrCacheMiss = errors.New("cache miss")
+	ErrNotFound  = errors.New("not found")
+)
+
+// CacheConfig 缓存配置
+type CacheConfig struct {
+	Name   string        // 缓存名，作为 key 的一部分
+	TTL    time.Duration // 过期时间
+	Jitter float64       // 过期时间的随机浮动比例，例如 0.1 表示 ±10% ，避免同时过期
+
+	// NegativeTTL 空值的缓存时间，<= 0 时不缓存空值
+	NegativeTTL time.Duration
+	// IsNotFound 判断回源的错误是否表示数据不存在，默认只有 ErrNotFound
+	IsNotFound func(err error) bool
+}
+
+// Cache 带类型的缓存，值以 json 存储
+type Cache[T any] struct {
+	conf  CacheConfig
+	group singleflight.Group
+}
+
+// NewCache 创建缓存，可以在包初始化时创建，使用时才获取全局客户端
+func NewCache[T any](conf CacheConfig) *Cache[T] {
+	if conf.TTL <= 0 {
+		conf.TTL = time.Minute * 10
+	}
+	if conf.IsNotFound == nil {
+		conf.IsNotFound = func(err error) bool {
+			return errors.Is(err, ErrNotFound)
+		}
+	}
+	return &Cache[T]{conf: conf}
+}
+
+// key 带有租户 id 的完整 key
+func (c *Cache[T]) key(ctx context.Context, key string) string {
+	tid, _ := tenant.FromContext(ctx)
+	return Key("cache", c.conf.Name, tid, key)
+}
+
+// tagKey 带有租户 id 的 tag key
+func tagKey(ctx context.Context, tag string) string {
+	tid, _ := tenant.FromContext(ctx)
+	return Key("tag", tid, tag)
+}
+
+// Get 获取缓存，不存在时返回 ErrCacheMiss ，缓存的空值返回 ErrNotFound
+func (c *Cache[T]) Get(ctx context.Context, key string) (*T, error) {
+	cli := GetClient()
+	if cli == nil {
+		return nil, ErrNotInit
+	}
+
+	b, err := cli.Get(ctx, c.key(ctx, key)).Bytes()
+	if err == Nil {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(b)
+}
+
+func (c *Cache[T]) decode(b []byte) (*T, error) {
+	if len(b) == 0 {
+		return nil, ErrCacheMiss
+	}
+	if b[0] == negativeFlag {
+		return nil, ErrNotFound
+	}
+	v := new(T)
+	if err := jsoniter.Unmarshal(b[1:], v); err != nil {
+		return nil, errors.Wrap(err, "decode cache")
+	}
+	return v, nil
+}
+
+// Set 设置缓存，并关联到 tags
+func (c *Cache[T]) Set(ctx context.Context, key string, v *T, tags ...string) error {
+	b, err := jsoniter.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "encode cache")
+	}
+	return c.set(ctx, key, append([]byte{valueFlag}, b...), c.ttl(c.conf.TTL), tags)
+}
+
+// setNegative 缓存空值
+func (c *Cache[T]) setNegative(ctx context.Context, key string, tags []string) error {
+	return c.set(ctx, key, []byte{negativeFlag}, c.ttl(c.conf.NegativeTTL), tags)
+}
+
+func (c *Cache[T]) set(ctx context.Context, key string, b []byte, ttl time.Duration, tags []string) error {
+	cli := GetClient()
+	if cli == nil {
+		return ErrNotInit
+	}
+
+	// 缓存和 tag 的 key 可能不在同一个 slot ，redis cluster 中不能使用事务
+	k := c.key(ctx, key)
+	pipe := cli.Pipeline()
+	pipe.Set(ctx, k, b, ttl)
+	for _, tag := range tags {
+		tk := tagKey(ctx, tag)
+		pipe.SAdd(ctx, tk, k)
+		// tag 比缓存多保留一段时间，保证能清除到所有关联的缓存
+		pipe.Expire(ctx, tk, c.maxTTL()*2)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// maxTTL 缓存最长的过期时间
+func (c *Cache[T]) maxTTL() time.Duration {
+	d := time.Duration(float64(c.conf.TTL) * (1 + c.conf.Jitter))
+	if c.conf.NegativeTTL > d {
+		d = c.conf.NegativeTTL
+	}
+	return d
+}
+
+// ttl 加上随机浮动的过期时间
+func (c *Cache[T]) ttl(base time.Duration) time.Duration {
+	if c.conf.Jitter <= 0 || base <= 0 {
+		return base
+	}
+	delta := time.Duration(float64(base) * c.conf.Jitter * (rand.Float64()*2 - 1))
+	return base + delta
+}
+
+// GetOrLoad 获取缓存，不存在时通过 load 回源并写入缓存
+// load 返回不存在的错误时(见 CacheConfig.IsNotFound)，缓存空值并返回 ErrNotFound
+// redis 不可用时直接回源
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) (*T, error), tags ...string) (*T, error) {
+	v, err := c.Get(ctx, key)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return v, err
+	}
+	if !errors.Is(err, ErrCacheMiss) && !errors.Is(err, ErrNotInit) {
+		log.Warnf(log.TagRedis, "get cache %s error : %s", key, err)
+	}
+
+	res, err, _ := c.group.Do(c.key(ctx, key), func() (interface{}, error) {
+		v, err := load(ctx)
+		if err != nil {
+			if c.conf.IsNotFound(err) {
+				if c.conf.NegativeTTL > 0 {
+					if err := c.setNegative(ctx, key, tags); err != nil && !errors.Is(err, ErrNotInit) {
+						log.Warnf(log.TagRedis, "set negative cache %s error : %s", key, err)
+					}
+				}
+				return nil, ErrNotFound
+			}
+			return nil, err
+		}
+		if err := c.Set(ctx, key, v, tags...); err != nil && !errors.Is(err, ErrNotInit) {
+			log.Warnf(log.TagRedis, "set cache %s error : %s", key, err)
+		}
+		return v, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*T), nil
+}
+
+// Delete 删除缓存
+func (c *Cache[T]) Delete(ctx context.Context, keys ...string) error {
+	cli := GetClient()
+	if cli == nil {
+		return ErrNotInit
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	list := make([]string, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, c.key(ctx, k))
+	}
+	return del(ctx, cli, list)
+}
+
+// InvalidateTags 删除当前租户下与 tags 关联的所有缓存
+func InvalidateTags(ctx context.Context, tags ...string) error {
+	cli := GetClient()
+	if cli == nil {
+		return ErrNotInit
+	}
+
+	for _, tag := range tags {
+		tk := tagKey(ctx, tag)
+		keys, err := cli.SMembers(ctx, tk).Result()
+		if err != nil {
+			return err
+		}
+		if err = del(ctx, cli, append(keys, tk)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// del 逐个删除 key ，redis cluster 中一次 DEL 多个 key 需要在同一个 slot
+func del(ctx context.Context, cli *goredis.Client, keys []string) error {
+	pipe := cli.Pipeline()
+	for _, k := range keys {
+		pipe.Del(ctx, k)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
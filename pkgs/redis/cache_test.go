@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"night-fury/pkgs/tenant"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+var errNoRow = errors.New("no row")
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func TestCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	SetClient(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}), "test:")
+	defer Close()
+
+	ctx1 := tenant.WithID(context.Background(), "tenant1")
+	ctx2 := tenant.WithID(context.Background(), "tenant2")
+	cache := NewCache[item](CacheConfig{
+		Name: "item", TTL: time.Minute, Jitter: 0.1, NegativeTTL: time.Second * 10,
+		IsNotFound: func(err error) bool { return errors.Is(err, errNoRow) },
+	})
+
+	// 并发回源只加载一次
+	var loads int32
+	load := func(ctx context.Context) (*item, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(time.Millisecond * 20)
+		return &item{Name: "a"}, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrLoad(ctx1, "a", load, "items")
+			assert.Nil(t, err)
+			assert.Equal(t, "a", v.Name)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), loads)
+
+	ttl := mr.TTL("test:cache:item:tenant1:a")
+	assert.True(t, ttl >= time.Second*54 && ttl <= time.Second*66)
+
+	// 不同租户互相隔离
+	_, err := cache.Get(ctx2, "a")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	// 空值缓存
+	_, err = cache.GetOrLoad(ctx1, "none", func(ctx context.Context) (*item, error) {
+		atomic.AddInt32(&loads, 1)
+		return nil, errNoRow
+	}, "items")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = cache.GetOrLoad(ctx1, "none", load)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, int32(2), loads)
+
+	// 默认只有 ErrNotFound 表示不存在，其他错误不缓存
+	plain := NewCache[item](CacheConfig{Name: "plain", NegativeTTL: time.Second * 10})
+	_, err = plain.GetOrLoad(ctx1, "none", func(ctx context.Context) (*item, error) { return nil, errNoRow })
+	assert.ErrorIs(t, err, errNoRow)
+	_, err = plain.GetOrLoad(ctx1, "none", func(ctx context.Context) (*item, error) { return nil, ErrNotFound })
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = plain.Get(ctx1, "none")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// 按 tag 清除
+	assert.Nil(t, InvalidateTags(ctx1, "items"))
+	_, err = cache.Get(ctx1, "a")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	_, err = cache.Get(ctx1, "none")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	// redis 不可用时直接回源
+	mr.Close()
+	v, err := cache.GetOrLoad(ctx1, "a", load)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", v.Name)
+}
@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"night-fury/pkgs/config"
+	"time"
+)
+
+// Config redis 配置
+type Config struct {
+	Addr     string        `mapstructure:"addr"`
+	Password config.Secret `mapstructure:"password"`
+	DB       int           `mapstructure:"db" validate:"gte=0"`
+
+	// KeyPrefix 所有缓存 key 的前缀，多个服务共用 redis 时用于隔离
+	KeyPrefix string `mapstructure:"key_prefix"`
+
+	PoolSize     int           `mapstructure:"pool_size" validate:"gte=0"`
+	MinIdleConns int           `mapstructure:"min_idle_conns" validate:"gte=0"`
+	DialTimeout  time.Duration `mapstructure:"dial_timeout"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+}
+
+// LoadConfig 读取 redis 下的配置
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	if err := config.Unmarshal("redis", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Enabled 是否配置了 redis
+func (c *Config) Enabled() bool {
+	return c.Addr != ""
+}
+
+func (c *Config) setDefaults() {
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = "night-fury:"
+	}
+	if c.PoolSize <= 0 {
+		c.PoolSize = 50
+	}
+	if c.MinIdleConns <= 0 {
+		c.MinIdleConns = 5
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = time.Second * 5
+	}
+	if c.ReadTimeout <= 0 {
+		c.ReadTimeout = time.Second * 3
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = time.Second * 3
+	}
+}
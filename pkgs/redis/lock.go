@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"context"
+	"night-fury/pkgs/lock"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// 获取锁成功时递增 fencing token 并返回，失败时返回 0
+	lockScript = goredis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+	refreshScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+	releaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+)
+
+// Locker 基于 redis 的分布式锁，使用全局客户端
+type Locker struct{}
+
+// NewLocker 创建基于 redis 的 lock.Locker
+func NewLocker() *Locker {
+	return &Locker{}
+}
+
+func (l *Locker) TryLock(ctx context.Context, key string, ttl time.Duration) (lock.Lease, error) {
+	cli := GetClient()
+	if cli == nil {
+		return nil, ErrNotInit
+	}
+
+	lockKey, fenceKey := lockKeys(key)
+	lease := &redisLease{
+		key:   lockKey,
+		value: uuid.NewV4().String(), // 只有持有者才能续期和释放
+	}
+	token, err := lockScript.Run(ctx, cli, []string{lockKey, fenceKey}, lease.value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if token == 0 {
+		return nil, lock.ErrNotAcquired
+	}
+	lease.token = token
+	return lease, nil
+}
+
+// lockKeys 锁和 fencing token 的 key ，使用相同的 hash tag ，保证在 redis cluster 中位于同一个 slot
+func lockKeys(key string) (lockKey, fenceKey string) {
+	tag := "{" + key + "}"
+	return Key(tag, "lock"), Key(tag, "fence")
+}
+
+type redisLease struct {
+	key   string
+	value string
+	token int64
+}
+
+func (l *redisLease) Token() int64 {
+	return l.token
+}
+
+func (l *redisLease) Refresh(ctx context.Context, ttl time.Duration) error {
+	cli := GetClient()
+	if cli == nil {
+		return ErrNotInit
+	}
+	ok, err := refreshScript.Run(ctx, cli, []string{l.key}, l.value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return lock.ErrLockLost
+	}
+	return nil
+}
+
+func (l *redisLease) Release(ctx context.Context) error {
+	cli := GetClient()
+	if cli == nil {
+		return ErrNotInit
+	}
+	ok, err := releaseScript.Run(ctx, cli, []string{l.key}, l.value).Int64()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return lock.ErrLockLost
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"context"
+	"night-fury/pkgs/lock"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocker(t *testing.T) {
+	mr := miniredis.RunT(t)
+	SetClient(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}), "test:")
+	defer Close()
+
+	ctx := context.Background()
+	l := NewLocker()
+
+	l1, err := l.TryLock(ctx, "job", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), l1.Token())
+	// 锁和 fencing token 使用相同的 hash tag
+	assert.True(t, mr.Exists("test:{job}:lock"))
+	fence, err := mr.Get("test:{job}:fence")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", fence)
+	_, err = l.TryLock(ctx, "job", time.Second)
+	assert.ErrorIs(t, err, lock.ErrNotAcquired)
+
+	assert.Nil(t, l1.Refresh(ctx, time.Second))
+	assert.Nil(t, l1.Release(ctx))
+
+	l2, err := l.TryLock(ctx, "job", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), l2.Token())
+
+	// 过期后不能续期和释放
+	mr.FastForward(time.Second * 2)
+	assert.ErrorIs(t, l2.Refresh(ctx, time.Second), lock.ErrLockLost)
+	assert.ErrorIs(t, l2.Release(ctx), lock.ErrLockLost)
+}
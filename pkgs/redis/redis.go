@@ -1 +1,110 @@
 package redis
+
+// redis 客户端，基于 go-redis v8
+// 启动时需要显式调用 Init ，未初始化时缓存会直接回源(见 Cache.GetOrLoad)
+
+import (
+	"context"
+	"sync"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// Nil redis 中 key 不存在
+const Nil = goredis.Nil
+
+var (
+	ErrNotInit = errors.New("redis not init")
+
+	client    *goredis.Client
+	keyPrefix = "night-fury:"
+	mu        sync.RWMutex
+)
+
+// Open 根据配置创建一个新的客户端，并检查连接是否可用
+func Open(ctx context.Context, conf *Config) (*goredis.Client, error) {
+	conf.setDefaults()
+
+	c := goredis.NewClient(&goredis.Options{
+		Addr:         conf.Addr,
+		Password:     conf.Password.Value(),
+		DB:           conf.DB,
+		PoolSize:     conf.PoolSize,
+		MinIdleConns: conf.MinIdleConns,
+		DialTimeout:  conf.DialTimeout,
+		ReadTimeout:  conf.ReadTimeout,
+		WriteTimeout: conf.WriteTimeout,
+	})
+	if err := c.Ping(ctx).Err(); err != nil {
+		c.Close()
+		return nil, errors.Wrapf(err, "ping redis %s", conf.Addr)
+	}
+	return c, nil
+}
+
+// Init 创建客户端并设置为全局客户端
+func Init(ctx context.Context, conf *Config) error {
+	c, err := Open(ctx, conf)
+	if err != nil {
+		return err
+	}
+	SetClient(c, conf.KeyPrefix)
+	return nil
+}
+
+// SetClient 设置全局客户端，测试时可以设置为 miniredis 的客户端
+func SetClient(c *goredis.Client, prefix string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	client = c
+	if prefix != "" {
+		keyPrefix = prefix
+	}
+}
+
+// GetClient 获取全局客户端，未初始化时返回 nil
+func GetClient() *goredis.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return client
+}
+
+// Key 加上全局前缀的 key
+func Key(parts ...string) string {
+	mu.RLock()
+	k := keyPrefix
+	mu.RUnlock()
+
+	for i, p := range parts {
+		if i > 0 {
+			k += ":"
+		}
+		k += p
+	}
+	return k
+}
+
+// Close 关闭全局客户端
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if client == nil {
+		return nil
+	}
+	err := client.Close()
+	client = nil
+	return err
+}
+
+// HealthCheck 检查 redis 是否可用
+func HealthCheck(ctx context.Context) error {
+	c := GetClient()
+	if c == nil {
+		return ErrNotInit
+	}
+	return c.Ping(ctx).Err()
+}
@@ -0,0 +1,50 @@
+package tenant
+
+// 多租户的上下文
+// 租户 id 来自 jwt 中的 tid ，通过 context 在 http 、 db 、 ws 之间传递
+// db 中带有 tenant_id 字段的 model 会自动按租户过滤，context 中没有租户时拒绝访问
+// 需要跨租户访问时(例如迁移、定时任务)，显式使用 WithSystem
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+var ErrNoTenant = errors.New("no tenant in context")
+
+type tenantKey struct{}
+type systemKey struct{}
+
+// WithID 在 context 中设置租户 id
+func WithID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// FromContext 获取 context 中的租户 id
+func FromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// WithSystem 系统级操作，不按租户过滤，谨慎使用
+func WithSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// IsSystem 是否为系统级操作
+func IsSystem(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	v, _ := ctx.Value(systemKey{}).(bool)
+	return v
+}
+
+// RoomKey 租户隔离的房间 key
+func RoomKey(tenantID, room string) string {
+	return tenantID + "/" + room
+}
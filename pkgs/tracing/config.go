@@ -0,0 +1,29 @@
+package tracing
+
+import (
+	"night-fury/pkgs/config"
+	"time"
+)
+
+// Config 链路追踪配置，使用 OTLP http 协议导出，如 jaeger 、 otel collector
+type Config struct {
+	Enabled     bool              `mapstructure:"enabled"`
+	ServiceName string            `mapstructure:"service_name" default:"night-fury"`
+	Endpoint    string            `mapstructure:"endpoint" default:"localhost:4318"` // host:port
+	URLPath     string            `mapstructure:"url_path" default:"/v1/traces"`
+	Insecure    bool              `mapstructure:"insecure"` // 使用 http 而不是 https
+	Headers     map[string]string `mapstructure:"headers"`  // 如鉴权 header
+	Timeout     time.Duration     `mapstructure:"timeout" default:"10s"`
+
+	// 采样比例，有上游 span 时跟随上游的采样结果
+	SampleRatio float64 `mapstructure:"sample_ratio" default:"1" validate:"gte=0,lte=1"`
+}
+
+// LoadConfig 读取 tracing 下的配置
+func LoadConfig() (*Config, error) {
+	c := &Config{}
+	if err := config.Unmarshal("tracing", c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,143 @@
+package tracing
+
+// 基于 OpenTelemetry 的链路追踪
+//   conf, err := tracing.LoadConfig()
+//   tracing.Init(ctx, conf)
+//   ctx, span := tracing.Start(ctx, "do something")
+//   defer func() { tracing.End(span, err) }()
+//
+// 未初始化或未开启时 span 为空实现，不会产生开销
+// http 、 ws 消息、 gorm 和 kafka 已经自动创建 span ，kafka 通过消息 header 传递上下文
+// 测试时可以使用 InitWithExporter 和 tracetest.NewInMemoryExporter
+
+import (
+	"context"
+	"net/http"
+	"night-fury/pkgs/log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "night-fury"
+
+var (
+	provider *sdktrace.TracerProvider
+	mu       sync.Mutex
+)
+
+func init() {
+	// 未初始化时也需要传递上游的上下文
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Errorf(log.TagTracing, "otel error : %s", err)
+	}))
+}
+
+// Init 创建 OTLP exporter ，未开启时不做任何事
+func Init(ctx context.Context, conf *Config) error {
+	if !conf.Enabled {
+		return nil
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(conf.Endpoint),
+		otlptracehttp.WithURLPath(conf.URLPath),
+		otlptracehttp.WithTimeout(conf.Timeout),
+	}
+	if conf.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(conf.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(conf.Headers))
+	}
+	exp, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return errors.Wrap(err, "create otlp exporter")
+	}
+
+	setProvider(conf, sdktrace.WithBatcher(exp))
+	log.Infof(log.TagTracing, "tracing enabled, export to %s%s", conf.Endpoint, conf.URLPath)
+	return nil
+}
+
+// InitWithExporter 使用指定的 exporter 同步导出，用于测试
+func InitWithExporter(exp sdktrace.SpanExporter, conf *Config) {
+	setProvider(conf, sdktrace.WithSyncer(exp))
+}
+
+func setProvider(conf *Config, exporter sdktrace.TracerProviderOption) {
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(conf.ServiceName))
+	tp := sdktrace.NewTracerProvider(
+		exporter,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+	)
+
+	mu.Lock()
+	old := provider
+	provider = tp
+	mu.Unlock()
+
+	otel.SetTracerProvider(tp)
+	if old != nil {
+		old.Shutdown(context.Background())
+	}
+}
+
+// Close 导出剩余的 span ，tracing 组件最先启动，它的 Stop hook 在最后执行
+func Close() error {
+	mu.Lock()
+	tp := provider
+	mu.Unlock()
+	if tp == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	return errors.Wrap(tp.Shutdown(ctx), "shutdown tracer provider")
+}
+
+// Tracer 全局 tracer ，Init 之前为空实现
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start 创建 span ，需要调用 End 结束
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// End 结束 span ，err 不为空时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject 把 ctx 中的 span 写入 headers ，如 kafka 消息的 header
+func Inject(ctx context.Context, headers map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// Extract 从 headers 中读取上游的 span
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// ExtractHTTP 从 http header 中读取上游的 span
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	InitWithExporter(exp, &Config{ServiceName: "test", SampleRatio: 1})
+	t.Cleanup(func() { Close() })
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("failed"))
+	End(parent, nil)
+
+	spans := exp.GetSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+
+	// 通过 header 传递上下文
+	headers := map[string]string{}
+	Inject(ctx, headers)
+	assert.NotEmpty(t, headers["traceparent"])
+	remote := trace.SpanContextFromContext(Extract(context.Background(), headers))
+	assert.True(t, remote.IsRemote())
+	assert.Equal(t, parent.SpanContext().TraceID(), remote.TraceID())
+
+	h := http.Header{}
+	h.Set("traceparent", headers["traceparent"])
+	assert.Equal(t, remote.SpanID(), trace.SpanContextFromContext(ExtractHTTP(context.Background(), h)).SpanID())
+}
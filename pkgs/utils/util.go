@@ -4,14 +4,10 @@ import (
 	"fmt"
 	"hash/crc32"
 	"math/rand"
-	"os"
-	"os/signal"
 	"runtime"
-	"syscall"
 	"time"
 
 	"github.com/gogf/gf/util/gconv"
-	"github.com/pkg/errors"
 	"github.com/sony/sonyflake"
 )
 
@@ -110,35 +106,6 @@ func HashCode(s string) int {
 	return 0
 }
 
-// GraceShutdown 优雅关闭
-func GraceShutdown(funcList []func() error, sigs ...os.Signal) (os.Signal, error) {
-	if funcList == nil {
-		funcList = []func() error{
-			func() error { return nil },
-		}
-	}
-
-	shutdownChan := make(chan os.Signal, 1)
-
-	if len(sigs) > 0 {
-		signal.Notify(shutdownChan, sigs...)
-	} else {
-		signal.Notify(shutdownChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGSEGV)
-	}
-
-	sig := <-shutdownChan
-
-	var errList error
-	for i, f := range funcList {
-		err := TryTimes(f, 3)
-		if err != nil {
-			errList = errors.Wrap(errList, fmt.Sprintf("func exec error : index [%d], message: %s", i, err))
-		}
-	}
-
-	return sig, errList
-}
-
 // RunAfter 延时执行
 func RunAfter(f func(), t time.Duration, safeRun bool) error {
 	<-time.After(t)
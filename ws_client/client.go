@@ -2,11 +2,32 @@ package wsclient
 
 import (
 	"night-fury/pkgs/log"
+	"night-fury/pkgs/nacos"
 	"night-fury/pkgs/utils"
 	"night-fury/ws_client/client"
 	"sync"
+
+	"github.com/pkg/errors"
 )
 
+// Discover 通过 nacos 按权重选择一个 ws server ，返回连接地址
+func Discover(r *nacos.Registry, service string) (string, error) {
+	inst, err := r.Pick(service)
+	if err != nil {
+		return "", err
+	}
+
+	path := inst.Metadata[nacos.MetaWSPath]
+	if path == "" {
+		return "", errors.Errorf("instance %s has no ws path", inst.Addr())
+	}
+	scheme := inst.Metadata[nacos.MetaWSScheme]
+	if scheme == "" {
+		scheme = "ws"
+	}
+	return scheme + "://" + inst.Addr() + path, nil
+}
+
 func RunClient(addr string, closeChan chan struct{}) error {
 	c, err := client.NewClient(addr, closeChan)
 	if err != nil {
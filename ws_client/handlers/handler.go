@@ -36,7 +36,7 @@ func (m *msgHandlerHub) RegisterHandler(handler MessageHandler) {
 	m.msgHandlers[handlerID] = handler
 
 	if err := handler.Init(); err != nil {
-		log.NS().Panicf("init handler error %s", err)
+		log.Panicf(log.TagWSClient, "init handler error %s", err)
 	}
 }
 
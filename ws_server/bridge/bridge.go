@@ -0,0 +1,250 @@
+package bridge
+
+// kafka 与 websocket 之间的桥接
+// Inbound : 订阅 kafka topic ，将消息转换为 EncodeJSON 格式后发送到租户下的房间/用户
+// Outbound : 将客户端发送的指定类型的消息转发到 kafka ，消息体为 EncodeKafkaMsg 格式的 Envelope
+// 路由在 routes.go 中声明，通过 Init 注册
+
+import (
+	"context"
+	"encoding/json"
+	"night-fury/pkgs/kafka"
+	"night-fury/pkgs/log"
+	"night-fury/ws_server/handlers"
+	"night-fury/ws_server/iclient"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	HeaderTenantID = kafka.HeaderTenantID
+	HeaderRoom     = "x-room"
+	HeaderUserID   = "x-user-id"
+)
+
+var ErrNoTenant = errors.New("bridge message has no tenant")
+
+// Hub 发送消息的目标，*client.ClientHub
+type Hub interface {
+	Broadcast(tenantID, room string, msg []byte)
+	BroadcastTenant(tenantID string, msg []byte)
+	SendToUser(tenantID, userID string, msg []byte)
+}
+
+// Target 消息的接收方，Room 不为空时发送到房间，UserID 不为空时发送给用户，都为空时发送给整个租户
+type Target struct {
+	TenantID string
+	Room     string
+	UserID   string
+}
+
+// InboundRoute kafka topic 到 websocket 的路由
+type InboundRoute struct {
+	Topic   string
+	MsgType int // 发送给客户端的消息类型
+
+	// Target 解析消息的接收方，为 nil 时使用 HeaderTarget
+	Target func(msg *kafka.Message) (Target, error)
+	// Transform 转换消息，返回值以 json 发送给客户端，为 nil 时直接转发消息中的 json
+	Transform func(msg *kafka.Message) (interface{}, error)
+}
+
+// OutboundRoute 客户端消息到 kafka topic 的路由
+type OutboundRoute struct {
+	MsgType int
+	Topic   string
+
+	// Key 消息的 key ，相同 key 的消息有序，为 nil 时使用租户 id
+	Key func(c iclient.TenantClient, data []byte) string
+}
+
+// Envelope 转发到 kafka 的客户端消息
+type Envelope struct {
+	TenantID string          `json:"tenantID"`
+	UserID   string          `json:"userID"`
+	ClientID string          `json:"clientID"`
+	MsgType  int             `json:"msgType"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// HeaderTarget 从消息 header 中获取接收方
+func HeaderTarget(msg *kafka.Message) (Target, error) {
+	return Target{
+		TenantID: msg.Headers[HeaderTenantID],
+		Room:     msg.Headers[HeaderRoom],
+		UserID:   msg.Headers[HeaderUserID],
+	}, nil
+}
+
+// JSONTarget 从消息 json 的字段中获取接收方，字段名为空时忽略
+func JSONTarget(tenantField, roomField, userField string) func(msg *kafka.Message) (Target, error) {
+	get := func(data []byte, field string) string {
+		if field == "" {
+			return ""
+		}
+		return jsoniter.Get(data, field).ToString()
+	}
+	return func(msg *kafka.Message) (Target, error) {
+		data, err := messageJSON(msg)
+		if err != nil {
+			return Target{}, err
+		}
+		return Target{
+			TenantID: get(data, tenantField),
+			Room:     get(data, roomField),
+			UserID:   get(data, userField),
+		}, nil
+	}
+}
+
+// messageJSON 获取消息中的 json ，兼容 EncodeKafkaMsg 编码和直接的 json
+func messageJSON(msg *kafka.Message) ([]byte, error) {
+	if len(msg.Value) > 0 && (msg.Value[0] == '{' || msg.Value[0] == '[') {
+		return msg.Value, nil
+	}
+	return handlers.DecodeKafkaMsg(msg.Value)
+}
+
+// Bridge 桥接 kafka 和 websocket
+type Bridge struct {
+	hub      Hub
+	producer *kafka.Producer
+	consumer *kafka.Consumer
+}
+
+// New 创建桥接，producer 为 nil 时不能注册 Outbound ， consumer 为 nil 时不能注册 Inbound
+func New(hub Hub, producer *kafka.Producer, consumer *kafka.Consumer) *Bridge {
+	return &Bridge{hub: hub, producer: producer, consumer: consumer}
+}
+
+// Inbound 注册 kafka 到 websocket 的路由，需要在 consumer 启动前调用
+func (b *Bridge) Inbound(routes ...InboundRoute) error {
+	if b.consumer == nil {
+		return kafka.ErrNotInit
+	}
+	for _, r := range routes {
+		if err := b.consumer.Handle(r.Topic, b.inboundHandler(r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bridge) inboundHandler(r InboundRoute) kafka.Handler {
+	if r.Target == nil {
+		r.Target = HeaderTarget
+	}
+	return func(ctx context.Context, msg *kafka.Message) error {
+		frame, target, err := b.encodeInbound(r, msg)
+		if err != nil {
+			// 格式错误的消息重试也无法成功，跳过
+			log.Errorf(log.TagWS, "bridge message %s/%d/%d error : %s", msg.Topic, msg.Partition, msg.Offset, err)
+			return nil
+		}
+
+		switch {
+		case target.Room != "":
+			b.hub.Broadcast(target.TenantID, target.Room, frame)
+		case target.UserID != "":
+			b.hub.SendToUser(target.TenantID, target.UserID, frame)
+		default:
+			b.hub.BroadcastTenant(target.TenantID, frame)
+		}
+		return nil
+	}
+}
+
+func (b *Bridge) encodeInbound(r InboundRoute, msg *kafka.Message) ([]byte, Target, error) {
+	target, err := r.Target(msg)
+	if err != nil {
+		return nil, target, errors.Wrap(err, "resolve target")
+	}
+	// 没有租户的消息不发送，避免跨租户广播
+	if target.TenantID == "" {
+		return nil, target, ErrNoTenant
+	}
+
+	var data interface{}
+	if r.Transform != nil {
+		if data, err = r.Transform(msg); err != nil {
+			return nil, target, errors.Wrap(err, "transform")
+		}
+	} else {
+		raw, err := messageJSON(msg)
+		if err != nil {
+			return nil, target, err
+		}
+		data = json.RawMessage(raw)
+	}
+
+	frame, err := handlers.EncodeJSON(r.MsgType, data)
+	return frame, target, err
+}
+
+// Outbound 注册 websocket 到 kafka 的路由，会允许用户发送对应类型的消息，需要在启动前调用
+func (b *Bridge) Outbound(routes ...OutboundRoute) error {
+	if b.producer == nil {
+		return kafka.ErrNotInit
+	}
+	for _, r := range routes {
+		handlers.RegisterFunc(r.MsgType, b.outboundHandler(r))
+		handlers.AllowUserMsgType(r.MsgType)
+	}
+	return nil
+}
+
+func (b *Bridge) outboundHandler(r OutboundRoute) func(context.Context, iclient.Client, int, []byte) {
+	return func(ctx context.Context, c iclient.Client, msgType int, data []byte) {
+		tc, ok := c.(iclient.TenantClient)
+		if !ok {
+			log.Errorf(log.TagWS, "bridge msgType %d : client has no tenant", msgType)
+			return
+		}
+
+		msg, err := b.encodeOutbound(r, tc, msgType, data)
+		if err != nil {
+			log.Errorf(log.TagWS, "bridge msgType %d error : %s", msgType, err)
+			return
+		}
+		b.producer.SendAsync(*msg, nil)
+	}
+}
+
+func (b *Bridge) encodeOutbound(r OutboundRoute, c iclient.TenantClient, msgType int, data []byte) (*kafka.Message, error) {
+	if c.GetTenantID() == "" {
+		return nil, ErrNoTenant
+	}
+	raw, err := handlers.DecodeJSONData(data)
+	if err != nil {
+		return nil, err
+	}
+	if !jsoniter.Valid(raw) {
+		return nil, errors.Errorf("invalid json data from client %s", c.GetID())
+	}
+
+	value, err := handlers.EncodeKafkaMsg(&Envelope{
+		TenantID: c.GetTenantID(),
+		UserID:   c.GetUserID(),
+		ClientID: c.GetID(),
+		MsgType:  msgType,
+		Data:     raw,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	key := c.GetTenantID()
+	if r.Key != nil {
+		key = r.Key(c, raw)
+	}
+	return &kafka.Message{
+		Topic: r.Topic,
+		Key:   []byte(key),
+		Value: value,
+		Headers: map[string]string{
+			HeaderTenantID: c.GetTenantID(),
+			HeaderUserID:   c.GetUserID(),
+		},
+	}, nil
+}
@@ -0,0 +1,120 @@
+package bridge
+
+import (
+	"context"
+	"night-fury/pkgs/kafka"
+	"night-fury/ws_server/handlers"
+	"sync"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+type sent struct {
+	tenantID, room, userID string
+	msg                    []byte
+}
+
+type fakeHub struct {
+	mu   sync.Mutex
+	sent []sent
+}
+
+func (h *fakeHub) add(s sent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sent = append(h.sent, s)
+}
+func (h *fakeHub) Broadcast(tenantID, room string, msg []byte) {
+	h.add(sent{tenantID: tenantID, room: room, msg: msg})
+}
+func (h *fakeHub) BroadcastTenant(tenantID string, msg []byte) {
+	h.add(sent{tenantID: tenantID, msg: msg})
+}
+func (h *fakeHub) SendToUser(tenantID, userID string, msg []byte) {
+	h.add(sent{tenantID: tenantID, userID: userID, msg: msg})
+}
+func (h *fakeHub) list() []sent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]sent{}, h.sent...)
+}
+
+type fakeClient struct{}
+
+func (fakeClient) SendMsg([]byte)      {}
+func (fakeClient) Close()              {}
+func (fakeClient) GetID() string       { return "c1" }
+func (fakeClient) GetTenantID() string { return "tenant1" }
+func (fakeClient) GetUserID() string   { return "u1" }
+func (fakeClient) Join(room string)    {}
+func (fakeClient) Leave(room string)   {}
+
+func TestInbound(t *testing.T) {
+	broker := kafka.NewMemoryBroker(1)
+	conf := &kafka.Config{RetryBackoff: time.Millisecond}
+	p := kafka.NewProducer(broker, conf)
+	c := kafka.NewConsumer(broker, conf)
+	hub := &fakeHub{}
+
+	b := New(hub, p, c)
+	assert.Nil(t, b.Inbound(
+		InboundRoute{Topic: "order", MsgType: 2001, Target: JSONTarget("tenantID", "orderID", "")},
+		InboundRoute{Topic: "notice", MsgType: 2002},
+	))
+	assert.Nil(t, c.Start(context.Background()))
+	defer c.Stop()
+
+	ctx := context.Background()
+	assert.Nil(t, p.SendJSON(ctx, "order", "o1", map[string]string{"tenantID": "tenant1", "orderID": "o1"}))
+	assert.Nil(t, p.Send(ctx, kafka.Message{Topic: "notice", Value: []byte(`{"text":"hi"}`),
+		Headers: map[string]string{HeaderTenantID: "tenant2", HeaderUserID: "u1"}}))
+	// 没有租户的消息被丢弃
+	assert.Nil(t, p.Send(ctx, kafka.Message{Topic: "notice", Value: []byte(`{"text":"leak"}`)}))
+
+	assert.Eventually(t, func() bool { return len(hub.list()) == 2 }, time.Second, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 50)
+	byTenant := map[string]sent{}
+	for _, s := range hub.list() {
+		byTenant[s.tenantID] = s
+	}
+	assert.Len(t, byTenant, 2)
+
+	assert.Equal(t, "o1", byTenant["tenant1"].room)
+	msgType, data := handlers.DecodeMsgType(byTenant["tenant1"].msg)
+	assert.Equal(t, 2001, msgType)
+	raw, err := handlers.DecodeJSONData(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "o1", jsoniter.Get(raw, "orderID").ToString())
+
+	assert.Equal(t, "u1", byTenant["tenant2"].userID)
+	assert.Equal(t, "", byTenant["tenant2"].room)
+}
+
+func TestOutbound(t *testing.T) {
+	broker := kafka.NewMemoryBroker(1)
+	p := kafka.NewProducer(broker, &kafka.Config{})
+	b := New(&fakeHub{}, p, nil)
+
+	assert.Nil(t, b.Outbound(OutboundRoute{MsgType: 3001, Topic: "cursor"}))
+	assert.True(t, handlers.IsUserMsgType(3001))
+
+	frame, err := handlers.EncodeJSON(3001, map[string]int{"x": 1})
+	assert.Nil(t, err)
+	msgType, data := handlers.DecodeMsgType(frame)
+	b.outboundHandler(OutboundRoute{MsgType: 3001, Topic: "cursor"})(context.Background(), fakeClient{}, msgType, data)
+	assert.Nil(t, p.Close())
+
+	msgs := broker.Messages("cursor")
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, "tenant1", string(msgs[0].Key))
+	raw, err := handlers.DecodeKafkaMsg(msgs[0].Value)
+	assert.Nil(t, err)
+	env := &Envelope{}
+	assert.Nil(t, jsoniter.Unmarshal(raw, env))
+	assert.Equal(t, "u1", env.UserID)
+	assert.Equal(t, 3001, env.MsgType)
+	assert.JSONEq(t, `{"x":1}`, string(env.Data))
+}
@@ -0,0 +1,31 @@
+package bridge
+
+import (
+	"night-fury/pkgs/kafka"
+)
+
+// InboundRoutes kafka 到 websocket 的路由，例如
+//
+//	{Topic: "order_events", MsgType: enum.TYPE_ORDER_EVENT, Target: JSONTarget("tenantID", "orderID", "")}
+var InboundRoutes = []InboundRoute{}
+
+// OutboundRoutes websocket 到 kafka 的路由，例如
+//
+//	{MsgType: enum.TYPE_CURSOR, Topic: "cursor_events"}
+var OutboundRoutes = []OutboundRoute{}
+
+// Init 注册 routes.go 中声明的路由，需要在 kafka consumer 启动前调用
+func Init(hub Hub) error {
+	b := New(hub, kafka.GetProducer(), kafka.GetConsumer())
+	if len(InboundRoutes) > 0 {
+		if err := b.Inbound(InboundRoutes...); err != nil {
+			return err
+		}
+	}
+	if len(OutboundRoutes) > 0 {
+		if err := b.Outbound(OutboundRoutes...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,15 +3,27 @@ package client
 import (
 	"context"
 	"night-fury/pkgs/log"
+	"night-fury/pkgs/tenant"
+	"night-fury/pkgs/tracing"
 	"night-fury/pkgs/utils"
 	"night-fury/ws_server/handlers"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Client struct {
-	ID string
+	ID       string
+	TenantID string // 租户 id ，房间和广播按租户隔离
+	UserID   string
+
+	// 建立连接的 http 请求的 request id ，该连接上的消息都带上它
+	RequestID string
+	// 建立连接的 http 请求的 span ，消息的 span 与它关联
+	SpanContext trace.SpanContext
 
 	closeChan chan struct{}
 	conn      *websocket.Conn
@@ -21,14 +33,16 @@ type Client struct {
 
 	lastPingTime *time.Time
 
-	closingFlag bool
+	closingFlag int32 // 1 表示正在关闭，原子读写，SendMsg 可能在其他 goroutine 中触发关闭
 }
 
-func NewClient(conn *websocket.Conn, ID string) *Client {
+func NewClient(conn *websocket.Conn, ID, tenantID, userID string) *Client {
 	now := time.Now()
 
 	return &Client{
 		ID:           ID,
+		TenantID:     tenantID,
+		UserID:       userID,
 		conn:         conn,
 		closeChan:    make(chan struct{}, 1),
 		msgChan:      make(chan []byte, 60),
@@ -36,8 +50,42 @@ func NewClient(conn *websocket.Conn, ID string) *Client {
 	}
 }
 
+// SendMsg 放入发送队列，不会阻塞调用方（广播、bridge 的消费）
+// 队列满说明客户端读得太慢，丢弃消息并断开连接，由客户端重连
 func (c *Client) SendMsg(msg []byte) {
-	c.msgChan <- msg
+	if c.closing() {
+		return
+	}
+	select {
+	case c.msgChan <- msg:
+	default:
+		msgType, _ := handlers.DecodeMsgType(msg)
+		wsDropped.WithLabelValues(msgTypeLabel(msgType)).Inc()
+		log.Warnf(log.TagWSServer, "client %s send queue is full, close it", c.ID)
+		go c.Close()
+	}
+}
+
+func (c *Client) GetID() string {
+	return c.ID
+}
+
+func (c *Client) GetTenantID() string {
+	return c.TenantID
+}
+
+func (c *Client) GetUserID() string {
+	return c.UserID
+}
+
+// Join 加入当前租户下的房间
+func (c *Client) Join(room string) {
+	c.hub.Join(c, room)
+}
+
+// Leave 离开当前租户下的房间
+func (c *Client) Leave(room string) {
+	c.hub.Leave(c, room)
 }
 
 func (c *Client) ReadMsg() {
@@ -46,7 +94,7 @@ func (c *Client) ReadMsg() {
 	}()
 	c.conn.SetReadLimit(1024 * 1024 * 50) // 50 mb
 
-	for !c.closingFlag {
+	for !c.closing() {
 		c.conn.SetReadDeadline(time.Now().Add(time.Second * 60))
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
@@ -86,6 +134,8 @@ func (c *Client) WriteMsg() {
 			if err != nil {
 				return
 			}
+			msgType, _ := handlers.DecodeMsgType(message)
+			wsSent.WithLabelValues(msgTypeLabel(msgType)).Inc()
 
 		case <-pingTicker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(time.Second * 10))
@@ -104,25 +154,45 @@ func (c *Client) WriteMsg() {
 }
 
 func (c *Client) handleMsg(msg []byte) {
+	ctx := log.WithRequestID(tenant.WithID(context.Background(), c.TenantID), c.RequestID)
+	logger := log.WithContext(ctx).WithTag(log.TagWSServer)
+
 	// 处理message类型，并进行分发
 	msgType, data := handlers.DecodeMsgType(msg)
 
 	// 鉴权，是否能够发送该类型的消息
 	if !handlers.IsUserMsgType(msgType) {
-		log.Errorln(log.TagWSServer, "messagetype not allowed")
+		wsReceived.WithLabelValues("invalid").Inc()
+		logger.Errorf("messagetype %d not allowed", msgType)
 		return
 	}
+	wsReceived.WithLabelValues(msgTypeLabel(msgType)).Inc()
 
 	// 获取处理器并处理
 	handlerFunc, err := handlers.MessageHandlers.GetHandler(msgType)
 	if err != nil {
-		log.Errorf(log.TagWSServer, "get msg handler error : %s", err)
+		logger.Errorf("get msg handler error : %s", err)
+		return
 	}
+
+	// 消息是独立的调用链，通过 link 关联到建立连接的请求
+	ctx, span := tracing.Start(ctx, "ws.message",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithLinks(trace.Link{SpanContext: c.SpanContext}),
+		trace.WithAttributes(
+			attribute.Int("ws.msg_type", msgType),
+			attribute.String("ws.client_id", c.ID),
+			attribute.String("tenant_id", c.TenantID),
+		),
+	)
+	defer span.End()
+
 	msgCtx := &handlers.MsgContext{
-		Ctx:    context.Background(),
+		Ctx:    ctx,
 		Client: c,
 		Msg:    data,
 	}
+	defer observeHandle(msgType, time.Now())
 	handlerFunc(msgCtx)
 }
 
@@ -137,15 +207,35 @@ func (c *Client) LastMessage(msg []byte) {
 	}
 }
 
+// shutdown 发送关闭消息后立即断开，用于 Hub.CloseAll
+func (c *Client) shutdown(msg []byte) {
+	if !atomic.CompareAndSwapInt32(&c.closingFlag, 0, 1) {
+		return
+	}
+
+	if c.conn != nil {
+		c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		if err := c.conn.Close(); err != nil {
+			log.Errorf(log.TagWSServer, "close client error : %s", err)
+		}
+	}
+	c.hub.UnRegister(c.ID)
+}
+
+func (c *Client) closing() bool {
+	return atomic.LoadInt32(&c.closingFlag) == 1
+}
+
 func (c *Client) Close() {
-	if c.closingFlag {
+	if !atomic.CompareAndSwapInt32(&c.closingFlag, 0, 1) {
 		return
 	}
 	var err error
-	c.closingFlag = true
 
-	if err = c.conn.Close(); err != nil {
-		log.Errorf(log.TagWSServer, "close client error : %s", err)
+	if c.conn != nil {
+		if err = c.conn.Close(); err != nil {
+			log.Errorf(log.TagWSServer, "close client error : %s", err)
+		}
 	}
 
 	err = utils.RunAfter(func() {
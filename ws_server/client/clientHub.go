@@ -2,8 +2,10 @@ package client
 
 import (
 	"fmt"
+	"night-fury/pkgs/tenant"
 	"sync"
 
+	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 )
 
@@ -12,15 +14,26 @@ var Hub *ClientHub
 var ErrClientExist = errors.New("client already exist")
 
 func init() {
-	Hub = &ClientHub{
-		mu:      &sync.RWMutex{},
-		clients: make(map[string]*Client, 100),
+	Hub = NewClientHub()
+}
+
+// NewClientHub 创建连接管理
+func NewClientHub() *ClientHub {
+	return &ClientHub{
+		mu:          &sync.RWMutex{},
+		clients:     make(map[string]*Client, 100),
+		rooms:       make(map[string]map[string]*Client),
+		clientRooms: make(map[string]map[string]bool),
 	}
 }
 
+// ClientHub 连接管理
+// 房间以 tenant.RoomKey(tenantID, room) 为 key ，不同租户的同名房间互不可见
 type ClientHub struct {
-	mu      sync.Locker
-	clients map[string]*Client
+	mu          *sync.RWMutex
+	clients     map[string]*Client
+	rooms       map[string]map[string]*Client // roomKey -> clientID -> client
+	clientRooms map[string]map[string]bool    // clientID -> roomKey
 }
 
 func (h *ClientHub) Register(c *Client) error {
@@ -40,7 +53,82 @@ func (h *ClientHub) Register(c *Client) error {
 func (h *ClientHub) UnRegister(clientID string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.clients[clientID] = nil
+
+	for key := range h.clientRooms[clientID] {
+		h.leave(clientID, key)
+	}
+	delete(h.clients, clientID)
+}
+
+// Count 连接数
+func (h *ClientHub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// RoomCount 所有租户的房间数
+func (h *ClientHub) RoomCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms)
+}
+
+// QueueStats 所有连接发送队列中的消息总数和最大的队列长度
+func (h *ClientHub) QueueStats() (total, max int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, c := range h.clients {
+		n := len(c.msgChan)
+		total += n
+		if n > max {
+			max = n
+		}
+	}
+	return total, max
+}
+
+// CloseAll 通知所有连接服务正在关闭并断开，客户端收到后重连到其他实例
+func (h *ClientHub) CloseAll() {
+	h.mu.RLock()
+	list := make([]*Client, 0, len(h.clients))
+	for _, c := range h.clients {
+		list = append(list, c)
+	}
+	h.mu.RUnlock()
+
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown")
+	for _, c := range list {
+		c.shutdown(msg)
+	}
+}
+
+// HubStats 连接统计
+type HubStats struct {
+	Connections   int            `json:"connections"`
+	Rooms         int            `json:"rooms"`
+	Tenants       map[string]int `json:"tenants"` // 租户 -> 连接数
+	QueuedMsgs    int            `json:"queued_msgs"`
+	MaxQueueDepth int            `json:"max_queue_depth"`
+}
+
+// Stats 连接、房间和发送队列的统计
+func (h *ClientHub) Stats() *HubStats {
+	total, max := h.QueueStats()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	s := &HubStats{
+		Connections:   len(h.clients),
+		Rooms:         len(h.rooms),
+		Tenants:       make(map[string]int),
+		QueuedMsgs:    total,
+		MaxQueueDepth: max,
+	}
+	for _, c := range h.clients {
+		s.Tenants[c.TenantID]++
+	}
+	return s
 }
 
 func (h *ClientHub) Reset(c *Client) {
@@ -48,3 +136,87 @@ func (h *ClientHub) Reset(c *Client) {
 
 	h.Register(c)
 }
+
+// Join 加入房间，房间属于连接所在的租户
+func (h *ClientHub) Join(c *Client, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := tenant.RoomKey(c.TenantID, room)
+	if h.rooms[key] == nil {
+		h.rooms[key] = make(map[string]*Client)
+	}
+	h.rooms[key][c.ID] = c
+
+	if h.clientRooms[c.ID] == nil {
+		h.clientRooms[c.ID] = make(map[string]bool)
+	}
+	h.clientRooms[c.ID][key] = true
+}
+
+// Leave 离开房间
+func (h *ClientHub) Leave(c *Client, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.leave(c.ID, tenant.RoomKey(c.TenantID, room))
+}
+
+func (h *ClientHub) leave(clientID, key string) {
+	if members, ok := h.rooms[key]; ok {
+		delete(members, clientID)
+		if len(members) == 0 {
+			delete(h.rooms, key)
+		}
+	}
+	if rooms, ok := h.clientRooms[clientID]; ok {
+		delete(rooms, key)
+		if len(rooms) == 0 {
+			delete(h.clientRooms, clientID)
+		}
+	}
+}
+
+// Broadcast 向租户下的房间广播
+func (h *ClientHub) Broadcast(tenantID, room string, msg []byte) {
+	h.mu.RLock()
+	members := h.rooms[tenant.RoomKey(tenantID, room)]
+	list := make([]*Client, 0, len(members))
+	for _, c := range members {
+		list = append(list, c)
+	}
+	h.mu.RUnlock()
+
+	for _, c := range list {
+		c.SendMsg(msg)
+	}
+}
+
+// BroadcastTenant 向租户下的所有连接广播
+func (h *ClientHub) BroadcastTenant(tenantID string, msg []byte) {
+	h.send(msg, func(c *Client) bool {
+		return c.TenantID == tenantID
+	})
+}
+
+// SendToUser 向租户下某个用户的所有连接发送消息
+func (h *ClientHub) SendToUser(tenantID, userID string, msg []byte) {
+	h.send(msg, func(c *Client) bool {
+		return c.TenantID == tenantID && c.UserID == userID
+	})
+}
+
+func (h *ClientHub) send(msg []byte, filter func(c *Client) bool) {
+	h.mu.RLock()
+	var list []*Client
+	for _, c := range h.clients {
+		if filter(c) {
+			list = append(list, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range list {
+		c.SendMsg(msg)
+	}
+}
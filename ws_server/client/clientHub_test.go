@@ -0,0 +1,74 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHubTenant(t *testing.T) {
+	h := NewClientHub()
+	c1 := NewClient(nil, "c1", "tenant1", "u1")
+	c2 := NewClient(nil, "c2", "tenant1", "u2")
+	c3 := NewClient(nil, "c3", "tenant2", "u1")
+	for _, c := range []*Client{c1, c2, c3} {
+		assert.Nil(t, h.Register(c))
+		c.Join("room")
+	}
+
+	// 同名房间按租户隔离
+	h.Broadcast("tenant1", "room", []byte("hi"))
+	assert.Len(t, c1.msgChan, 1)
+	assert.Len(t, c2.msgChan, 1)
+	assert.Len(t, c3.msgChan, 0)
+
+	h.SendToUser("tenant2", "u1", []byte("hi"))
+	assert.Len(t, c1.msgChan, 1)
+	assert.Len(t, c3.msgChan, 1)
+
+	h.UnRegister("c2")
+	h.BroadcastTenant("tenant1", []byte("hi"))
+	assert.Len(t, c1.msgChan, 2)
+	assert.Len(t, c2.msgChan, 1)
+	assert.Len(t, h.rooms, 2)
+	assert.Len(t, h.clients, 2)
+
+	assert.Equal(t, 2, h.Count())
+	assert.Equal(t, 2, h.RoomCount())
+	total, max := h.QueueStats()
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 2, max)
+}
+
+func TestSlowClient(t *testing.T) {
+	h := NewClientHub()
+	slow := NewClient(nil, "slow", "tenant1", "u1")
+	fast := NewClient(nil, "fast", "tenant1", "u2")
+	for _, c := range []*Client{slow, fast} {
+		assert.Nil(t, h.Register(c))
+		c.Join("room")
+	}
+
+	// slow 从不读取，队列满后不能阻塞广播，并且会被断开
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < cap(slow.msgChan)+10; i++ {
+			h.Broadcast("tenant1", "room", []byte("hi"))
+			if i%10 == 0 {
+				for len(fast.msgChan) > 0 {
+					<-fast.msgChan
+				}
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("broadcast blocked by slow client")
+	}
+	assert.Len(t, slow.msgChan, cap(slow.msgChan))
+	assert.Eventually(t, func() bool { return slow.closing() }, time.Second, time.Millisecond*10)
+	assert.False(t, fast.closing())
+}
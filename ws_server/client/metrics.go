@@ -0,0 +1,39 @@
+package client
+
+import (
+	"night-fury/pkgs/metrics"
+	"strconv"
+	"time"
+)
+
+var (
+	wsReceived = metrics.NewCounterVec("ws", "messages_received_total", "ws messages received by msg type, not allowed types are counted as invalid", "msg_type")
+	wsSent     = metrics.NewCounterVec("ws", "messages_sent_total", "ws messages sent by msg type", "msg_type")
+	wsDropped  = metrics.NewCounterVec("ws", "messages_dropped_total", "ws messages dropped because the send queue is full", "msg_type")
+	wsHandle   = metrics.NewHistogramVec("ws", "handler_duration_seconds", "ws message handler latency", nil, "msg_type")
+)
+
+func init() {
+	metrics.NewGaugeFunc("ws", "connections", "ws connections of this instance", func() float64 {
+		return float64(Hub.Count())
+	})
+	metrics.NewGaugeFunc("ws", "rooms", "ws rooms of this instance", func() float64 {
+		return float64(Hub.RoomCount())
+	})
+	metrics.NewGaugeFunc("ws", "send_queue_messages", "messages waiting in the send queues of all ws connections", func() float64 {
+		total, _ := Hub.QueueStats()
+		return float64(total)
+	})
+	metrics.NewGaugeFunc("ws", "send_queue_max", "max send queue depth of ws connections", func() float64 {
+		_, max := Hub.QueueStats()
+		return float64(max)
+	})
+}
+
+func msgTypeLabel(msgType int) string {
+	return strconv.Itoa(msgType)
+}
+
+func observeHandle(msgType int, start time.Time) {
+	wsHandle.WithLabelValues(msgTypeLabel(msgType)).Observe(time.Since(start).Seconds())
+}
@@ -46,6 +46,18 @@ func initUserMsgType() {
 	}
 }
 
+// AllowUserMsgType 允许用户发送的消息类型，需要在启动前调用
+func AllowUserMsgType(msgTypes ...int) {
+	for _, t := range msgTypes {
+		userMsgType[t] = true
+	}
+}
+
+// RegisterFunc 注册消息处理函数，同一类型的消息按顺序处理，需要在启动前调用
+func RegisterFunc(msgType int, handler func(context.Context, client.Client, int, []byte)) {
+	MessageHandlers.RegisterHandler(newHandler(msgType, handler))
+}
+
 type msgHandlerHub struct {
 	msgHandlers map[int]MessageHandler
 }
@@ -55,7 +67,7 @@ func (m *msgHandlerHub) RegisterHandler(handler MessageHandler) {
 	m.msgHandlers[handlerID] = handler
 
 	if err := handler.Init(); err != nil {
-		log.NS().Panicf("init handler error %s", err)
+		log.Panicf(log.TagWSServer, "init handler error %s", err)
 	}
 }
 
@@ -145,6 +157,18 @@ func EncodeKafkaMsg(jsonData interface{}) ([]byte, error) {
 	return b, nil
 }
 
+// DecodeKafkaMsg 解析 EncodeKafkaMsg 编码的消息，返回 json 数据
+func DecodeKafkaMsg(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errors.New("kafka msg decode error: no enough data")
+	}
+	msgLen := binary.BigEndian.Uint32(data[0:4])
+	if len(data) < int(msgLen)+4 {
+		return nil, errors.New("kafka msg decode error: no enough data")
+	}
+	return data[4 : msgLen+4], nil
+}
+
 func EncodeJSON(msgType int, jsonData interface{}) ([]byte, error) {
 	b := formatMsgType(msgType)
 	if jsonData == nil {
@@ -197,6 +221,18 @@ func decodeBindMetaData(data []byte, pointer interface{}) error {
 	return nil
 }
 
+// DecodeJSONData 获取 EncodeJSON 编码的消息(去掉消息类型后)中的 json 数据
+func DecodeJSONData(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errors.New("json data decode error: no enough data")
+	}
+	dataLen := binary.BigEndian.Uint32(data[0:4])
+	if len(data) < int(dataLen)+4 {
+		return nil, errors.New("json data decode error: no enough data")
+	}
+	return data[4 : dataLen+4], nil
+}
+
 func DecodeMsgType(data []byte) (int, []byte) {
 	if len(data) < 2 {
 		// 消息格式错误
@@ -12,12 +12,25 @@ type paramJoin struct {
 }
 
 func HandleJoin(ctx context.Context, c client.Client, msgType int, msg []byte) {
+	logger := log.WithContext(ctx).WithTag(log.TagActionJoin)
+
 	param := &paramJoin{}
 	err := decodeBindMetaData(msg, param)
 	if err != nil {
-		log.Errorf(log.TagActionJoin, "decode parameter error %s", err)
+		logger.Errorf("decode parameter error %s", err)
+		return
+	}
+
+	if param.ID == "" {
+		logger.Error("room id is empty")
 		return
 	}
 
-	// 做一些处理
+	tc, ok := c.(client.TenantClient)
+	if !ok {
+		logger.Error("client can not join room")
+		return
+	}
+	// 房间按租户隔离，只能加入自己租户下的房间
+	tc.Join(param.ID)
 }
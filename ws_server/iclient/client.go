@@ -5,3 +5,15 @@ type Client interface {
 	SendMsg([]byte) // 发送消息
 	Close()         // 关闭该连接
 }
+
+// TenantClient 带有身份信息的连接，房间按租户隔离
+type TenantClient interface {
+	Client
+
+	GetID() string       // 连接 id
+	GetTenantID() string // 租户 id
+	GetUserID() string   // 用户 id
+
+	Join(room string)  // 加入当前租户下的房间
+	Leave(room string) // 离开当前租户下的房间
+}
@@ -3,6 +3,8 @@ package wsserver
 import (
 	"net/http"
 	"night-fury/dashboard/api"
+	"night-fury/pkgs/auth"
+	"night-fury/pkgs/log"
 	"night-fury/pkgs/utils"
 	"night-fury/ws_server/client"
 	"time"
@@ -11,7 +13,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"github.com/nacos-group/nacos-sdk-go/common/logger"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var upgrader = websocket.Upgrader{
@@ -23,16 +25,33 @@ var upgrader = websocket.Upgrader{
 }
 
 func Serve(c *gin.Context, w http.ResponseWriter, r *http.Request) {
+	// 鉴权，浏览器无法设置 header 时可以通过 query 传递 token
+	authToken := r.Header.Get("x-auth")
+	if authToken == "" {
+		authToken = r.URL.Query().Get("token")
+	}
+	if authToken == "" {
+		api.Fail(c, 403, api.NewMeta(api.CODE_ERR_NOTPERMIT, "no token"))
+		return
+	}
+	claims, err := auth.JwtTokenValidate(authToken)
+	if err != nil {
+		api.Fail(c, 403, api.NewMeta(api.CODE_ERR_NOTPERMIT, err))
+		return
+	}
+
 	// 创建连接
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		logger.Errorf("upgrade ws error : %s", err)
+		log.Errorf(log.TagWSServer, "upgrade ws error : %s", err)
 		api.Fail(c, 500, nil)
 		return
 	}
 
 	clientID := uuid.NewV4().String()
-	clientInstance := client.NewClient(conn, clientID)
+	clientInstance := client.NewClient(conn, clientID, claims.TenantID, claims.ID)
+	clientInstance.RequestID = log.RequestID(r.Context())
+	clientInstance.SpanContext = trace.SpanContextFromContext(r.Context())
 
 	err = client.Hub.Register(clientInstance)
 	if err != nil {